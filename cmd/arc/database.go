@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/acim/arc/pkg/store"
+	"github.com/acim/arc/pkg/store/pgstore"
+	"github.com/acim/arc/pkg/store/sqlitestore"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// usersTable stores users added by operators, since users can not sign up. Only users with the admin flag
+// may manage contact form submissions.
+const usersTable = "admin"
+
+var errUnknownDriver = errors.New("unknown database driver")
+
+type dbConfig struct {
+	// Driver is either postgres or sqlite.
+	Driver   string `def:"postgres"`
+	Hostname string `def:"postgres"`
+	// Replicas is comma separated list of read replica hostnames.
+	Replicas string
+	Username string `def:"postgres"`
+	Password string
+	Name     string `def:"postgres"`
+	// RowLevelSecurity enables Postgres row level security tenant isolation of the users table. Other
+	// tables are scoped by queries only, see pgstore package documentation.
+	RowLevelSecurity bool
+	// Path is SQLite database file path.
+	Path string `def:"arc.db"`
+}
+
+// database contains stores of the configured driver.
+type database struct {
+	users   store.Users
+	migrate func(ctx context.Context) error
+	close   func()
+	// pool is set only for postgres driver and enables features depending on it.
+	pool *pgxpool.Pool
+	// replicas are set if postgres read replicas are configured. Their health should be checked by a worker.
+	replicas *pgstore.Replicas
+}
+
+func openDatabase(ctx context.Context, c dbConfig) (*database, error) {
+	switch c.Driver {
+	case "postgres":
+		return openPostgres(ctx, c)
+	case "sqlite":
+		db, err := sqlitestore.NewDB(ctx, c.Path)
+		if err != nil {
+			return nil, fmt.Errorf("open sqlite: %w", err)
+		}
+
+		return &database{
+			users:    sqlitestore.NewUsers(db, sqlitestore.UsersTableName(usersTable)),
+			migrate:  sqlitestore.NewMigrator(db, sqlitestore.MigratorUsersTable(usersTable)).Migrate,
+			close:    func() { _ = db.Close() },
+			pool:     nil,
+			replicas: nil,
+		}, nil
+	default:
+		return nil, fmt.Errorf("%s: %w", c.Driver, errUnknownDriver)
+	}
+}
+
+func openPostgres(ctx context.Context, c dbConfig) (*database, error) {
+	pool, err := pgstore.NewDB(ctx, c.Hostname, c.Username, c.Password, c.Name)
+	if err != nil {
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+
+	migratorOpts := []pgstore.MigratorOption{pgstore.MigratorUsersTable(usersTable)}
+	usersOpts := []pgstore.UsersOption{pgstore.UsersTableName(usersTable)}
+
+	if c.RowLevelSecurity {
+		migratorOpts = append(migratorOpts, pgstore.MigratorRowLevelSecurity())
+		usersOpts = append(usersOpts, pgstore.UsersRowLevelSecurity())
+	}
+
+	d := &database{ //nolint:exhaustivestruct
+		migrate: pgstore.NewMigrator(pool, migratorOpts...).Migrate,
+		close:   pool.Close,
+		pool:    pool,
+	}
+
+	if c.Replicas != "" {
+		replicas, err := newReplicas(ctx, c)
+		if err != nil {
+			pool.Close()
+
+			return nil, fmt.Errorf("connect to postgres replica: %w", err)
+		}
+
+		// Unreachable replicas start as unhealthy, instead of preventing startup.
+		replicas.Check(ctx)
+
+		d.replicas = replicas
+		usersOpts = append(usersOpts, pgstore.UsersReplicas(replicas))
+		d.close = func() {
+			replicas.Close()
+			pool.Close()
+		}
+	}
+
+	d.users = pgstore.NewUsers(pool, usersOpts...)
+
+	return d, nil
+}
+
+func newReplicas(ctx context.Context, c dbConfig) (*pgstore.Replicas, error) {
+	hostnames := strings.Split(c.Replicas, ",")
+	pools := make([]*pgxpool.Pool, 0, len(hostnames))
+
+	for _, hostname := range hostnames {
+		pool, err := pgstore.NewReplicaDB(ctx, strings.TrimSpace(hostname), c.Username, c.Password, c.Name)
+		if err != nil {
+			for _, p := range pools {
+				p.Close()
+			}
+
+			return nil, fmt.Errorf("%s: %w", hostname, err)
+		}
+
+		pools = append(pools, pool)
+	}
+
+	return pgstore.NewReplicas(pools), nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/acim/arc/pkg/mail"
+	"github.com/mailgun/mailgun-go/v4"
+	"go.uber.org/zap"
+)
+
+var (
+	errUnknownMailDriver = errors.New("unknown mail driver")
+	errNoMailFrom        = errors.New("sender address not set")
+)
+
+type mailConfig struct {
+	// Driver is either mailgun or smtp. Comma separated list of drivers, i.e. mailgun,smtp, makes
+	// e-mails sent by the first available one. Development drivers log, file and memory are allowed only
+	// in dev environment. Default is mailgun, or log in dev environment.
+	Driver string
+	// Dir is the directory where file driver writes .eml files.
+	Dir string `def:"mail"`
+	// From is the sender address, i.e. "Contact form <noreply@example.com>".
+	From      string
+	Recipient string
+}
+
+type smtpConfig struct {
+	Host string
+	Port int `def:"587"`
+	// Security is one of starttls, tls or plain.
+	Security string `def:"starttls"`
+	// Auth is one of plain, login or cram-md5. Authentication is skipped if Username is empty.
+	Auth      string `def:"plain"`
+	Username  string
+	Password  string
+	LocalName string `def:"localhost"`
+}
+
+// setMailDefaults sets driver and sender address defaults, which differ in dev environment so that
+// the server runs without mail provider credentials.
+func setMailDefaults(c *config) {
+	if c.Environment == devEnvironment {
+		if c.Mail.Driver == "" {
+			c.Mail.Driver = "log"
+		}
+
+		if c.Mail.From == "" {
+			c.Mail.From = "arc <noreply@localhost>"
+		}
+	}
+
+	if c.Mail.Driver == "" {
+		c.Mail.Driver = "mailgun"
+	}
+}
+
+// newMailSender creates sender of the configured drivers. Returned function releases its resources.
+func newMailSender(c *config, logger *zap.Logger) (mail.Sender, func(), error) { //nolint:ireturn
+	if c.Mail.From == "" {
+		return nil, nil, errNoMailFrom
+	}
+
+	drivers := strings.Split(c.Mail.Driver, ",")
+	if len(drivers) == 1 {
+		return newDriverSender(c, drivers[0], logger)
+	}
+
+	providers := make([]mail.Provider, 0, len(drivers))
+	closers := make([]func(), 0, len(drivers))
+	closeAll := func() {
+		for _, closeSender := range closers {
+			closeSender()
+		}
+	}
+
+	for _, driver := range drivers {
+		driver = strings.TrimSpace(driver)
+
+		sender, closeSender, err := newDriverSender(c, driver, logger)
+		if err != nil {
+			closeAll()
+
+			return nil, nil, err
+		}
+
+		providers = append(providers, mail.Provider{Name: driver, Sender: sender})
+		closers = append(closers, closeSender)
+	}
+
+	return mail.NewFailover(providers, logger), closeAll, nil
+}
+
+func newDriverSender(c *config, driver string, logger *zap.Logger) (mail.Sender, func(), error) { //nolint:ireturn
+	switch driver {
+	case "mailgun":
+		return mail.NewMailgun(mailgun.NewMailgun(c.Mailgun.Domain, c.Mailgun.APIKey)), func() {}, nil
+	case "smtp":
+		sender, err := newSMTP(&c.SMTP)
+		if err != nil {
+			return nil, nil, fmt.Errorf("smtp: %w", err)
+		}
+
+		return sender, func() { _ = sender.Close() }, nil
+	}
+
+	if c.Environment == devEnvironment {
+		switch driver {
+		case "log":
+			return mail.NewLog(logger), func() {}, nil
+		case "file":
+			sender, err := mail.NewFile(c.Mail.Dir)
+			if err != nil {
+				return nil, nil, fmt.Errorf("file: %w", err)
+			}
+
+			return sender, func() {}, nil
+		case "memory":
+			return mail.NewMemory(), func() {}, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("%q: %w", driver, errUnknownMailDriver)
+}
+
+func newSMTP(c *smtpConfig) (*mail.SMTP, error) {
+	security, err := mail.ParseSecurity(c.Security)
+	if err != nil {
+		return nil, fmt.Errorf("parse security: %w", err)
+	}
+
+	opts := []mail.SMTPOption{
+		mail.SMTPSecurity(security),
+		mail.SMTPLocalName(c.LocalName),
+	}
+
+	if c.Username != "" {
+		auth, err := mail.SMTPAuth(c.Auth, c.Username, c.Password)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+
+		opts = append(opts, auth)
+	}
+
+	return mail.NewSMTP(c.Host, c.Port, opts...), nil
+}
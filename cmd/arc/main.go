@@ -1,27 +1,34 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
+	_ "time/tzdata" // time zones are validated against embedded database, the image has none
 
+	"github.com/acim/arc/pkg/contact"
 	"github.com/acim/arc/pkg/controller"
+	"github.com/acim/arc/pkg/job"
 	"github.com/acim/arc/pkg/mail"
+	arcmw "github.com/acim/arc/pkg/middleware"
+	"github.com/acim/arc/pkg/outbox"
 	"github.com/acim/arc/pkg/rest"
+	"github.com/acim/arc/pkg/schedule"
+	"github.com/acim/arc/pkg/store/cachestore"
+	"github.com/acim/arc/pkg/store/metricstore"
 	"github.com/acim/arc/pkg/store/pgstore"
+	"github.com/acim/arc/pkg/tenant"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/jwtauth/v5"
-	_ "github.com/jackc/pgx/stdlib"
-	"github.com/mailgun/mailgun-go/v4"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.ectobit.com/act"
 )
 
-type dbConfig struct {
-	Hostname string `def:"postgres"`
-	Username string `def:"postgres"`
-	Password string
-	Name     string `def:"postgres"`
-}
+// devEnvironment enables development features.
+const devEnvironment = "dev"
 
 type config struct {
 	ServiceName string `def:"arc"`
@@ -33,12 +40,26 @@ type config struct {
 		AuthTokenExpiration    time.Duration `env:"ARC_JWT_AUTH_TOKEN_EXP" def:"15m"`
 		RefreshTokenExpiration time.Duration `env:"ARC_JWT_REFRESH_TOKEN_EXP" def:"168h"`
 	}
-	DB      dbConfig
+	DB     dbConfig
+	Tenant struct {
+		// Header is the name of request header containing tenant ID.
+		Header string
+		// Domain is base domain whose subdomains are tenant IDs.
+		Domain string
+		// Token enables resolving tenant from JWT claim.
+		Token bool
+	}
+	Mail    mailConfig
 	Mailgun struct {
-		Domain    string
-		APIKey    string
-		Recipient string
+		Domain string
+		APIKey string
+		// WebhookSigningKey enables receiving event webhooks.
+		WebhookSigningKey string
 	}
+	SMTP smtpConfig
+	Spam spamConfig
+	// TrustProxy makes client IP address taken from X-Forwarded-For or X-Real-IP header.
+	TrustProxy bool
 }
 
 func main() { //nolint:funlen
@@ -62,28 +83,123 @@ func main() { //nolint:funlen
 			exit("logger", err)
 		}
 
-		db, err := pgstore.NewDB(c.DB.Hostname, c.DB.Username, c.DB.Password, c.DB.Name)
+		db, err := openDatabase(context.Background(), c.DB)
 		if err != nil {
-			exit("connect to postgres", err)
+			exit("open database", err)
 		}
+		defer db.close()
 
-		users := pgstore.NewUsers(db, pgstore.UsersTableName("admin"))
+		instrumenter := metricstore.NewInstrumenter(c.ServiceName, logger)
+		prometheus.MustRegister(instrumenter)
+		users := cachestore.NewUsers(
+			metricstore.NewUsers(db.users, instrumenter),
+			c.ServiceName)
+		prometheus.MustRegister(users)
 		jwtAuth := jwtauth.New("HS256", []byte(c.JWT.Secret), nil)
 		authController := controller.NewAuth(users, jwtAuth, logger)
 
-		mailSender := mail.NewMailgun(mailgun.NewMailgun(c.Mailgun.Domain, c.Mailgun.APIKey))
-		mailController := controller.NewMail(mailSender, c.Mailgun.Recipient, logger)
+		setMailDefaults(c)
+
+		mailSender, closeMail, err := newMailSender(c, logger)
+		if err != nil {
+			exit("mail sender", err)
+		}
+		defer closeMail()
+
+		catalog, err := controller.Catalog()
+		if err != nil {
+			exit("catalog", err)
+		}
+
+		mailTemplates, err := controller.MailTemplates()
+		if err != nil {
+			exit("mail templates", err)
+		}
+
+		mailOpts, err := mailOptions(&c.Spam)
+		if err != nil {
+			exit("spam protection", err)
+		}
+
+		// With postgres contact form submissions are stored and delivered in background by the job pool to
+		// recipients not found on the suppression list. Acknowledgements to visitors are queued by the outbox
+		// relay and sent by the job pool as well.
+		var (
+			jobPool     *job.Pool
+			relay       *outbox.Relay
+			submissions *pgstore.Submissions
+			deliverer   contact.Deliverer = contact.NewMailer(mailSender, mailTemplates, c.Mail.From,
+				c.Mail.Recipient, logger)
+		)
+
+		if db.pool != nil {
+			mailSender = mail.NewSuppressing(mailSender, pgstore.NewSuppressions(db.pool))
+			submissions = pgstore.NewSubmissions(db.pool)
+			jobs := pgstore.NewJobs(db.pool)
+			jobPool = job.NewPool(c.ServiceName, jobs, logger)
+			prometheus.MustRegister(jobPool)
+			mail.HandleQueued(jobPool, mailSender)
+			contact.Handle(jobPool, submissions, contact.NewMailer(mailSender, mailTemplates, c.Mail.From,
+				c.Mail.Recipient, logger, contact.MailerSubmissions(submissions)))
+			deliverer = contact.NewQueued(job.NewQueue(jobs))
+			outboxStore := pgstore.NewOutbox(db.pool)
+			relay = outbox.NewRelay(outboxStore, logger)
+			contact.HandleSubmitted(relay, submissions,
+				contact.NewAcknowledger(mail.NewQueued(job.NewQueue(jobs)), mailTemplates, c.Mail.From, logger))
+			mailOpts = append(mailOpts, controller.MailSubmissions(submissions, pgstore.NewTransactor(db.pool)),
+				controller.MailOutbox(outboxStore))
+		}
+
+		mailController := controller.NewMail(deliverer, logger, mailOpts...)
 
 		router := rest.DefaultRouter(c.ServiceName, nil, logger)
+		if c.TrustProxy {
+			router.Use(middleware.RealIP)
+		}
+		router.Use(arcmw.Language(catalog))
+		router.Use(arcmw.StoreSession)
+		router.Use(arcmw.Tenant(tenantResolvers(c, jwtAuth)...))
 		router.Post("/auth", authController.Login)
-		router.Post("/mail", mailController.Send)
+		router.Group(func(r chi.Router) {
+			if c.Spam.RateLimit > 0 {
+				r.Use(arcmw.RateLimit(c.Spam.RateLimit, c.Spam.RateWindow))
+			}
+
+			r.Post("/mail", mailController.Send)
+		})
+
+		if c.Spam.Secret != "" {
+			router.Get("/mail/token", mailController.Token)
+		}
+
+		if db.pool != nil && c.Mailgun.WebhookSigningKey != "" {
+			webhookController := controller.NewMailgunWebhook(c.Mailgun.WebhookSigningKey,
+				pgstore.NewMailEvents(db.pool), pgstore.NewSuppressions(db.pool), logger)
+			router.Post("/webhooks/mailgun", webhookController.Receive)
+		}
 
 		router.Group(func(r chi.Router) {
 			r.Use(jwtauth.Verifier(jwtAuth))
 			r.Use(jwtauth.Authenticator)
+			r.Use(arcmw.TenantToken)
 
 			r.Get("/auth", authController.User)
+			r.Patch("/auth", authController.Update)
 			r.Delete("/auth", authController.Logout)
+
+			if db.pool != nil {
+				submissionsController := controller.NewSubmissions(submissions, pgstore.NewTransactor(db.pool),
+					deliverer, logger)
+
+				r.Route("/admin/submissions", func(r chi.Router) {
+					r.Use(arcmw.Admin(users))
+					r.Get("/", submissionsController.List)
+					r.Get("/{id}", submissionsController.Get)
+					r.Put("/{id}/handled", submissionsController.Handled)
+					r.Delete("/{id}/handled", submissionsController.Unhandled)
+					r.Post("/{id}/resend", submissionsController.Resend)
+				})
+			}
 		})
 
 		// router.Get("/heavy", func(w http.ResponseWriter, r *http.Request) {
@@ -106,8 +222,47 @@ func main() { //nolint:funlen
 		// })
 
 		app := rest.NewServer(c.ServiceName, c.ServerPort, c.MetricsPort, router, logger)
+
+		if db.replicas != nil {
+			app.AddWorker("replicas health check", db.replicas)
+		}
+
+		if db.pool != nil {
+			app.AddWorker("job pool", jobPool)
+			app.AddWorker("outbox relay", relay)
+
+			scheduler := schedule.NewScheduler(c.ServiceName, logger,
+				schedule.SchedulerLocker(pgstore.NewLocker(db.pool)))
+			prometheus.MustRegister(scheduler)
+
+			if err := addHousekeeping(scheduler, db.pool); err != nil {
+				exit("schedule", err)
+			}
+
+			app.AddWorker("scheduler", scheduler)
+		}
+
 		app.Run()
 
+	case "migrate":
+		migrateCmd := act.New("migrate", act.WithUsage("arc"))
+
+		if err := migrateCmd.Parse(c, os.Args[2:]); err != nil {
+			exit("parse arguments", err)
+		}
+
+		ctx := context.Background()
+
+		db, err := openDatabase(ctx, c.DB)
+		if err != nil {
+			exit("open database", err)
+		}
+		defer db.close()
+
+		if err := db.migrate(ctx); err != nil {
+			exit("migrate", err)
+		}
+
 	case "user":
 		config := &struct{}{}
 		userCmd := act.New("user", act.WithUsage("arc"))
@@ -140,12 +295,59 @@ func main() { //nolint:funlen
 	}
 }
 
+// addHousekeeping schedules daily deletion of data kept only for a while.
+func addHousekeeping(s *schedule.Scheduler, pool *pgxpool.Pool) error {
+	const day = 24 * time.Hour
+
+	purges := []struct {
+		name      string
+		purge     func(ctx context.Context, before time.Time) error
+		retention time.Duration
+	}{
+		{name: "purge outbox", purge: pgstore.NewOutbox(pool).Purge, retention: 7 * day},
+		{name: "purge dead jobs", purge: pgstore.NewJobs(pool).Purge, retention: 30 * day},
+		{name: "purge mail events", purge: pgstore.NewMailEvents(pool).Purge, retention: 90 * day},
+	}
+
+	for _, p := range purges {
+		p := p
+
+		err := s.Add(p.name, "@daily", func(ctx context.Context) error {
+			return p.purge(ctx, time.Now().Add(-p.retention))
+		})
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+func tenantResolvers(c *config, jwtAuth *jwtauth.JWTAuth) []tenant.Resolver {
+	var resolvers []tenant.Resolver
+
+	if c.Tenant.Header != "" {
+		resolvers = append(resolvers, tenant.FromHeader(c.Tenant.Header))
+	}
+
+	if c.Tenant.Domain != "" {
+		resolvers = append(resolvers, tenant.FromSubdomain(c.Tenant.Domain))
+	}
+
+	if c.Tenant.Token {
+		resolvers = append(resolvers, tenant.FromToken(jwtAuth))
+	}
+
+	return resolvers
+}
+
 func usage() {
 	usage := `Usage of arc:
   arc <command>
 
   Available commands:
     serve	start rest server
+    migrate	apply database migrations
     user	create new user`
 
 	fmt.Println(usage) //nolint:forbidigo
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acim/arc/pkg/controller"
+	"github.com/acim/arc/pkg/spam"
+)
+
+var errUnknownCaptcha = errors.New("unknown captcha provider")
+
+type spamConfig struct {
+	// Secret signs form tokens. Tokens are not required if it is empty.
+	Secret string
+	// MinFillTime is minimal time between fetching form token and submitting the form.
+	MinFillTime time.Duration `def:"3s"`
+	// MaxLinks is maximal number of links in a message, negative value allows any number.
+	MaxLinks int `def:"2"`
+	// Keywords is comma separated list of words rejected in messages.
+	Keywords string
+	// RateLimit is number of messages allowed per client IP address within RateWindow, zero disables
+	// rate limiting.
+	RateLimit  int           `def:"5"`
+	RateWindow time.Duration `def:"10m"`
+	// Captcha is either hcaptcha or turnstile. CAPTCHA is not required if it is empty.
+	Captcha string
+	// CaptchaURL overrides verification endpoint of the provider.
+	CaptchaURL    string
+	CaptchaSecret string
+}
+
+// mailOptions creates spam protection options of the mail controller.
+func mailOptions(c *spamConfig) ([]controller.MailOption, error) {
+	opts := []controller.MailOption{
+		controller.MailFilter(spam.NewFilter(
+			spam.FilterMaxLinks(c.MaxLinks),
+			spam.FilterKeywords(strings.Split(c.Keywords, ",")...))),
+	}
+
+	if c.Secret != "" {
+		opts = append(opts, controller.MailTokens(spam.NewTokens(c.Secret, spam.TokensMinAge(c.MinFillTime))))
+	}
+
+	if c.Captcha == "" {
+		return opts, nil
+	}
+
+	url := c.CaptchaURL
+
+	if url == "" {
+		switch c.Captcha {
+		case "hcaptcha":
+			url = spam.HCaptchaURL
+		case "turnstile":
+			url = spam.TurnstileURL
+		default:
+			return nil, fmt.Errorf("%w: %s", errUnknownCaptcha, c.Captcha)
+		}
+	}
+
+	return append(opts, controller.MailCaptcha(spam.NewHTTPVerifier(url, c.CaptchaSecret))), nil
+}
@@ -0,0 +1,95 @@
+package contact
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+
+	"github.com/acim/arc/pkg/mail"
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/outbox"
+	"github.com/acim/arc/pkg/store"
+	"go.uber.org/zap"
+	"golang.org/x/text/language"
+)
+
+// SubmittedTopic is the outbox topic of messages added when a submission is stored.
+const SubmittedTopic = "contact.submitted"
+
+// Submitted is the payload of SubmittedTopic messages.
+type Submitted struct {
+	ID string `json:"id"`
+}
+
+// Acknowledger sends visitors e-mail rendered from acknowledgement template confirming that their
+// submission has been received. The e-mail doesn't repeat the submission, so that the contact form can't be
+// used to send arbitrary content to third party addresses.
+type Acknowledger struct {
+	sender    mail.Sender
+	templates *mail.Templates
+	from      string
+	logger    *zap.Logger
+}
+
+// NewAcknowledger creates new acknowledger.
+func NewAcknowledger(sender mail.Sender, templates *mail.Templates, from string, logger *zap.Logger) *Acknowledger {
+	return &Acknowledger{
+		sender:    sender,
+		templates: templates,
+		from:      from,
+		logger:    logger,
+	}
+}
+
+// Acknowledge sends acknowledgement of the submission in its language.
+func (a *Acknowledger) Acknowledge(ctx context.Context, s *model.Submission) error {
+	msg := &mail.Mail{ //nolint:exhaustivestruct
+		From: a.from,
+		To:   []string{(&netmail.Address{Name: s.Name(), Address: s.Email}).String()},
+		Tags: []string{"contact-acknowledgement"},
+	}
+
+	if err := a.templates.Render(msg, "acknowledgement", language.Make(s.Language), &data{ //nolint:exhaustivestruct
+		Name: s.Name(),
+	}); err != nil {
+		return fmt.Errorf("acknowledge submission: %w", err)
+	}
+
+	if _, err := a.sender.Send(ctx, msg); err != nil {
+		return fmt.Errorf("acknowledge submission: %w", err)
+	}
+
+	return nil
+}
+
+// HandleSubmitted registers relay handler acknowledging stored submissions. Submissions deleted in the
+// meantime and e-mails which would never be sent are skipped instead of retried.
+func HandleSubmitted(relay *outbox.Relay, submissions store.Submissions, a *Acknowledger) {
+	relay.Handle(SubmittedTopic, func(ctx context.Context, msg *outbox.Message) error {
+		e := &Submitted{} //nolint:exhaustivestruct
+		if err := json.Unmarshal(msg.Payload, e); err != nil {
+			a.logger.Error("acknowledge submission", zap.Int64("message", msg.ID), zap.NamedError("decode", err))
+
+			return nil
+		}
+
+		s, err := submissions.FindByID(ctx, e.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("acknowledge submission: %w", err)
+		}
+
+		if err = a.Acknowledge(ctx, s); err != nil && permanent(err) {
+			a.logger.Warn("acknowledge submission", zap.String("id", s.ID), zap.Error(err))
+
+			return nil
+		}
+
+		return err
+	})
+}
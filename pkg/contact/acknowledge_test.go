@@ -0,0 +1,76 @@
+package contact_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/acim/arc/pkg/contact"
+	"github.com/acim/arc/pkg/controller"
+	"github.com/acim/arc/pkg/mail"
+	"github.com/acim/arc/pkg/model"
+	"go.uber.org/zap"
+)
+
+func TestAcknowledge(t *testing.T) {
+	t.Parallel()
+
+	templates, err := controller.MailTemplates()
+	if err != nil {
+		t.Fatalf("templates: %v", err)
+	}
+
+	tests := map[string]struct {
+		language string
+		subject  string
+		greeting string
+	}{
+		"english":  {language: "en", subject: "We have received your message", greeting: "Hello Jane Doe,"},
+		"german":   {language: "de-AT", subject: "Wir haben Ihre Nachricht erhalten", greeting: "Hallo Jane Doe,"},
+		"serbian":  {language: "sr", subject: "Primili smo vašu poruku", greeting: "Poštovani/a Jane Doe,"},
+		"fallback": {language: "fr", subject: "We have received your message", greeting: "Hello Jane Doe,"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			sender := mail.NewMemory()
+			a := contact.NewAcknowledger(sender, templates, "noreply@example.com", zap.NewNop())
+
+			err := a.Acknowledge(context.Background(), &model.Submission{ //nolint:exhaustivestruct
+				ID: "1", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Subject: "Buy now",
+				Text: "http://spam.example", Language: tc.language,
+			})
+			if err != nil {
+				t.Fatalf("acknowledge: %v", err)
+			}
+
+			m := sender.Last()
+			if m == nil {
+				t.Fatal("nothing sent")
+			}
+
+			if len(m.To) != 1 || m.To[0] != `"Jane Doe" <jane@example.com>` {
+				t.Errorf("to = %v, want visitor", m.To)
+			}
+
+			if m.Subject != tc.subject {
+				t.Errorf("subject = %q, want %q", m.Subject, tc.subject)
+			}
+
+			if !strings.HasPrefix(m.Text, tc.greeting) {
+				t.Errorf("text = %q, want greeting %q", m.Text, tc.greeting)
+			}
+
+			// Visitor supplied content must not be sent to the address given by the visitor.
+			for _, body := range []string{m.Subject, m.Text, m.HTML} {
+				if strings.Contains(body, "spam.example") || strings.Contains(body, "Buy now") {
+					t.Errorf("acknowledgement repeats submission: %q", body)
+				}
+			}
+		})
+	}
+}
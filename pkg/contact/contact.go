@@ -0,0 +1,119 @@
+// Package contact delivers contact form submissions to the site owner.
+package contact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+
+	"github.com/acim/arc/pkg/mail"
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+	"go.uber.org/zap"
+	"golang.org/x/text/language"
+)
+
+var _ Deliverer = (*Mailer)(nil)
+
+// Deliverer delivers submissions.
+type Deliverer interface {
+	Deliver(ctx context.Context, s *model.Submission) error
+}
+
+// Mailer implements Deliverer interface by sending e-mail rendered from contact template in the language
+// of the submission. E-mails are sent from the sender address, which should belong to a domain authorized
+// to send them, with Reply-To set to the visitor's address.
+type Mailer struct {
+	sender      mail.Sender
+	templates   *mail.Templates
+	from        string
+	to          string
+	submissions store.Submissions
+	logger      *zap.Logger
+}
+
+// NewMailer creates new mailer.
+func NewMailer(sender mail.Sender, templates *mail.Templates, from, recipient string, logger *zap.Logger,
+	opts ...MailerOption,
+) *Mailer {
+	m := &Mailer{
+		sender:      sender,
+		templates:   templates,
+		from:        from,
+		to:          recipient,
+		submissions: nil,
+		logger:      logger,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Deliver implements Deliverer interface. Outcome is recorded in the submission and stored, if mailer
+// has a store. Failing to store it doesn't fail the delivery, since e-mail would be sent again on retry.
+func (m *Mailer) Deliver(ctx context.Context, s *model.Submission) error {
+	msg := &mail.Mail{ //nolint:exhaustivestruct
+		From:    m.from,
+		ReplyTo: (&netmail.Address{Name: s.Name(), Address: s.Email}).String(),
+		To:      []string{m.to},
+		Tags:    []string{"contact"},
+	}
+
+	err := m.templates.Render(msg, "contact", language.Make(s.Language), &data{
+		Name:    s.Name(),
+		Company: s.Company,
+		Email:   s.Email,
+		Subject: s.Subject,
+		Text:    s.Text,
+	})
+	if err != nil {
+		return fmt.Errorf("deliver submission: %w", err)
+	}
+
+	res, err := m.sender.Send(ctx, msg)
+	if err != nil {
+		s.Status, s.Error = model.SubmissionFailed, err.Error()
+	} else {
+		s.Status, s.Error, s.MessageID, s.Provider = model.SubmissionSent, "", res.ID, res.Provider
+	}
+
+	if m.submissions != nil {
+		if serr := m.submissions.UpdateDelivery(ctx, s); serr != nil {
+			m.logger.Error("deliver submission", zap.String("id", s.ID), zap.NamedError("update", serr))
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("deliver submission: %w", err)
+	}
+
+	return nil
+}
+
+// MailerOption ...
+type MailerOption func(*Mailer)
+
+// MailerSubmissions stores outcome of deliveries.
+func MailerSubmissions(s store.Submissions) MailerOption {
+	return func(m *Mailer) {
+		m.submissions = s
+	}
+}
+
+// data is passed to contact form e-mail templates.
+type data struct {
+	Name    string
+	Company string
+	Email   string
+	Subject string
+	Text    string
+}
+
+// permanent reports whether delivery should not be retried.
+func permanent(err error) bool {
+	return errors.Is(err, mail.ErrPermanent) || errors.Is(err, mail.ErrTemplateNotFound)
+}
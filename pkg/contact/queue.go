@@ -0,0 +1,62 @@
+package contact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/acim/arc/pkg/job"
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+)
+
+var _ Deliverer = (*Queued)(nil)
+
+// JobKind is the kind of jobs delivering submissions.
+const JobKind = "contact.deliver"
+
+// Queued implements Deliverer interface by enqueueing stored submissions to be delivered in background by
+// a job pool, where handler registered by Handle delivers them.
+type Queued struct {
+	queue *job.Queue
+}
+
+// NewQueued creates new queued deliverer.
+func NewQueued(queue *job.Queue) *Queued {
+	return &Queued{
+		queue: queue,
+	}
+}
+
+// Deliver implements Deliverer interface. Submission has to be stored already.
+func (q *Queued) Deliver(ctx context.Context, s *model.Submission) error {
+	if _, err := q.queue.Enqueue(ctx, JobKind, &queuedSubmission{ID: s.ID}); err != nil {
+		return fmt.Errorf("queue submission: %w", err)
+	}
+
+	return nil
+}
+
+// Handle registers handler delivering queued submissions found in store using mailer.
+func Handle(pool *job.Pool, submissions store.Submissions, mailer *Mailer) {
+	job.Register(pool, JobKind, func(ctx context.Context, qs *queuedSubmission) error {
+		s, err := submissions.FindByID(ctx, qs.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			return job.Permanent(err)
+		}
+
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err = mailer.Deliver(ctx, s); err != nil && permanent(err) {
+			return job.Permanent(err)
+		}
+
+		return err
+	})
+}
+
+type queuedSubmission struct {
+	ID string `json:"id"`
+}
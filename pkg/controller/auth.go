@@ -8,8 +8,11 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/acim/arc/pkg/i18n"
 	arcmw "github.com/acim/arc/pkg/middleware"
+	"github.com/acim/arc/pkg/model"
 	"github.com/acim/arc/pkg/store"
+	"github.com/acim/arc/pkg/tenant"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/golang-jwt/jwt"
@@ -51,13 +54,14 @@ func NewAuth(users store.Users, jwtauth *jwtauth.JWTAuth, logger *zap.Logger, op
 // Login handles /auth/login endpoint.
 func (c *Auth) Login(w http.ResponseWriter, r *http.Request) {
 	res := arcmw.ResponseFromContext(r.Context())
+	loc := i18n.FromContext(r.Context())
 
 	l := &login{} //nolint:exhaustivestruct
 
 	err := json.NewDecoder(r.Body).Decode(l)
 	if err != nil {
 		c.logger.Warn("login", zap.NamedError("json decode", err))
-		res.SetStatusBadRequest(errParsingRequestBody)
+		res.SetStatusBadRequest(loc.T(errParsingRequestBody))
 
 		return
 	}
@@ -65,7 +69,7 @@ func (c *Auth) Login(w http.ResponseWriter, r *http.Request) {
 	u, err := c.users.FindByEmail(r.Context(), l.Email)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
-			res.SetStatusForbidden(errInvalidCredentials)
+			res.SetStatusForbidden(loc.T(errInvalidCredentials))
 
 			return
 		}
@@ -77,12 +81,12 @@ func (c *Auth) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !u.IsValidPassword(l.Password) {
-		res.SetStatusForbidden(errInvalidCredentials)
+		res.SetStatusForbidden(loc.T(errInvalidCredentials))
 
 		return
 	}
 
-	authToken, err := c.token(c.authTokenExpiration, middleware.GetReqID(r.Context()), u.ID)
+	authToken, err := c.token(c.authTokenExpiration, middleware.GetReqID(r.Context()), u)
 	if err != nil {
 		c.logger.Warn("login", zap.NamedError("auth token", err))
 		res.SetStatusInternalServerError("")
@@ -90,7 +94,7 @@ func (c *Auth) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	refreshToken, err := c.token(c.refreshTokenExpiration, middleware.GetReqID(r.Context()), u.ID)
+	refreshToken, err := c.token(c.refreshTokenExpiration, middleware.GetReqID(r.Context()), u)
 	if err != nil {
 		c.logger.Warn("login", zap.NamedError("refresh token", err))
 		res.SetStatusInternalServerError("")
@@ -104,6 +108,7 @@ func (c *Auth) Login(w http.ResponseWriter, r *http.Request) {
 // User handles /auth/user endpoint.
 func (c *Auth) User(w http.ResponseWriter, r *http.Request) {
 	res := arcmw.ResponseFromContext(r.Context())
+	loc := i18n.FromContext(r.Context())
 
 	userID, err := getUserID(r.Context())
 	if err != nil {
@@ -116,7 +121,56 @@ func (c *Auth) User(w http.ResponseWriter, r *http.Request) {
 	user, err := c.users.FindByID(r.Context(), userID)
 	if err != nil {
 		c.logger.Warn("user", zap.NamedError("find by id", err))
-		res.SetStatusNotFound(fmt.Sprintf("user %s not found", userID))
+		setStoreError(res, loc, err)
+
+		return
+	}
+
+	user.Password = ""
+	res.SetPayload(user)
+}
+
+// Update handles PATCH /auth endpoint. Request body is a JSON merge patch (RFC 7396) of user's profile,
+// null values reset fields to their defaults.
+func (c *Auth) Update(w http.ResponseWriter, r *http.Request) {
+	res := arcmw.ResponseFromContext(r.Context())
+	loc := i18n.FromContext(r.Context())
+
+	userID, err := getUserID(r.Context())
+	if err != nil {
+		c.logger.Warn("update", zap.NamedError("get user id", err))
+		res.SetStatusInternalServerError(err.Error())
+
+		return
+	}
+
+	patch := map[string]*string{}
+
+	if err = json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		c.logger.Warn("update", zap.NamedError("json decode", err))
+		res.SetStatusBadRequest(loc.T(errParsingRequestBody))
+
+		return
+	}
+
+	user, err := c.users.FindByID(r.Context(), userID)
+	if err != nil {
+		c.logger.Warn("update", zap.NamedError("find by id", err))
+		setStoreError(res, loc, err)
+
+		return
+	}
+
+	if err = applyProfilePatch(&user.Profile, patch); err != nil {
+		c.logger.Warn("update", zap.NamedError("apply patch", err))
+		res.SetStatusUnprocessableEntity(errorMessage(loc, err))
+
+		return
+	}
+
+	if err = c.users.Update(r.Context(), user); err != nil {
+		c.logger.Warn("update", zap.NamedError("update", err))
+		setStoreError(res, loc, err)
 
 		return
 	}
@@ -141,12 +195,13 @@ func (c *Auth) Logout(w http.ResponseWriter, r *http.Request) {
 	c.logger.Info("logout", zap.String("user id", userID))
 }
 
-func (c *Auth) token(expiration time.Duration, requestID, userID string) (string, error) {
+func (c *Auth) token(expiration time.Duration, requestID string, user *model.User) (string, error) {
 	_, token, err := c.jwtauth.Encode(jwt.MapClaims{
-		"exp": time.Now().Add(expiration).Unix(),
-		"iat": time.Now().Unix(),
-		"jti": requestID,
-		"sub": userID,
+		"exp":        time.Now().Add(expiration).Unix(),
+		"iat":        time.Now().Unix(),
+		"jti":        requestID,
+		"sub":        user.ID,
+		tenant.Claim: user.TenantID,
 	})
 	if err != nil {
 		return "", fmt.Errorf("encode token: %w", err)
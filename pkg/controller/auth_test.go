@@ -0,0 +1,225 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/acim/arc/pkg/controller"
+	"github.com/acim/arc/pkg/middleware"
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+	"github.com/go-chi/jwtauth/v5"
+	"go.uber.org/zap"
+)
+
+// fakeUsers keeps a single user in memory and updates it only if the version matches the stored one.
+type fakeUsers struct {
+	store.Users
+	mu   sync.Mutex
+	user model.User
+	// concurrent is applied to the stored user after it has been found, like an update of another request.
+	concurrent func(u *model.User)
+}
+
+func (s *fakeUsers) FindByID(_ context.Context, id string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id != s.user.ID {
+		return nil, store.ErrNotFound
+	}
+
+	u := s.user
+
+	if s.concurrent != nil {
+		s.concurrent(&s.user)
+	}
+
+	return &u, nil
+}
+
+func (s *fakeUsers) Update(_ context.Context, u *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u.Version != s.user.Version {
+		return store.ErrConflict
+	}
+
+	u.Version++
+	s.user = *u
+
+	return nil
+}
+
+func newFakeUsers() *fakeUsers {
+	return &fakeUsers{ //nolint:exhaustivestruct
+		user: model.User{ //nolint:exhaustivestruct
+			ID:       "1",
+			Email:    "jane@example.com",
+			Password: "hash",
+			Profile: model.Profile{
+				DisplayName: "Jane",
+				Locale:      "de-AT",
+				Timezone:    "Europe/Vienna",
+				AvatarURL:   "https://example.com/jane.png",
+			},
+			Version: 1,
+		},
+	}
+}
+
+type updateResponse struct {
+	status int
+	user   *model.User
+	errors []string
+}
+
+// updateProfile patches profile of the user with the body in language negotiated from Accept-Language header.
+func updateProfile(t *testing.T, users *fakeUsers, acceptLanguage, body string) updateResponse {
+	t.Helper()
+
+	catalog, err := controller.Catalog()
+	if err != nil {
+		t.Fatalf("catalog: %v", err)
+	}
+
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+
+	_, token, err := auth.Encode(map[string]interface{}{"sub": users.user.ID})
+	if err != nil {
+		t.Fatalf("encode token: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPatch, "/auth", strings.NewReader(body))
+	r.Header.Set("Accept-Language", acceptLanguage)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	c := controller.NewAuth(users, auth, zap.NewNop())
+	rec := httptest.NewRecorder()
+	middleware.Language(catalog)(middleware.RenderJSON(jwtauth.Verifier(auth)(http.HandlerFunc(c.Update)))).
+		ServeHTTP(rec, r)
+
+	res := &struct {
+		Data   *model.User `json:"data"`
+		Errors []string    `json:"errors"`
+	}{}
+
+	if rec.Body.Len() > 0 {
+		if err = json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+			t.Fatalf("unmarshal %s: %v", rec.Body, err)
+		}
+	}
+
+	return updateResponse{status: rec.Code, user: res.Data, errors: res.Errors}
+}
+
+func TestAuthUpdate(t *testing.T) {
+	t.Parallel()
+
+	users := newFakeUsers()
+
+	res := updateProfile(t, users, "en", `{"displayName": "Jane Doe", "locale": "sr-latn-rs", "timezone": null}`)
+	if res.status != http.StatusOK {
+		t.Fatalf("status = %d, errors %v, want %d", res.status, res.errors, http.StatusOK)
+	}
+
+	want := model.Profile{
+		DisplayName: "Jane Doe",
+		Locale:      "sr-Latn-RS",
+		Timezone:    "",
+		AvatarURL:   "https://example.com/jane.png",
+	}
+
+	if res.user == nil || res.user.Profile != want || res.user.Version != 2 || res.user.Password != "" {
+		t.Errorf("response user = %+v, want profile %+v, version 2 and no password", res.user, want)
+	}
+
+	if users.user.Profile != want || users.user.Password != "hash" {
+		t.Errorf("stored user = %+v, want profile %+v and unchanged password", users.user, want)
+	}
+}
+
+func TestAuthUpdateRejects(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		body           string
+		acceptLanguage string
+		status         int
+		error          string
+	}{
+		"invalid timezone": {
+			body: `{"timezone": "Mars/Olympus_Mons"}`, acceptLanguage: "en",
+			status: http.StatusUnprocessableEntity, error: `Invalid timezone "Mars/Olympus_Mons"`,
+		},
+		"local timezone": {
+			body: `{"timezone": "Local"}`, acceptLanguage: "de",
+			status: http.StatusUnprocessableEntity, error: `Ungültige Zeitzone "Local"`,
+		},
+		"invalid locale": {
+			body: `{"locale": "not a locale"}`, acceptLanguage: "en",
+			status: http.StatusUnprocessableEntity, error: `Invalid locale "not a locale"`,
+		},
+		"unknown field": {
+			body: `{"displayName": "Jane Doe", "email": "john@example.com"}`, acceptLanguage: "sr",
+			status: http.StatusUnprocessableEntity, error: `Nepoznato polje "email"`,
+		},
+		"non-string value": {
+			body: `{"displayName": 1}`, acceptLanguage: "en",
+			status: http.StatusBadRequest,
+		},
+		"not an object": {
+			body: `["displayName"]`, acceptLanguage: "en",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			users := newFakeUsers()
+			stored := users.user
+
+			res := updateProfile(t, users, tc.acceptLanguage, tc.body)
+			if res.status != tc.status {
+				t.Fatalf("status = %d, want %d", res.status, tc.status)
+			}
+
+			if tc.error != "" && (len(res.errors) != 1 || res.errors[0] != tc.error) {
+				t.Errorf("errors = %q, want %q", res.errors, tc.error)
+			}
+
+			if users.user != stored {
+				t.Errorf("stored user = %+v, want unchanged %+v", users.user, stored)
+			}
+		})
+	}
+}
+
+func TestAuthUpdateStaleVersion(t *testing.T) {
+	t.Parallel()
+
+	users := newFakeUsers()
+	users.concurrent = func(u *model.User) {
+		u.DisplayName = "Jane Roe"
+		u.Version++
+	}
+
+	res := updateProfile(t, users, "en", `{"displayName": "Jane Doe"}`)
+	if res.status != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", res.status, http.StatusConflict)
+	}
+
+	if users.user.DisplayName != "Jane Roe" || users.user.Version != 2 {
+		t.Errorf("stored user = %+v, want the concurrent update", users.user)
+	}
+}
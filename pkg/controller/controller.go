@@ -1,7 +1,102 @@
 // Package controller contains http handlers.
 package controller
 
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/acim/arc/pkg/i18n"
+	arcmw "github.com/acim/arc/pkg/middleware"
+	"github.com/acim/arc/pkg/store"
+)
+
+// Error messages are English texts used as keys of the message catalog.
 const (
 	errParsingRequestBody = "Error parsing request body"
 	errInvalidCredentials = "Invalid credentials" //nolint:gosec
+	errNotFound           = "Resource not found"
+	errConflict           = "Resource already exists or has been modified"
+	errConstraint         = "Resource violates data constraints"
+	errSendingMail        = "Error sending e-mail"
 )
+
+//go:embed locales
+var locales embed.FS
+
+// Catalog loads embedded translations of messages returned by controllers and the router.
+func Catalog() (*i18n.Catalog, error) {
+	sub, err := fs.Sub(locales, "locales")
+	if err != nil {
+		return nil, fmt.Errorf("catalog: %w", err)
+	}
+
+	c, err := i18n.NewCatalog(sub)
+	if err != nil {
+		return nil, fmt.Errorf("catalog: %w", err)
+	}
+
+	return c, nil
+}
+
+// setStoreError sets response status code matching the store error. Errors not known to the store
+// result in http.StatusInternalServerError.
+func setStoreError(res *arcmw.Response, l *i18n.Localizer, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		res.SetStatusNotFound(l.T(errNotFound))
+	case errors.Is(err, store.ErrConflict):
+		res.SetStatusConflict(l.T(errConflict))
+	case errors.Is(err, store.ErrConstraint):
+		res.SetStatusUnprocessableEntity(l.T(errConstraint))
+	default:
+		res.SetStatusInternalServerError("")
+	}
+}
+
+// validationError describes invalid input by a message which can be translated.
+type validationError struct {
+	err    error
+	msg    string
+	n      int
+	plural bool
+	args   []interface{}
+}
+
+// invalid returns validation error wrapping err and described by message formatted with args.
+func invalid(err error, msg string, args ...interface{}) error {
+	return &validationError{err: err, msg: msg, n: 0, plural: false, args: args}
+}
+
+// invalidN is like invalid, but message has plural forms selected by n.
+func invalidN(err error, msg string, n int, args ...interface{}) error {
+	return &validationError{err: err, msg: msg, n: n, plural: true, args: args}
+}
+
+func (e *validationError) Error() string {
+	return e.localize(&i18n.Localizer{}) //nolint:exhaustivestruct
+}
+
+func (e *validationError) Unwrap() error {
+	return e.err
+}
+
+func (e *validationError) localize(l *i18n.Localizer) string {
+	if e.plural {
+		return l.N(e.msg, e.n, e.args...)
+	}
+
+	return l.T(e.msg, e.args...)
+}
+
+// errorMessage returns translated message of validation error or message of any other error.
+func errorMessage(l *i18n.Localizer, err error) string {
+	var ve *validationError
+
+	if errors.As(err, &ve) {
+		return ve.localize(l)
+	}
+
+	return firstToUpper(err.Error())
+}
@@ -1,14 +1,27 @@
 package controller
 
 import (
+	"context"
+	"embed"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/acim/arc/pkg/contact"
+	"github.com/acim/arc/pkg/i18n"
 	"github.com/acim/arc/pkg/mail"
 	"github.com/acim/arc/pkg/middleware"
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/outbox"
+	"github.com/acim/arc/pkg/spam"
+	"github.com/acim/arc/pkg/store"
 	"github.com/asaskevich/govalidator"
 	"go.uber.org/zap"
 )
@@ -16,52 +29,168 @@ import (
 // ErrInvalidValue is returned when a value is not defined or has wrong value.
 var ErrInvalidValue = errors.New("invalid value")
 
+// errHoneypot is returned when the hidden form field, which people do not see, is filled.
+var errHoneypot = errors.New("honeypot field filled")
+
+//go:embed templates
+var templates embed.FS
+
+// MailTemplates parses embedded templates of e-mails sent by controllers.
+func MailTemplates() (*mail.Templates, error) {
+	sub, err := fs.Sub(templates, "templates")
+	if err != nil {
+		return nil, fmt.Errorf("mail templates: %w", err)
+	}
+
+	t, err := mail.NewTemplates(sub)
+	if err != nil {
+		return nil, fmt.Errorf("mail templates: %w", err)
+	}
+
+	return t, nil
+}
+
 // Mail controller.
 type Mail struct {
-	mail   mail.Sender
-	to     string
-	logger *zap.Logger
+	deliverer   contact.Deliverer
+	submissions store.Submissions
+	transactor  store.Transactor
+	outbox      outbox.Writer
+	tokens      *spam.Tokens
+	filter      *spam.Filter
+	captcha     spam.Verifier
+	logger      *zap.Logger
 }
 
 // NewMail creates new mail controller.
-func NewMail(sender mail.Sender, recipient string, logger *zap.Logger) *Mail {
-	return &Mail{
-		mail:   sender,
-		to:     recipient,
-		logger: logger,
+func NewMail(deliverer contact.Deliverer, logger *zap.Logger, opts ...MailOption) *Mail {
+	c := &Mail{
+		deliverer:   deliverer,
+		submissions: nil,
+		transactor:  nil,
+		outbox:      nil,
+		tokens:      nil,
+		filter:      nil,
+		captcha:     nil,
+		logger:      logger,
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Token handles GET /mail/token endpoint. Contact form should fetch the token when rendered and submit
+// it with the message.
+func (c *Mail) Token(w http.ResponseWriter, r *http.Request) {
+	res := middleware.ResponseFromContext(r.Context())
+
+	if c.tokens == nil {
+		res.SetStatusNotFound(i18n.FromContext(r.Context()).T(http.StatusText(http.StatusNotFound)))
+
+		return
+	}
+
+	res.SetHeader("Cache-Control", "no-store").SetPayload(&formToken{Token: c.tokens.Issue(time.Now())})
 }
 
-// Send sends e-mail from contact form.
+// Send handles POST /mail endpoint. Submission of the contact form is stored, if controller has a store,
+// and delivered in the same transaction.
 func (c *Mail) Send(w http.ResponseWriter, r *http.Request) {
 	res := middleware.ResponseFromContext(r.Context())
+	loc := i18n.FromContext(r.Context())
 
 	mr := &mailReq{} //nolint:exhaustivestruct
 
 	err := json.NewDecoder(r.Body).Decode(mr)
 	if err != nil {
 		c.logger.Warn("send", zap.NamedError("json decode", err))
-		res.SetStatusBadRequest(errParsingRequestBody)
+		res.SetStatusBadRequest(loc.T(errParsingRequestBody))
 
 		return
 	}
 
 	if err = mr.validate(); err != nil {
 		c.logger.Warn("send", zap.NamedError("validate", err))
-		res.SetStatusBadRequest(firstToUpper(err.Error()))
+		res.SetStatusBadRequest(errorMessage(loc, err))
 
 		return
 	}
 
-	mres, err := c.mail.Send(r.Context(), &mail.Mail{
-		From:    fmt.Sprintf("%s %s %s <%s>", mr.FirstName, mr.LastName, mr.Company, mr.From),
-		Subject: mr.Subject,
-		Text:    mr.Text,
-		To:      []string{c.to},
+	if err = c.checkSpam(r, mr); err != nil {
+		var ve *validationError
+
+		switch {
+		case errors.Is(err, errHoneypot), errors.Is(err, spam.ErrTooFast):
+			// Bots are not told they have been detected.
+			c.logger.Info("send", zap.NamedError("spam", err))
+			res.SetStatusAccepted()
+		case errors.As(err, &ve):
+			c.logger.Info("send", zap.NamedError("spam", err))
+			res.SetStatusBadRequest(errorMessage(loc, err))
+		default:
+			c.logger.Error("send", zap.NamedError("spam", err))
+			res.SetStatusInternalServerError(loc.T(errSendingMail))
+		}
+
+		return
+	}
+
+	sub, err := model.NewSubmission()
+	if err != nil {
+		c.logger.Error("send", zap.NamedError("new submission", err))
+		res.SetStatusInternalServerError(loc.T(errSendingMail))
+
+		return
+	}
+
+	sub.FirstName = mr.FirstName
+	sub.LastName = mr.LastName
+	sub.Company = mr.Company
+	sub.Email = mr.From
+	sub.Subject = mr.Subject
+	sub.Text = mr.Text
+	sub.Language = loc.Language().String()
+	sub.IP = clientIP(r)
+	sub.UserAgent = r.UserAgent()
+
+	if c.submissions == nil {
+		if err = c.deliverer.Deliver(r.Context(), sub); err != nil {
+			c.logger.Error("send", zap.String("submission", sub.ID), zap.Error(err))
+			res.SetStatusInternalServerError(loc.T(errSendingMail))
+
+			return
+		}
+
+		res.SetStatusAccepted()
+
+		return
+	}
+
+	// Submission is stored and queued for delivery atomically, so that it is never stored without being
+	// delivered nor delivered without being stored.
+	err = c.transactor.WithTx(r.Context(), func(ctx context.Context) error {
+		if err := c.submissions.Insert(ctx, sub); err != nil {
+			return fmt.Errorf("insert submission: %w", err)
+		}
+
+		if c.outbox != nil {
+			if err := c.outbox.Add(ctx, contact.SubmittedTopic, &contact.Submitted{ID: sub.ID}); err != nil {
+				return fmt.Errorf("outbox: %w", err)
+			}
+		}
+
+		if err := c.deliverer.Deliver(ctx, sub); err != nil {
+			return fmt.Errorf("deliver: %w", err)
+		}
+
+		return nil
 	})
 	if err != nil {
-		c.logger.Error("send", zap.Error(err), zap.String("response", mres.Message), zap.String("mailgun id", mres.ID))
-		res.SetStatusInternalServerError("Error sending e-mail")
+		c.logger.Error("send", zap.String("submission", sub.ID), zap.Error(err))
+		res.SetStatusInternalServerError(loc.T(errSendingMail))
 
 		return
 	}
@@ -69,6 +198,87 @@ func (c *Mail) Send(w http.ResponseWriter, r *http.Request) {
 	res.SetStatusAccepted()
 }
 
+// checkSpam returns an error if the submission looks automated. Checks are ordered by their cost, so
+// that the CAPTCHA service is called only for submissions passing the rest.
+func (c *Mail) checkSpam(r *http.Request, mr *mailReq) error {
+	if mr.Website != "" {
+		return errHoneypot
+	}
+
+	if c.tokens != nil {
+		if err := c.tokens.Verify(mr.Token, time.Now()); err != nil {
+			if errors.Is(err, spam.ErrTooFast) {
+				return err //nolint:wrapcheck
+			}
+
+			return invalid(err, "Form has expired, please reload the page and try again")
+		}
+	}
+
+	if c.filter != nil {
+		err := c.filter.Check(mr.FirstName, mr.LastName, mr.Company, mr.Subject, mr.Text)
+
+		switch {
+		case errors.Is(err, spam.ErrTooManyLinks):
+			return invalidN(err, "Message may contain at most %d links", c.filter.MaxLinks(), c.filter.MaxLinks())
+		case err != nil:
+			return invalid(err, "Message looks like spam")
+		}
+	}
+
+	if c.captcha != nil {
+		if err := c.captcha.Verify(r.Context(), mr.Captcha, clientIP(r)); err != nil {
+			if errors.Is(err, spam.ErrCaptchaFailed) {
+				return invalid(err, "CAPTCHA verification failed")
+			}
+
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+// MailOption ...
+type MailOption func(*Mail)
+
+// MailSubmissions stores submissions and hands them to the deliverer in the same transaction.
+func MailSubmissions(s store.Submissions, t store.Transactor) MailOption {
+	return func(c *Mail) {
+		c.submissions = s
+		c.transactor = t
+	}
+}
+
+// MailOutbox adds contact.SubmittedTopic message to the outbox in the transaction storing submission. It
+// requires MailSubmissions option.
+func MailOutbox(w outbox.Writer) MailOption {
+	return func(c *Mail) {
+		c.outbox = w
+	}
+}
+
+// MailTokens requires submissions to carry a token issued by Token endpoint.
+func MailTokens(t *spam.Tokens) MailOption {
+	return func(c *Mail) {
+		c.tokens = t
+	}
+}
+
+// MailFilter rejects submissions whose content does not pass the filter.
+func MailFilter(f *spam.Filter) MailOption {
+	return func(c *Mail) {
+		c.filter = f
+	}
+}
+
+// MailCaptcha requires submissions to carry a CAPTCHA response accepted by the verifier.
+func MailCaptcha(v spam.Verifier) MailOption {
+	return func(c *Mail) {
+		c.captcha = v
+	}
+}
+
 type mailReq struct {
 	FirstName string `json:"firstName,omitempty"`
 	LastName  string `json:"lastName,omitempty"`
@@ -76,21 +286,68 @@ type mailReq struct {
 	From      string `json:"from"`
 	Subject   string `json:"subject"`
 	Text      string `json:"text"`
+	// Website is a honeypot field hidden from people by the contact form.
+	Website string `json:"website,omitempty"`
+	Token   string `json:"token,omitempty"`
+	Captcha string `json:"captcha,omitempty"`
 }
 
+type formToken struct {
+	Token string `json:"token"`
+}
+
+// Maximal lengths of submission fields in characters, as limited by the database.
+const (
+	maxNameLength    = 255
+	maxEmailLength   = 254
+	maxSubjectLength = 998
+)
+
 // Validate input data.
 func (m *mailReq) validate() error {
 	if (m.FirstName == "" && m.LastName == "") || m.From == "" || m.Subject == "" || m.Text == "" {
-		return fmt.Errorf("name, e-mail, subject or message: %w", ErrInvalidValue)
+		return invalid(ErrInvalidValue, "Name, e-mail, subject or message is missing")
+	}
+
+	if utf8.RuneCountInString(m.From) > maxEmailLength || !govalidator.IsEmail(m.From) {
+		return invalid(ErrInvalidValue, "Invalid sender e-mail address")
+	}
+
+	for _, f := range []struct {
+		value string
+		max   int
+		msg   string
+	}{
+		{value: m.FirstName, max: maxNameLength, msg: "First name must be at most %d characters"},
+		{value: m.LastName, max: maxNameLength, msg: "Last name must be at most %d characters"},
+		{value: m.Company, max: maxNameLength, msg: "Company must be at most %d characters"},
+		{value: m.Subject, max: maxSubjectLength, msg: "Subject must be at most %d characters"},
+	} {
+		if utf8.RuneCountInString(f.value) > f.max {
+			return invalidN(ErrInvalidValue, f.msg, f.max, f.max)
+		}
 	}
 
-	if !govalidator.IsEmail(m.From) {
-		return fmt.Errorf("from address: %w", ErrInvalidValue)
+	// Fields other than text become e-mail headers, which can't span lines, and the database doesn't
+	// store NUL characters.
+	if strings.IndexFunc(m.FirstName+m.LastName+m.Company+m.Subject, unicode.IsControl) >= 0 ||
+		strings.ContainsRune(m.Text, 0) {
+		return invalid(ErrInvalidValue, "Message contains invalid characters")
 	}
 
 	return nil
 }
 
+// clientIP returns IP address of the client without port.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return ip
+}
+
 func firstToUpper(str string) string {
 	for i, v := range str {
 		return string(unicode.ToUpper(v)) + str[i+1:]
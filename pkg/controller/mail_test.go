@@ -0,0 +1,396 @@
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/contact"
+	"github.com/acim/arc/pkg/controller"
+	"github.com/acim/arc/pkg/middleware"
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/spam"
+	"go.uber.org/zap"
+)
+
+type fakeDeliverer struct {
+	mu          sync.Mutex
+	submissions []*model.Submission
+}
+
+func (d *fakeDeliverer) Deliver(_ context.Context, s *model.Submission) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.submissions = append(d.submissions, s)
+
+	return nil
+}
+
+func (d *fakeDeliverer) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.submissions)
+}
+
+// fakeVerifier accepts CAPTCHA response "valid" and fails verification if response is "unavailable".
+type fakeVerifier struct{}
+
+func (fakeVerifier) Verify(_ context.Context, response, _ string) error {
+	switch response {
+	case "valid":
+		return nil
+	case "unavailable":
+		return spam.ErrCaptchaUnavailable
+	default:
+		return spam.ErrCaptchaFailed
+	}
+}
+
+type mailResponse struct {
+	status int
+	errors []string
+}
+
+// sendMail posts request to the controller in language negotiated from Accept-Language header.
+func sendMail(t *testing.T, c *controller.Mail, acceptLanguage string, req map[string]string) mailResponse {
+	t.Helper()
+
+	catalog, err := controller.Catalog()
+	if err != nil {
+		t.Fatalf("catalog: %v", err)
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/mail", bytes.NewReader(b))
+	r.Header.Set("Accept-Language", acceptLanguage)
+
+	rec := httptest.NewRecorder()
+	middleware.Language(catalog)(middleware.RenderJSON(http.HandlerFunc(c.Send))).ServeHTTP(rec, r)
+
+	res := mailResponse{status: rec.Code} //nolint:exhaustivestruct
+
+	if rec.Body.Len() > 0 {
+		body := &struct {
+			Errors []string `json:"errors"`
+		}{}
+
+		if err = json.Unmarshal(rec.Body.Bytes(), body); err != nil {
+			t.Fatalf("unmarshal %s: %v", rec.Body, err)
+		}
+
+		res.errors = body.Errors
+	}
+
+	return res
+}
+
+func mailRequest(fields ...string) map[string]string {
+	req := map[string]string{
+		"firstName": "Jane",
+		"lastName":  "Doe",
+		"company":   "Example",
+		"from":      "jane@example.com",
+		"subject":   "Quote",
+		"text":      "Please send me a quote.",
+	}
+
+	for i := 0; i+1 < len(fields); i += 2 {
+		req[fields[i]] = fields[i+1]
+	}
+
+	return req
+}
+
+func TestMailSpam(t *testing.T) {
+	t.Parallel()
+
+	tokens := spam.NewTokens("secret", spam.TokensMinAge(3*time.Second), spam.TokensMaxAge(time.Hour))
+	valid := tokens.Issue(time.Now().Add(-time.Minute))
+
+	tests := map[string]struct {
+		req       map[string]string
+		status    int
+		error     string
+		delivered bool
+	}{
+		"valid": {
+			req:    mailRequest("token", valid, "captcha", "valid"),
+			status: http.StatusAccepted, delivered: true,
+		},
+		"honeypot": {
+			req:    mailRequest("token", valid, "captcha", "valid", "website", "http://spam.example"),
+			status: http.StatusAccepted,
+		},
+		"too fast": {
+			req:    mailRequest("token", tokens.Issue(time.Now()), "captcha", "valid"),
+			status: http.StatusAccepted,
+		},
+		"expired token": {
+			req:    mailRequest("token", tokens.Issue(time.Now().Add(-2*time.Hour)), "captcha", "valid"),
+			status: http.StatusBadRequest, error: "Form has expired, please reload the page and try again",
+		},
+		"missing token": {
+			req:    mailRequest("captcha", "valid"),
+			status: http.StatusBadRequest, error: "Form has expired, please reload the page and try again",
+		},
+		"too many links": {
+			req:    mailRequest("token", valid, "captcha", "valid", "text", "http://a.example http://b.example"),
+			status: http.StatusBadRequest, error: "Message may contain at most 1 link",
+		},
+		"keyword": {
+			req:    mailRequest("token", valid, "captcha", "valid", "subject", "Casino"),
+			status: http.StatusBadRequest, error: "Message looks like spam",
+		},
+		"captcha failed": {
+			req:    mailRequest("token", valid, "captcha", "invalid"),
+			status: http.StatusBadRequest, error: "CAPTCHA verification failed",
+		},
+		"captcha unavailable": {
+			req:    mailRequest("token", valid, "captcha", "unavailable"),
+			status: http.StatusInternalServerError, error: "Error sending e-mail",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d := &fakeDeliverer{} //nolint:exhaustivestruct
+			c := controller.NewMail(d, zap.NewNop(),
+				controller.MailTokens(tokens),
+				controller.MailFilter(spam.NewFilter(spam.FilterMaxLinks(1), spam.FilterKeywords("casino"))),
+				controller.MailCaptcha(fakeVerifier{}))
+
+			res := sendMail(t, c, "en", tc.req)
+
+			if res.status != tc.status {
+				t.Errorf("status = %d, want %d", res.status, tc.status)
+			}
+
+			if tc.error != "" && (len(res.errors) != 1 || res.errors[0] != tc.error) {
+				t.Errorf("errors = %v, want %q", res.errors, tc.error)
+			}
+
+			if delivered := d.count() == 1; delivered != tc.delivered {
+				t.Errorf("delivered = %t, want %t", delivered, tc.delivered)
+			}
+		})
+	}
+}
+
+func TestMailToken(t *testing.T) {
+	t.Parallel()
+
+	tokens := spam.NewTokens("secret", spam.TokensMinAge(0))
+	c := controller.NewMail(&fakeDeliverer{}, zap.NewNop(), controller.MailTokens(tokens)) //nolint:exhaustivestruct
+
+	rec := httptest.NewRecorder()
+	middleware.RenderJSON(http.HandlerFunc(c.Token)).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mail/token", nil))
+
+	body := &struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}{}
+
+	if err := json.NewDecoder(rec.Body).Decode(body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if err := tokens.Verify(body.Data.Token, time.Now()); err != nil {
+		t.Errorf("issued token %q: %v", body.Data.Token, err)
+	}
+
+	if !strings.Contains(rec.Header().Get("Cache-Control"), "no-store") {
+		t.Error("token response may be cached")
+	}
+}
+
+func TestMailValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		req            map[string]string
+		acceptLanguage string
+		status         int
+		error          string
+	}{
+		"longest fields": {
+			req: mailRequest("firstName", strings.Repeat("ä", 255), "lastName", strings.Repeat("b", 255),
+				"company", strings.Repeat("c", 255), "subject", strings.Repeat("d", 998)),
+			status: http.StatusAccepted,
+		},
+		"missing subject": {
+			req:    mailRequest("subject", ""),
+			status: http.StatusBadRequest, error: "Name, e-mail, subject or message is missing",
+		},
+		"invalid e-mail": {
+			req:    mailRequest("from", "jane"),
+			status: http.StatusBadRequest, error: "Invalid sender e-mail address",
+		},
+		"long e-mail": {
+			req:    mailRequest("from", strings.Repeat("a", 64)+"@"+strings.Repeat("b", 190)+".example"),
+			status: http.StatusBadRequest, error: "Invalid sender e-mail address",
+		},
+		"long first name": {
+			req:    mailRequest("firstName", strings.Repeat("ä", 256)),
+			status: http.StatusBadRequest, error: "First name must be at most 255 characters",
+		},
+		"long last name": {
+			req: mailRequest("lastName", strings.Repeat("b", 256)), acceptLanguage: "de",
+			status: http.StatusBadRequest, error: "Der Nachname darf höchstens 255 Zeichen lang sein",
+		},
+		"long company": {
+			req: mailRequest("company", strings.Repeat("c", 256)), acceptLanguage: "sr",
+			status: http.StatusBadRequest, error: "Naziv firme može imati najviše 255 znakova",
+		},
+		"long subject": {
+			req:    mailRequest("subject", strings.Repeat("d", 999)),
+			status: http.StatusBadRequest, error: "Subject must be at most 998 characters",
+		},
+		"multiline subject": {
+			req:    mailRequest("subject", "Quote\r\nBcc: victim@example.com"),
+			status: http.StatusBadRequest, error: "Message contains invalid characters",
+		},
+		"multiline text": {
+			req:    mailRequest("text", "Hello,\r\nplease send me a quote.\n\tThanks"),
+			status: http.StatusAccepted,
+		},
+		"nul in text": {
+			req: mailRequest("text", "Hello\x00"), acceptLanguage: "de",
+			status: http.StatusBadRequest, error: "Die Nachricht enthält ungültige Zeichen",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d := &fakeDeliverer{} //nolint:exhaustivestruct
+			res := sendMail(t, controller.NewMail(d, zap.NewNop()), tc.acceptLanguage, tc.req)
+
+			if res.status != tc.status {
+				t.Errorf("status = %d, want %d", res.status, tc.status)
+			}
+
+			if tc.error != "" && (len(res.errors) != 1 || res.errors[0] != tc.error) {
+				t.Errorf("errors = %v, want %q", res.errors, tc.error)
+			}
+
+			if delivered := d.count() == 1; delivered != (tc.status == http.StatusAccepted) {
+				t.Errorf("delivered = %t", delivered)
+			}
+		})
+	}
+}
+
+func TestMailSendStoresAndDeliversAtomically(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err    error
+		status int
+		stored int
+	}{
+		"delivered": {status: http.StatusAccepted, stored: 1},
+		"failed":    {err: errDeliver, status: http.StatusInternalServerError, stored: 0},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			subs := &fakeSubmissions{submissions: map[string]model.Submission{}} //nolint:exhaustivestruct
+			d := &statusDeliverer{submissions: subs, err: tc.err}                //nolint:exhaustivestruct
+			c := controller.NewMail(d, zap.NewNop(), controller.MailSubmissions(subs, subs))
+
+			if res := sendMail(t, c, "en", mailRequest()); res.status != tc.status {
+				t.Errorf("status = %d, want %d", res.status, tc.status)
+			}
+
+			// Deliverer sees the submission already stored within the transaction.
+			if len(d.statuses) != 1 || d.statuses[0] != model.SubmissionPending {
+				t.Errorf("delivered with statuses %v, want [pending]", d.statuses)
+			}
+
+			if len(subs.submissions) != tc.stored {
+				t.Errorf("stored %d submissions, want %d", len(subs.submissions), tc.stored)
+			}
+		})
+	}
+}
+
+// fakeOutbox records messages added to the outbox.
+type fakeOutbox struct {
+	err      error
+	messages []*contact.Submitted
+}
+
+func (o *fakeOutbox) Add(_ context.Context, topic string, payload interface{}) error {
+	if o.err != nil {
+		return o.err
+	}
+
+	if s, ok := payload.(*contact.Submitted); ok && topic == contact.SubmittedTopic {
+		o.messages = append(o.messages, s)
+	}
+
+	return nil
+}
+
+func TestMailSendAddsOutboxMessage(t *testing.T) {
+	t.Parallel()
+
+	subs := &fakeSubmissions{submissions: map[string]model.Submission{}} //nolint:exhaustivestruct
+	o := &fakeOutbox{}                                                   //nolint:exhaustivestruct
+	d := &fakeDeliverer{}                                                //nolint:exhaustivestruct
+	c := controller.NewMail(d, zap.NewNop(), controller.MailSubmissions(subs, subs), controller.MailOutbox(o))
+
+	if res := sendMail(t, c, "en", mailRequest()); res.status != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", res.status, http.StatusAccepted)
+	}
+
+	if len(o.messages) != 1 {
+		t.Fatalf("added %d outbox messages, want 1", len(o.messages))
+	}
+
+	if _, ok := subs.submissions[o.messages[0].ID]; !ok {
+		t.Errorf("outbox message refers to submission %s which is not stored", o.messages[0].ID)
+	}
+}
+
+func TestMailSendOutboxFailure(t *testing.T) {
+	t.Parallel()
+
+	subs := &fakeSubmissions{submissions: map[string]model.Submission{}} //nolint:exhaustivestruct
+	d := &fakeDeliverer{}                                                //nolint:exhaustivestruct
+	c := controller.NewMail(d, zap.NewNop(), controller.MailSubmissions(subs, subs),
+		controller.MailOutbox(&fakeOutbox{err: errDeliver})) //nolint:exhaustivestruct
+
+	if res := sendMail(t, c, "en", mailRequest()); res.status != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", res.status, http.StatusInternalServerError)
+	}
+
+	if len(subs.submissions) != 0 || d.count() != 0 {
+		t.Errorf("stored %d and delivered %d submissions, want none", len(subs.submissions), d.count())
+	}
+}
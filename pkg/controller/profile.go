@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/acim/arc/pkg/model"
+	"golang.org/x/text/language"
+)
+
+// ErrUnknownField is returned when request contains a field which can not be set.
+var ErrUnknownField = errors.New("unknown field")
+
+const maxDisplayNameLength = 100
+
+// applyProfilePatch applies merge patch to profile. Profile is modified only if all fields are valid.
+func applyProfilePatch(p *model.Profile, patch map[string]*string) error {
+	patched := *p
+
+	for field, value := range patch {
+		v := ""
+		if value != nil {
+			v = *value
+		}
+
+		switch field {
+		case "displayName":
+			patched.DisplayName = v
+		case "locale":
+			patched.Locale = v
+		case "timezone":
+			patched.Timezone = v
+		case "avatarUrl":
+			patched.AvatarURL = v
+		default:
+			return invalid(ErrUnknownField, "Unknown field %q", field)
+		}
+	}
+
+	if err := validateProfile(&patched); err != nil {
+		return err
+	}
+
+	*p = patched
+
+	return nil
+}
+
+func validateProfile(p *model.Profile) error {
+	if len([]rune(p.DisplayName)) > maxDisplayNameLength {
+		return invalidN(ErrInvalidValue, "Display name must be at most %d characters", maxDisplayNameLength,
+			maxDisplayNameLength)
+	}
+
+	if p.Locale != "" {
+		tag, err := language.Parse(p.Locale)
+		if err != nil {
+			return invalid(ErrInvalidValue, "Invalid locale %q", p.Locale)
+		}
+
+		p.Locale = tag.String()
+	}
+
+	if p.Timezone != "" {
+		if p.Timezone == "Local" {
+			return invalid(ErrInvalidValue, "Invalid timezone %q", p.Timezone)
+		}
+
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			return invalid(ErrInvalidValue, "Invalid timezone %q", p.Timezone)
+		}
+	}
+
+	if p.AvatarURL != "" {
+		u, err := url.Parse(p.AvatarURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return invalid(ErrInvalidValue, "Invalid avatar URL")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/acim/arc/pkg/contact"
+	"github.com/acim/arc/pkg/i18n"
+	arcmw "github.com/acim/arc/pkg/middleware"
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// Submissions controller lets users manage contact form submissions.
+type Submissions struct {
+	submissions store.Submissions
+	transactor  store.Transactor
+	deliverer   contact.Deliverer
+	logger      *zap.Logger
+}
+
+// NewSubmissions creates new submissions controller. Deliverer is used to resend submissions within
+// transactions started by transactor.
+func NewSubmissions(submissions store.Submissions, transactor store.Transactor, deliverer contact.Deliverer,
+	logger *zap.Logger,
+) *Submissions {
+	return &Submissions{
+		submissions: submissions,
+		transactor:  transactor,
+		deliverer:   deliverer,
+		logger:      logger,
+	}
+}
+
+// List handles GET /admin/submissions endpoint. Submissions are filtered by q, status and handled query
+// parameters and paginated from the newest using after and limit, the next page is linked in Link header.
+func (c *Submissions) List(w http.ResponseWriter, r *http.Request) {
+	res := arcmw.ResponseFromContext(r.Context())
+	loc := i18n.FromContext(r.Context())
+
+	query := r.URL.Query()
+	filter := &store.SubmissionFilter{ //nolint:exhaustivestruct
+		Query:  query.Get("q"),
+		Status: query.Get("status"),
+	}
+
+	switch filter.Status {
+	case "", model.SubmissionPending, model.SubmissionSent, model.SubmissionFailed:
+	default:
+		res.SetStatusBadRequest(loc.T("Invalid query parameter %q", "status"))
+
+		return
+	}
+
+	if v := query.Get("handled"); v != "" {
+		handled, err := strconv.ParseBool(v)
+		if err != nil {
+			res.SetStatusBadRequest(loc.T("Invalid query parameter %q", "handled"))
+
+			return
+		}
+
+		filter.Handled = &handled
+	}
+
+	limit := defaultListLimit
+
+	if v := query.Get("limit"); v != "" {
+		var err error
+
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxListLimit {
+			res.SetStatusBadRequest(loc.T("Invalid query parameter %q", "limit"))
+
+			return
+		}
+	}
+
+	after := query.Get("after")
+	if _, err := uuid.Parse(after); after != "" && err != nil {
+		res.SetStatusBadRequest(loc.T("Invalid query parameter %q", "after"))
+
+		return
+	}
+
+	subs, err := c.submissions.List(r.Context(), filter, after, limit)
+	if err != nil {
+		c.logger.Error("list submissions", zap.Error(err))
+		res.SetStatusInternalServerError("")
+
+		return
+	}
+
+	if len(subs) == limit {
+		next := *r.URL
+		q := next.Query()
+		q.Set("after", subs[len(subs)-1].ID)
+		next.RawQuery = q.Encode()
+		res.SetHeader("Link", "<"+next.RequestURI()+`>; rel="next"`)
+	}
+
+	res.SetPayload(subs)
+}
+
+// Get handles GET /admin/submissions/{id} endpoint.
+func (c *Submissions) Get(w http.ResponseWriter, r *http.Request) {
+	res := arcmw.ResponseFromContext(r.Context())
+
+	sub, err := c.submissions.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		c.logger.Warn("get submission", zap.Error(err))
+		setStoreError(res, i18n.FromContext(r.Context()), err)
+
+		return
+	}
+
+	res.SetPayload(sub)
+}
+
+// Handled handles PUT /admin/submissions/{id}/handled endpoint marking submission as handled by the user.
+func (c *Submissions) Handled(w http.ResponseWriter, r *http.Request) {
+	res := arcmw.ResponseFromContext(r.Context())
+
+	userID, err := getUserID(r.Context())
+	if err != nil {
+		c.logger.Warn("handled", zap.NamedError("get user id", err))
+		res.SetStatusInternalServerError(err.Error())
+
+		return
+	}
+
+	c.setHandled(w, r, userID)
+}
+
+// Unhandled handles DELETE /admin/submissions/{id}/handled endpoint marking submission as not handled.
+func (c *Submissions) Unhandled(w http.ResponseWriter, r *http.Request) {
+	c.setHandled(w, r, "")
+}
+
+// Resend handles POST /admin/submissions/{id}/resend endpoint. Submission is marked pending and delivered
+// in a single transaction, so that its status is left unchanged if delivery fails.
+func (c *Submissions) Resend(w http.ResponseWriter, r *http.Request) {
+	res := arcmw.ResponseFromContext(r.Context())
+	loc := i18n.FromContext(r.Context())
+
+	sub, err := c.submissions.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		c.logger.Warn("resend", zap.NamedError("find by id", err))
+		setStoreError(res, loc, err)
+
+		return
+	}
+
+	sub.Status = model.SubmissionPending
+	sub.Error = ""
+
+	var deliverErr error
+
+	err = c.transactor.WithTx(r.Context(), func(ctx context.Context) error {
+		if err := c.submissions.UpdateDelivery(ctx, sub); err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		deliverErr = c.deliverer.Deliver(ctx, sub)
+
+		return deliverErr
+	})
+
+	switch {
+	case deliverErr != nil:
+		c.logger.Error("resend", zap.String("submission", sub.ID), zap.Error(deliverErr))
+		res.SetStatusInternalServerError(loc.T(errSendingMail))
+
+		return
+	case err != nil:
+		c.logger.Warn("resend", zap.NamedError("update delivery", err))
+		setStoreError(res, loc, err)
+
+		return
+	}
+
+	res.SetStatusAccepted().SetPayload(sub)
+}
+
+func (c *Submissions) setHandled(w http.ResponseWriter, r *http.Request, userID string) {
+	res := arcmw.ResponseFromContext(r.Context())
+
+	sub, err := c.submissions.SetHandled(r.Context(), chi.URLParam(r, "id"), userID)
+	if err != nil {
+		c.logger.Warn("set handled", zap.Error(err))
+		setStoreError(res, i18n.FromContext(r.Context()), err)
+
+		return
+	}
+
+	res.SetPayload(sub)
+}
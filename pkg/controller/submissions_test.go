@@ -0,0 +1,178 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/acim/arc/pkg/controller"
+	"github.com/acim/arc/pkg/middleware"
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+var errDeliver = errors.New("deliver failed")
+
+// fakeSubmissions keeps submissions in memory. Transactions restore submissions changed by failed
+// functions.
+type fakeSubmissions struct {
+	store.Submissions
+	mu          sync.Mutex
+	submissions map[string]model.Submission
+	updateErr   error
+}
+
+func (s *fakeSubmissions) FindByID(_ context.Context, id string) (*model.Submission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.submissions[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &sub, nil
+}
+
+func (s *fakeSubmissions) Insert(_ context.Context, sub *model.Submission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.submissions[sub.ID] = *sub
+
+	return nil
+}
+
+func (s *fakeSubmissions) UpdateDelivery(_ context.Context, sub *model.Submission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.updateErr != nil {
+		return s.updateErr
+	}
+
+	s.submissions[sub.ID] = *sub
+
+	return nil
+}
+
+func (s *fakeSubmissions) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	s.mu.Lock()
+	saved := make(map[string]model.Submission, len(s.submissions))
+
+	for id, sub := range s.submissions {
+		saved[id] = sub
+	}
+	s.mu.Unlock()
+
+	if err := fn(ctx); err != nil {
+		s.mu.Lock()
+		s.submissions = saved
+		s.mu.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// statusDeliverer records status of submissions it delivers as seen by the store.
+type statusDeliverer struct {
+	submissions *fakeSubmissions
+	err         error
+	statuses    []string
+}
+
+func (d *statusDeliverer) Deliver(ctx context.Context, s *model.Submission) error {
+	stored, err := d.submissions.FindByID(ctx, s.ID)
+	if err != nil {
+		return err
+	}
+
+	d.statuses = append(d.statuses, stored.Status)
+
+	return d.err
+}
+
+func resend(t *testing.T, subs *fakeSubmissions, d *statusDeliverer, id string) int {
+	t.Helper()
+
+	c := controller.NewSubmissions(subs, subs, d, zap.NewNop())
+
+	router := chi.NewRouter()
+	router.Use(middleware.RenderJSON)
+	router.Post("/admin/submissions/{id}/resend", c.Resend)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/submissions/"+id+"/resend", nil))
+
+	return rec.Code
+}
+
+func newFakeSubmissions() *fakeSubmissions {
+	return &fakeSubmissions{ //nolint:exhaustivestruct
+		submissions: map[string]model.Submission{
+			"1": {ID: "1", Status: model.SubmissionFailed, Error: "mailbox unavailable"}, //nolint:exhaustivestruct
+		},
+	}
+}
+
+func TestSubmissionsResend(t *testing.T) {
+	t.Parallel()
+
+	subs := newFakeSubmissions()
+	d := &statusDeliverer{submissions: subs} //nolint:exhaustivestruct
+
+	if code := resend(t, subs, d, "1"); code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", code, http.StatusAccepted)
+	}
+
+	if len(d.statuses) != 1 || d.statuses[0] != model.SubmissionPending {
+		t.Errorf("delivered with statuses %v, want [pending]", d.statuses)
+	}
+
+	if sub := subs.submissions["1"]; sub.Status != model.SubmissionPending || sub.Error != "" {
+		t.Errorf("stored status %s, error %q, want pending without error", sub.Status, sub.Error)
+	}
+}
+
+func TestSubmissionsResendFailureKeepsStatus(t *testing.T) {
+	t.Parallel()
+
+	subs := newFakeSubmissions()
+	d := &statusDeliverer{submissions: subs, err: errDeliver} //nolint:exhaustivestruct
+
+	if code := resend(t, subs, d, "1"); code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", code, http.StatusInternalServerError)
+	}
+
+	if sub := subs.submissions["1"]; sub.Status != model.SubmissionFailed || sub.Error != "mailbox unavailable" {
+		t.Errorf("stored status %s, error %q, want the previous ones", sub.Status, sub.Error)
+	}
+}
+
+func TestSubmissionsResendStoreErrors(t *testing.T) {
+	t.Parallel()
+
+	subs := newFakeSubmissions()
+	d := &statusDeliverer{submissions: subs} //nolint:exhaustivestruct
+
+	if code := resend(t, subs, d, "2"); code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", code, http.StatusNotFound)
+	}
+
+	subs.updateErr = store.ErrConflict
+
+	if code := resend(t, subs, d, "1"); code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", code, http.StatusConflict)
+	}
+
+	if len(d.statuses) != 0 {
+		t.Errorf("submission delivered without being updated")
+	}
+}
@@ -0,0 +1,274 @@
+package controller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acim/arc/pkg/i18n"
+	"github.com/acim/arc/pkg/mail"
+	"github.com/acim/arc/pkg/middleware"
+	"go.uber.org/zap"
+)
+
+// Errors.
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrStaleSignature   = errors.New("stale signature")
+	ErrReplayedToken    = errors.New("replayed token")
+)
+
+// MailgunWebhook controller receives Mailgun event webhooks.
+type MailgunWebhook struct {
+	signingKey   []byte
+	events       mail.EventStore
+	suppressions mail.Suppressions
+	maxAge       time.Duration
+	tokens       *seenTokens
+	logger       *zap.Logger
+}
+
+// NewMailgunWebhook creates new Mailgun webhook controller. Requests are authenticated with the webhook
+// signing key found in Mailgun's control panel.
+func NewMailgunWebhook(signingKey string, events mail.EventStore, suppressions mail.Suppressions,
+	logger *zap.Logger, opts ...MailgunWebhookOption,
+) *MailgunWebhook {
+	c := &MailgunWebhook{
+		signingKey:   []byte(signingKey),
+		events:       events,
+		suppressions: suppressions,
+		maxAge:       15 * time.Minute, //nolint:gomnd
+		tokens:       newSeenTokens(),
+		logger:       logger,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Receive handles POST /webhooks/mailgun endpoint. Each event is recorded against the Message-ID of the
+// e-mail and recipients which permanently failed, complained or unsubscribed are suppressed. Requests
+// with a token seen before are rejected, unless the earlier request failed. Mailgun retries delivery of the
+// webhook on server errors, so storing is idempotent.
+func (c *MailgunWebhook) Receive(w http.ResponseWriter, r *http.Request) {
+	const maxBodySize = 1 << 20
+
+	res := middleware.ResponseFromContext(r.Context())
+	loc := i18n.FromContext(r.Context())
+
+	p := &mailgunPayload{} //nolint:exhaustivestruct
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(p); err != nil {
+		c.logger.Warn("mailgun webhook", zap.NamedError("json decode", err))
+		res.SetStatusBadRequest(loc.T(errParsingRequestBody))
+
+		return
+	}
+
+	if err := c.verify(&p.Signature, time.Now()); err != nil {
+		c.logger.Warn("mailgun webhook", zap.Error(err))
+		res.SetStatusForbidden(loc.T(http.StatusText(http.StatusForbidden)))
+
+		return
+	}
+
+	e := p.EventData.event()
+	if e.ID == "" || e.Event == "" {
+		res.SetStatusBadRequest(loc.T(errParsingRequestBody))
+
+		return
+	}
+
+	if err := c.store(r.Context(), e); err != nil {
+		// Token is accepted again, so that Mailgun can retry.
+		c.tokens.remove(p.Signature.Token)
+		c.logger.Error("mailgun webhook", zap.Error(err))
+		res.SetStatusInternalServerError("")
+
+		return
+	}
+
+	res.SetStatus(http.StatusNoContent)
+}
+
+// store suppresses recipient if needed and stores the event.
+func (c *MailgunWebhook) store(ctx context.Context, e *mail.Event) error {
+	if reason := suppressionReason(e); reason != "" && e.Recipient != "" {
+		if err := c.suppressions.Suppress(ctx, e.Recipient, reason); err != nil {
+			return fmt.Errorf("suppress: %w", err)
+		}
+	}
+
+	if err := c.events.AddEvent(ctx, e); err != nil {
+		return fmt.Errorf("add event: %w", err)
+	}
+
+	return nil
+}
+
+// verify checks that signature is HMAC-SHA256 of timestamp and token made with the signing key, that
+// it is not older than maxAge and that the token hasn't been used before.
+func (c *MailgunWebhook) verify(s *mailgunSignature, now time.Time) error {
+	ts, err := strconv.ParseInt(s.Timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if d := now.Sub(time.Unix(ts, 0)); d > c.maxAge || d < -c.maxAge {
+		return ErrStaleSignature
+	}
+
+	signature, err := hex.DecodeString(s.Signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, c.signingKey)
+	_, _ = mac.Write([]byte(s.Timestamp + s.Token))
+
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+
+	// Token older than maxAge is rejected as stale, so it doesn't have to be remembered any longer.
+	if !c.tokens.add(s.Token, time.Unix(ts, 0).Add(c.maxAge), now) {
+		return ErrReplayedToken
+	}
+
+	return nil
+}
+
+// seenTokens remembers signature tokens until they expire. Tokens are kept in memory, so each instance of
+// the service detects replays separately.
+type seenTokens struct {
+	mu        sync.Mutex
+	tokens    map[string]time.Time
+	lastPrune time.Time
+}
+
+func newSeenTokens() *seenTokens {
+	return &seenTokens{tokens: make(map[string]time.Time)} //nolint:exhaustivestruct
+}
+
+// add remembers token until expiry and returns false if it has already been seen.
+func (s *seenTokens) add(token string, expiry, now time.Time) bool {
+	const pruneInterval = time.Minute
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastPrune) > pruneInterval {
+		for t, e := range s.tokens {
+			if now.After(e) {
+				delete(s.tokens, t)
+			}
+		}
+
+		s.lastPrune = now
+	}
+
+	if e, ok := s.tokens[token]; ok && !now.After(e) {
+		return false
+	}
+
+	s.tokens[token] = expiry
+
+	return true
+}
+
+func (s *seenTokens) remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, token)
+}
+
+// suppressionReason returns the reason recipient of the event should not receive e-mails any more, if any.
+func suppressionReason(e *mail.Event) string {
+	switch e.Event {
+	case "failed":
+		if e.Severity == "permanent" {
+			return mail.SuppressionBounce
+		}
+	case "complained":
+		return mail.SuppressionComplaint
+	case "unsubscribed":
+		return mail.SuppressionUnsubscribe
+	}
+
+	return ""
+}
+
+// MailgunWebhookOption ...
+type MailgunWebhookOption func(*MailgunWebhook)
+
+// MailgunWebhookMaxAge sets the maximum allowed difference between signature timestamp and current time.
+func MailgunWebhookMaxAge(d time.Duration) MailgunWebhookOption {
+	return func(c *MailgunWebhook) {
+		c.maxAge = d
+	}
+}
+
+type mailgunPayload struct {
+	Signature mailgunSignature `json:"signature"`
+	EventData mailgunEventData `json:"event-data"`
+}
+
+type mailgunSignature struct {
+	Timestamp string `json:"timestamp"`
+	Token     string `json:"token"`
+	Signature string `json:"signature"`
+}
+
+type mailgunEventData struct {
+	ID        string  `json:"id"`
+	Event     string  `json:"event"`
+	Timestamp float64 `json:"timestamp"`
+	Recipient string  `json:"recipient"`
+	Severity  string  `json:"severity"`
+	Reason    string  `json:"reason"`
+	Message   struct {
+		Headers struct {
+			MessageID string `json:"message-id"`
+		} `json:"headers"`
+	} `json:"message"`
+	DeliveryStatus struct {
+		Code        int    `json:"code"`
+		Message     string `json:"message"`
+		Description string `json:"description"`
+	} `json:"delivery-status"`
+}
+
+func (d *mailgunEventData) event() *mail.Event {
+	sec, frac := math.Modf(d.Timestamp)
+
+	description := d.DeliveryStatus.Description
+	if description == "" {
+		description = d.DeliveryStatus.Message
+	}
+
+	return &mail.Event{
+		ID:          d.ID,
+		MessageID:   strings.Trim(d.Message.Headers.MessageID, "<>"),
+		Event:       d.Event,
+		Recipient:   d.Recipient,
+		Severity:    d.Severity,
+		Reason:      d.Reason,
+		Code:        d.DeliveryStatus.Code,
+		Description: description,
+		Timestamp:   time.Unix(int64(sec), int64(frac*float64(time.Second))).UTC(),
+	}
+}
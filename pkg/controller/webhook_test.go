@@ -0,0 +1,316 @@
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/controller"
+	"github.com/acim/arc/pkg/mail"
+	"github.com/acim/arc/pkg/middleware"
+	"go.uber.org/zap"
+)
+
+const signingKey = "webhook-signing-key"
+
+var errStore = errors.New("store failed")
+
+type fakeEvents struct {
+	mu     sync.Mutex
+	events map[string]*mail.Event
+	calls  int
+	err    error
+}
+
+func (s *fakeEvents) AddEvent(_ context.Context, e *mail.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+
+	if s.err != nil {
+		return s.err
+	}
+
+	if _, ok := s.events[e.ID]; !ok {
+		s.events[e.ID] = e
+	}
+
+	return nil
+}
+
+func (s *fakeEvents) Events(_ context.Context, messageID string) ([]*mail.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []*mail.Event
+
+	for _, e := range s.events {
+		if e.MessageID == messageID {
+			list = append(list, e)
+		}
+	}
+
+	return list, nil
+}
+
+func (s *fakeEvents) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+type fakeSuppressions struct {
+	mu        sync.Mutex
+	addresses map[string]string
+}
+
+func (s *fakeSuppressions) Suppress(_ context.Context, address, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addresses[address] = reason
+
+	return nil
+}
+
+func (s *fakeSuppressions) Unsuppress(_ context.Context, address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.addresses, address)
+
+	return nil
+}
+
+func (s *fakeSuppressions) Suppressed(_ context.Context, addresses []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []string
+
+	for _, a := range addresses {
+		if _, ok := s.addresses[a]; ok {
+			list = append(list, a)
+		}
+	}
+
+	return list, nil
+}
+
+type webhookServer struct {
+	url          string
+	events       *fakeEvents
+	suppressions *fakeSuppressions
+}
+
+func newWebhookServer(t *testing.T) *webhookServer {
+	t.Helper()
+
+	s := &webhookServer{
+		events:       &fakeEvents{events: make(map[string]*mail.Event)},     //nolint:exhaustivestruct
+		suppressions: &fakeSuppressions{addresses: make(map[string]string)}, //nolint:exhaustivestruct
+	}
+
+	c := controller.NewMailgunWebhook(signingKey, s.events, s.suppressions, zap.NewNop(),
+		controller.MailgunWebhookMaxAge(time.Minute))
+
+	srv := httptest.NewServer(middleware.RenderJSON(http.HandlerFunc(c.Receive)))
+	t.Cleanup(srv.Close)
+
+	s.url = srv.URL
+
+	return s
+}
+
+func (s *webhookServer) post(t *testing.T, payload interface{}) int {
+	t.Helper()
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	res, err := http.Post(s.url, "application/json", bytes.NewReader(b)) //nolint:noctx
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+
+	_ = res.Body.Close()
+
+	return res.StatusCode
+}
+
+func sign(key, timestamp, token string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	_, _ = mac.Write([]byte(timestamp + token))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func payload(key string, ts time.Time, token, id, event string) map[string]interface{} {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+
+	return map[string]interface{}{
+		"signature": map[string]string{
+			"timestamp": timestamp,
+			"token":     token,
+			"signature": sign(key, timestamp, token),
+		},
+		"event-data": map[string]interface{}{
+			"id":        id,
+			"event":     event,
+			"timestamp": float64(ts.Unix()) + 0.5,
+			"recipient": "rcpt@example.com",
+			"severity":  "permanent",
+			"message":   map[string]interface{}{"headers": map[string]string{"message-id": "<msg@example.com>"}},
+			"delivery-status": map[string]interface{}{
+				"code":    550,
+				"message": "User unknown",
+			},
+		},
+	}
+}
+
+func TestMailgunWebhookValidSignature(t *testing.T) {
+	t.Parallel()
+
+	s := newWebhookServer(t)
+
+	if code := s.post(t, payload(signingKey, time.Now(), "token", "event-1", "failed")); code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", code, http.StatusNoContent)
+	}
+
+	events, _ := s.events.Events(context.Background(), "msg@example.com")
+	if len(events) != 1 || events[0].Event != "failed" || events[0].Code != 550 ||
+		events[0].Description != "User unknown" {
+		t.Errorf("stored events %v, want failed event", events)
+	}
+
+	if reason := s.suppressions.addresses["rcpt@example.com"]; reason != mail.SuppressionBounce {
+		t.Errorf("suppression reason = %q, want %q", reason, mail.SuppressionBounce)
+	}
+}
+
+func TestMailgunWebhookRejected(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		payload interface{}
+		status  int
+	}{
+		"bad signature": {
+			payload: payload("other-key", time.Now(), "token", "event-1", "delivered"),
+			status:  http.StatusForbidden,
+		},
+		"stale timestamp": {
+			payload: payload(signingKey, time.Now().Add(-2*time.Minute), "token", "event-1", "delivered"),
+			status:  http.StatusForbidden,
+		},
+		"future timestamp": {
+			payload: payload(signingKey, time.Now().Add(2*time.Minute), "token", "event-1", "delivered"),
+			status:  http.StatusForbidden,
+		},
+		"missing event": {
+			payload: payload(signingKey, time.Now(), "token", "", ""),
+			status:  http.StatusBadRequest,
+		},
+		"malformed body": {
+			payload: "not an object",
+			status:  http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newWebhookServer(t)
+
+			if code := s.post(t, tc.payload); code != tc.status {
+				t.Errorf("status = %d, want %d", code, tc.status)
+			}
+
+			if s.events.calls != 0 || len(s.suppressions.addresses) != 0 {
+				t.Error("rejected event was stored")
+			}
+		})
+	}
+}
+
+func TestMailgunWebhookReplayedToken(t *testing.T) {
+	t.Parallel()
+
+	s := newWebhookServer(t)
+	p := payload(signingKey, time.Now(), "token", "event-1", "delivered")
+
+	if code := s.post(t, p); code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", code, http.StatusNoContent)
+	}
+
+	if code := s.post(t, p); code != http.StatusForbidden {
+		t.Errorf("replay status = %d, want %d", code, http.StatusForbidden)
+	}
+
+	if s.events.calls != 1 {
+		t.Errorf("event stored %d times, want 1", s.events.calls)
+	}
+}
+
+func TestMailgunWebhookRetryAfterFailure(t *testing.T) {
+	t.Parallel()
+
+	s := newWebhookServer(t)
+	p := payload(signingKey, time.Now(), "token", "event-1", "delivered")
+
+	s.events.fail(errStore)
+
+	if code := s.post(t, p); code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", code, http.StatusInternalServerError)
+	}
+
+	s.events.fail(nil)
+
+	// Mailgun retries with the same token.
+	if code := s.post(t, p); code != http.StatusNoContent {
+		t.Errorf("retry status = %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestMailgunWebhookIdempotent(t *testing.T) {
+	t.Parallel()
+
+	s := newWebhookServer(t)
+
+	// The same event delivered again is signed with a new token.
+	for i := 0; i < 2; i++ {
+		p := payload(signingKey, time.Now(), fmt.Sprintf("token-%d", i), "event-1", "complained")
+
+		if code := s.post(t, p); code != http.StatusNoContent {
+			t.Fatalf("status = %d, want %d", code, http.StatusNoContent)
+		}
+	}
+
+	events, _ := s.events.Events(context.Background(), "msg@example.com")
+	if len(events) != 1 {
+		t.Errorf("stored %d events, want 1", len(events))
+	}
+
+	if reason := s.suppressions.addresses["rcpt@example.com"]; reason != mail.SuppressionComplaint {
+		t.Errorf("suppression reason = %q, want %q", reason, mail.SuppressionComplaint)
+	}
+}
@@ -0,0 +1,206 @@
+// Package i18n contains message catalog with language negotiation and pluralization.
+//
+// Messages are identified by their English text, which is also used when there is no translation.
+// Catalog file is a JSON object named by BCP 47 language tag, i.e. de.json, mapping English text to its
+// translation. Translation is either a string or an object of CLDR plural forms (zero, one, two, few, many,
+// other) used by Localizer.N. English file is needed only to define English plural forms.
+package i18n
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+)
+
+// ErrInvalidCatalog is returned when catalog file is malformed.
+var ErrInvalidCatalog = errors.New("invalid catalog")
+
+// Catalog contains translations of messages.
+type Catalog struct {
+	tags     []language.Tag
+	matcher  language.Matcher
+	messages map[language.Tag]map[string]message
+}
+
+// message is either a single translation or plural forms.
+type message struct {
+	text  string
+	forms map[plural.Form]string
+}
+
+func (m *message) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &m.text); err == nil {
+		return nil
+	}
+
+	var forms map[string]string
+
+	if err := json.Unmarshal(b, &forms); err != nil {
+		return fmt.Errorf("message must be string or object of plural forms: %w", err)
+	}
+
+	m.forms = make(map[plural.Form]string, len(forms))
+
+	for name, text := range forms {
+		form, ok := pluralForms[name]
+		if !ok {
+			return fmt.Errorf("plural form %q: %w", name, ErrInvalidCatalog)
+		}
+
+		m.forms[form] = text
+	}
+
+	return nil
+}
+
+var pluralForms = map[string]plural.Form{ //nolint:gochecknoglobals
+	"zero":  plural.Zero,
+	"one":   plural.One,
+	"two":   plural.Two,
+	"few":   plural.Few,
+	"many":  plural.Many,
+	"other": plural.Other,
+}
+
+// NewCatalog loads all .json files found in the root of fsys, which is usually an embed.FS.
+// English is always supported and used as fallback.
+func NewCatalog(fsys fs.FS) (*Catalog, error) {
+	c := &Catalog{
+		tags:     []language.Tag{language.English},
+		matcher:  nil,
+		messages: map[language.Tag]map[string]message{language.English: {}},
+	}
+
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, fmt.Errorf("new catalog: %w", err)
+	}
+
+	for _, file := range files {
+		tag, err := language.Parse(strings.TrimSuffix(path.Base(file), ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("new catalog: %s: %w", file, err)
+		}
+
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("new catalog: %w", err)
+		}
+
+		messages := make(map[string]message)
+
+		if err := json.Unmarshal(b, &messages); err != nil {
+			return nil, fmt.Errorf("new catalog: %s: %w", file, err)
+		}
+
+		if _, ok := c.messages[tag]; !ok {
+			c.tags = append(c.tags, tag)
+		}
+
+		c.messages[tag] = messages
+	}
+
+	c.matcher = language.NewMatcher(c.tags)
+
+	return c, nil
+}
+
+// Tags returns supported languages, English being the first.
+func (c *Catalog) Tags() []language.Tag {
+	return c.tags
+}
+
+// Match returns the supported language best matching Accept-Language header value.
+func (c *Catalog) Match(acceptLanguage string) language.Tag {
+	prefs, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(prefs) == 0 {
+		return language.English
+	}
+
+	_, i, confidence := c.matcher.Match(prefs...)
+	if confidence == language.No {
+		return language.English
+	}
+
+	return c.tags[i]
+}
+
+// Localizer returns localizer for the supported language.
+func (c *Catalog) Localizer(tag language.Tag) *Localizer {
+	return &Localizer{
+		tag:      tag,
+		messages: c.messages[tag],
+	}
+}
+
+// Localizer translates messages to a single language. Zero value translates to English without
+// a catalog.
+type Localizer struct {
+	tag      language.Tag
+	messages map[string]message
+}
+
+// Language returns localizer's language.
+func (l *Localizer) Language() language.Tag {
+	if l.tag == language.Und {
+		return language.English
+	}
+
+	return l.tag
+}
+
+// T translates message and formats it with args, if any, like fmt.Sprintf does.
+func (l *Localizer) T(msg string, args ...interface{}) string {
+	if m, ok := l.messages[msg]; ok && m.text != "" {
+		msg = m.text
+	}
+
+	return format(msg, args)
+}
+
+// N translates message in the plural form matching n and formats it with args like fmt.Sprintf does.
+// If a form is not translated, the other form is used and the English message at last.
+func (l *Localizer) N(msg string, n int, args ...interface{}) string {
+	if m, ok := l.messages[msg]; ok && m.forms != nil {
+		form := plural.Cardinal.MatchPlural(l.Language(), n, 0, 0, 0, 0)
+
+		if text, ok := m.forms[form]; ok {
+			msg = text
+		} else if text, ok := m.forms[plural.Other]; ok {
+			msg = text
+		}
+	}
+
+	return format(msg, args)
+}
+
+func format(msg string, args []interface{}) string {
+	if len(args) == 0 {
+		return msg
+	}
+
+	return fmt.Sprintf(msg, args...)
+}
+
+type localizerKey struct{}
+
+// NewContext returns context carrying localizer.
+func NewContext(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, localizerKey{}, l)
+}
+
+// FromContext returns localizer found in context or English localizer if there is none.
+func FromContext(ctx context.Context) *Localizer {
+	if l, ok := ctx.Value(localizerKey{}).(*Localizer); ok {
+		return l
+	}
+
+	return &Localizer{} //nolint:exhaustivestruct
+}
@@ -0,0 +1,217 @@
+package i18n_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/acim/arc/pkg/i18n"
+	"golang.org/x/text/language"
+)
+
+// file returns catalog file with the content.
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)} //nolint:exhaustivestruct
+}
+
+func newCatalog(t *testing.T) *i18n.Catalog {
+	t.Helper()
+
+	c, err := i18n.NewCatalog(fstest.MapFS{
+		"en.json": file(`{"%d messages": {"one": "%d message", "other": "%d messages"}}`),
+		"de.json": file(`{
+			"Hello": "Hallo",
+			"Hello %s": "Hallo %s",
+			"%d messages": {"one": "%d Nachricht", "other": "%d Nachrichten"}
+		}`),
+		"sr.json": file(`{
+			"Hello": "Здраво",
+			"%d messages": {"one": "%d порука", "few": "%d поруке", "other": "%d порука"},
+			"%d days": {"other": "%d дана"}
+		}`),
+		"README.md": file("not a catalog"),
+	})
+	if err != nil {
+		t.Fatalf("new catalog: %v", err)
+	}
+
+	return c
+}
+
+func TestNewCatalog(t *testing.T) {
+	t.Parallel()
+
+	tags := newCatalog(t).Tags()
+
+	if len(tags) != 3 || tags[0] != language.English {
+		t.Fatalf("tags = %v, want English first and de and sr", tags)
+	}
+
+	for _, tag := range []language.Tag{language.German, language.Serbian} {
+		if tag != tags[1] && tag != tags[2] {
+			t.Errorf("tags = %v, missing %v", tags, tag)
+		}
+	}
+}
+
+func TestNewCatalogInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fsys    fstest.MapFS
+		wantErr error
+	}{
+		"malformed json": {
+			fsys: fstest.MapFS{"de.json": file(`{"Hello":`)},
+		},
+		"invalid language": {
+			fsys: fstest.MapFS{"not a language.json": file(`{}`)},
+		},
+		"invalid message": {
+			fsys: fstest.MapFS{"de.json": file(`{"Hello": 1}`)},
+		},
+		"unknown plural form": {
+			fsys:    fstest.MapFS{"de.json": file(`{"%d messages": {"several": "%d Nachrichten"}}`)},
+			wantErr: i18n.ErrInvalidCatalog,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := i18n.NewCatalog(tc.fsys)
+			if err == nil {
+				t.Fatal("loaded invalid catalog")
+			}
+
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCatalogMatch(t *testing.T) {
+	t.Parallel()
+
+	c := newCatalog(t)
+
+	tests := map[string]struct {
+		acceptLanguage string
+		want           language.Tag
+	}{
+		"empty":                 {acceptLanguage: "", want: language.English},
+		"malformed":             {acceptLanguage: "de;q=x", want: language.English},
+		"exact":                 {acceptLanguage: "de", want: language.German},
+		"region":                {acceptLanguage: "de-AT", want: language.German},
+		"script and region":     {acceptLanguage: "sr-Latn-RS", want: language.Serbian},
+		"unsupported":           {acceptLanguage: "ja", want: language.English},
+		"unsupported first":     {acceptLanguage: "ja, sr;q=0.8", want: language.Serbian},
+		"higher q-value":        {acceptLanguage: "de;q=0.5, sr;q=0.9", want: language.Serbian},
+		"english preferred":     {acceptLanguage: "en-US, de;q=0.7", want: language.English},
+		"zero q-value excluded": {acceptLanguage: "de;q=0, sr;q=0.1", want: language.Serbian},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := c.Match(tc.acceptLanguage); got != tc.want {
+				t.Errorf("Match(%q) = %v, want %v", tc.acceptLanguage, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLocalizerT(t *testing.T) {
+	t.Parallel()
+
+	c := newCatalog(t)
+
+	tests := map[string]struct {
+		tag  language.Tag
+		msg  string
+		args []interface{}
+		want string
+	}{
+		"translated":            {tag: language.German, msg: "Hello", want: "Hallo"},
+		"translated with args":  {tag: language.German, msg: "Hello %s", args: []interface{}{"Jane"}, want: "Hallo Jane"},
+		"english":               {tag: language.English, msg: "Hello", want: "Hello"},
+		"untranslated fallback": {tag: language.Serbian, msg: "Hello %s", args: []interface{}{"Jane"}, want: "Hello Jane"},
+		"plural message":        {tag: language.German, msg: "%d messages", want: "%d messages"},
+		"unsupported language":  {tag: language.Japanese, msg: "Hello", want: "Hello"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := c.Localizer(tc.tag).T(tc.msg, tc.args...); got != tc.want {
+				t.Errorf("T(%q) = %q, want %q", tc.msg, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLocalizerN(t *testing.T) {
+	t.Parallel()
+
+	c := newCatalog(t)
+
+	tests := map[string]struct {
+		tag  language.Tag
+		msg  string
+		n    int
+		want string
+	}{
+		"english one":             {tag: language.English, msg: "%d messages", n: 1, want: "1 message"},
+		"english other":           {tag: language.English, msg: "%d messages", n: 2, want: "2 messages"},
+		"german zero":             {tag: language.German, msg: "%d messages", n: 0, want: "0 Nachrichten"},
+		"german one":              {tag: language.German, msg: "%d messages", n: 1, want: "1 Nachricht"},
+		"german other":            {tag: language.German, msg: "%d messages", n: 5, want: "5 Nachrichten"},
+		"serbian one":             {tag: language.Serbian, msg: "%d messages", n: 1, want: "1 порука"},
+		"serbian one after ten":   {tag: language.Serbian, msg: "%d messages", n: 21, want: "21 порука"},
+		"serbian few":             {tag: language.Serbian, msg: "%d messages", n: 3, want: "3 поруке"},
+		"serbian few after ten":   {tag: language.Serbian, msg: "%d messages", n: 24, want: "24 поруке"},
+		"serbian teens":           {tag: language.Serbian, msg: "%d messages", n: 12, want: "12 порука"},
+		"serbian other":           {tag: language.Serbian, msg: "%d messages", n: 5, want: "5 порука"},
+		"missing form uses other": {tag: language.Serbian, msg: "%d days", n: 1, want: "1 дана"},
+		"untranslated fallback":   {tag: language.German, msg: "%d days", n: 1, want: "1 days"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := c.Localizer(tc.tag).N(tc.msg, tc.n, tc.n); got != tc.want {
+				t.Errorf("N(%q, %d) = %q, want %q", tc.msg, tc.n, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	l := i18n.FromContext(context.Background())
+
+	if l.Language() != language.English || l.T("Hello %s", "Jane") != "Hello Jane" {
+		t.Errorf("localizer without catalog = %v, %q, want English", l.Language(), l.T("Hello %s", "Jane"))
+	}
+
+	de := newCatalog(t).Localizer(language.German)
+
+	if l := i18n.FromContext(i18n.NewContext(context.Background(), de)); l != de {
+		t.Error("localizer not found in context")
+	}
+}
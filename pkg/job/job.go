@@ -0,0 +1,156 @@
+// Package job contains background job queue with typed handlers run by a pool of workers.
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoHandler is returned when there is no handler registered for the job kind.
+var ErrNoHandler = errors.New("no handler")
+
+// Job is a unit of deferred work.
+type Job struct {
+	ID          int64
+	TenantID    string
+	Kind        string
+	Payload     json.RawMessage
+	Priority    int
+	RunAt       time.Time
+	Attempts    int
+	MaxAttempts int
+}
+
+// Store persists jobs.
+type Store interface {
+	// Enqueue stores new job. Implementations should join the transaction found in context, if any.
+	Enqueue(ctx context.Context, j *Job) error
+	// Claim returns up to limit due jobs, highest priority first, and hides them from other workers for
+	// the lease duration.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*Job, error)
+	// Done removes completed job.
+	Done(ctx context.Context, id int64) error
+	// Retry records failed attempt and schedules next one.
+	Retry(ctx context.Context, id int64, next time.Time, cause string) error
+	// Bury moves job to dead state. Dead jobs are kept for inspection, but never run again.
+	Bury(ctx context.Context, id int64, cause string) error
+	// Stats returns number of pending and dead jobs by kind.
+	Stats(ctx context.Context) ([]*Stats, error)
+}
+
+// Stats contains number of jobs of a kind.
+type Stats struct {
+	Kind    string
+	Pending int
+	Dead    int
+}
+
+// Queue enqueues jobs.
+type Queue struct {
+	store       Store
+	maxAttempts int
+}
+
+// NewQueue creates new queue.
+func NewQueue(store Store, opts ...QueueOption) *Queue {
+	q := &Queue{
+		store:       store,
+		maxAttempts: 10, //nolint:gomnd
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	return q
+}
+
+// Enqueue encodes payload as JSON and stores job of the given kind. Job runs in the tenant found in context.
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload interface{}, opts ...EnqueueOption) (*Job, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("enqueue %s: encode payload: %w", kind, err)
+	}
+
+	j := &Job{ //nolint:exhaustivestruct
+		Kind:        kind,
+		Payload:     b,
+		RunAt:       time.Now(),
+		MaxAttempts: q.maxAttempts,
+	}
+
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	if err := q.store.Enqueue(ctx, j); err != nil {
+		return nil, fmt.Errorf("enqueue %s: %w", kind, err)
+	}
+
+	return j, nil
+}
+
+// QueueOption ...
+type QueueOption func(*Queue)
+
+// QueueMaxAttempts sets default number of attempts after which job is moved to dead state.
+func QueueMaxAttempts(n int) QueueOption {
+	return func(q *Queue) {
+		q.maxAttempts = n
+	}
+}
+
+// EnqueueOption ...
+type EnqueueOption func(*Job)
+
+// Priority sets job priority. Jobs with higher priority run first, default is 0.
+func Priority(p int) EnqueueOption {
+	return func(j *Job) {
+		j.Priority = p
+	}
+}
+
+// RunAt schedules job to run not before t.
+func RunAt(t time.Time) EnqueueOption {
+	return func(j *Job) {
+		j.RunAt = t
+	}
+}
+
+// Delay schedules job to run not before d elapses.
+func Delay(d time.Duration) EnqueueOption {
+	return func(j *Job) {
+		j.RunAt = time.Now().Add(d)
+	}
+}
+
+// MaxAttempts overrides queue's number of attempts for the job.
+func MaxAttempts(n int) EnqueueOption {
+	return func(j *Job) {
+		j.MaxAttempts = n
+	}
+}
+
+// Handler runs job. Job is retried if handler returns an error, so handlers should be idempotent.
+type Handler func(ctx context.Context, j *Job) error
+
+// PermanentError signals that job must not be retried.
+type PermanentError struct {
+	Err error
+}
+
+// Permanent wraps err so that the job is moved to dead state without further attempts.
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
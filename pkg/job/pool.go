@@ -0,0 +1,348 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/acim/arc/pkg/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var _ prometheus.Collector = (*Pool)(nil)
+
+// Pool claims jobs from the store and runs them concurrently using registered handlers. Pool implements
+// prometheus.Collector, it should be registered by the caller.
+type Pool struct {
+	store         Store
+	handlers      map[string]Handler
+	workers       int
+	pollInterval  time.Duration
+	lease         time.Duration
+	backoff       time.Duration
+	statsInterval time.Duration
+	grace         time.Duration
+	logger        *zap.Logger
+	pending       *prometheus.GaugeVec
+	dead          *prometheus.GaugeVec
+	runs          *prometheus.CounterVec
+}
+
+// NewPool creates new worker pool.
+func NewPool(serviceName string, store Store, logger *zap.Logger, opts ...PoolOption) *Pool {
+	p := &Pool{ //nolint:exhaustivestruct
+		store:         store,
+		handlers:      make(map[string]Handler),
+		workers:       4, //nolint:gomnd
+		pollInterval:  time.Second,
+		lease:         5 * time.Minute,  //nolint:gomnd
+		backoff:       10 * time.Second, //nolint:gomnd
+		statsInterval: 30 * time.Second, //nolint:gomnd
+		grace:         10 * time.Second, //nolint:gomnd
+		logger:        logger,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.pending = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{ //nolint:exhaustivestruct
+			Name:        "job_queue_depth",
+			Help:        "Number of pending jobs partitioned by kind.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+		[]string{"kind"},
+	)
+
+	p.dead = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{ //nolint:exhaustivestruct
+			Name:        "job_dead",
+			Help:        "Number of dead jobs partitioned by kind.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+		[]string{"kind"},
+	)
+
+	p.runs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{ //nolint:exhaustivestruct
+			Name:        "job_runs_total",
+			Help:        "Number of job runs partitioned by kind and result (ok, retry or dead).",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+		[]string{"kind", "result"},
+	)
+
+	return p
+}
+
+// Describe implements prometheus.Collector interface.
+func (p *Pool) Describe(ch chan<- *prometheus.Desc) {
+	p.pending.Describe(ch)
+	p.dead.Describe(ch)
+	p.runs.Describe(ch)
+}
+
+// Collect implements prometheus.Collector interface.
+func (p *Pool) Collect(ch chan<- prometheus.Metric) {
+	p.pending.Collect(ch)
+	p.dead.Collect(ch)
+	p.runs.Collect(ch)
+}
+
+// Handle registers handler for the job kind.
+func (p *Pool) Handle(kind string, h Handler) {
+	p.handlers[kind] = h
+}
+
+// Register registers typed handler for the job kind. Payload is decoded from JSON before handler is called
+// and a job with malformed payload is moved to dead state.
+func Register[T any](p *Pool, kind string, h func(ctx context.Context, payload T) error) {
+	p.Handle(kind, func(ctx context.Context, j *Job) error {
+		var payload T
+
+		if err := json.Unmarshal(j.Payload, &payload); err != nil {
+			return Permanent(fmt.Errorf("decode payload: %w", err))
+		}
+
+		return h(ctx, payload)
+	})
+}
+
+// Run implements rest.Worker interface. Once context is canceled due jobs are still claimed, so that queued
+// work like e-mails is done before the process exits, until there are none left or the shutdown timeout
+// elapses. Run returns after running jobs finish. Jobs still running when the shutdown timeout elapses get
+// their contexts canceled and are retried later, as are jobs not yet claimed.
+func (p *Pool) Run(ctx context.Context) {
+	// Running jobs are detached from the worker context, so that shutdown doesn't interrupt them right away.
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-jobsCtx.Done():
+			return
+		}
+
+		timer := time.NewTimer(p.grace)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			cancelJobs()
+		case <-jobsCtx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+
+	defer wg.Wait()
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		p.collectStats(ctx)
+	}()
+
+	slots := make(chan struct{}, p.workers)
+	ticker := time.NewTicker(p.pollInterval)
+
+	defer ticker.Stop()
+
+	claimCtx, stop := ctx, ctx.Done()
+
+	for {
+		if free := cap(slots) - len(slots); free > 0 {
+			jobs, err := p.store.Claim(claimCtx, free, p.lease)
+			if err != nil && claimCtx.Err() == nil {
+				p.logger.Error("job claim", zap.Error(err))
+			}
+
+			for _, j := range jobs {
+				slots <- struct{}{}
+
+				wg.Add(1)
+
+				go func(j *Job) {
+					defer wg.Done()
+					defer func() { <-slots }()
+
+					p.run(jobsCtx, j)
+				}(j)
+			}
+
+			// Claim again immediately if there may be more jobs due.
+			if len(jobs) == free && claimCtx.Err() == nil {
+				continue
+			}
+
+			// Draining is done once there are no more jobs due.
+			if stop == nil {
+				return
+			}
+		}
+
+		select {
+		case <-stop:
+			stop, claimCtx = nil, jobsCtx
+		case <-jobsCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) run(ctx context.Context, j *Job) {
+	const recordTimeout = 5 * time.Second
+
+	jobCtx, cancel := context.WithTimeout(tenant.NewContext(ctx, j.TenantID), p.lease)
+	defer cancel()
+
+	err := p.handle(jobCtx, j)
+
+	// Result is recorded even if the job has been canceled on shutdown.
+	ctx, cancelRecord := context.WithTimeout(tenant.NewContext(context.Background(), j.TenantID), recordTimeout)
+	defer cancelRecord()
+
+	if err == nil {
+		p.runs.WithLabelValues(j.Kind, "ok").Inc()
+
+		if err = p.store.Done(ctx, j.ID); err != nil {
+			p.logger.Error("job done", zap.Int64("id", j.ID), zap.Error(err))
+		}
+
+		return
+	}
+
+	p.logger.Warn("job run", zap.Int64("id", j.ID), zap.String("kind", j.Kind),
+		zap.Int("attempt", j.Attempts+1), zap.Error(err))
+
+	var permanent *PermanentError
+
+	if errors.As(err, &permanent) || j.Attempts+1 >= j.MaxAttempts {
+		p.runs.WithLabelValues(j.Kind, "dead").Inc()
+		err = p.store.Bury(ctx, j.ID, err.Error())
+	} else {
+		p.runs.WithLabelValues(j.Kind, "retry").Inc()
+		err = p.store.Retry(ctx, j.ID, time.Now().Add(Backoff(p.backoff, j.Attempts)), err.Error())
+	}
+
+	if err != nil {
+		p.logger.Error("job record failure", zap.Int64("id", j.ID), zap.Error(err))
+	}
+}
+
+func (p *Pool) handle(ctx context.Context, j *Job) (err error) {
+	h, ok := p.handlers[j.Kind]
+	if !ok {
+		return fmt.Errorf("kind %s: %w", j.Kind, ErrNoHandler)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r) //nolint:goerr113
+		}
+	}()
+
+	return h(ctx, j)
+}
+
+// collectStats periodically updates queue depth and dead jobs gauges until context is canceled.
+func (p *Pool) collectStats(ctx context.Context) {
+	ticker := time.NewTicker(p.statsInterval)
+	defer ticker.Stop()
+
+	for {
+		stats, err := p.store.Stats(ctx)
+		if err != nil && ctx.Err() == nil {
+			p.logger.Warn("job stats", zap.Error(err))
+		}
+
+		if err == nil {
+			// Kinds without jobs are not returned, so gauges are reset first.
+			p.pending.Reset()
+			p.dead.Reset()
+
+			for _, s := range stats {
+				p.pending.WithLabelValues(s.Kind).Set(float64(s.Pending))
+				p.dead.WithLabelValues(s.Kind).Set(float64(s.Dead))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Backoff returns exponentially growing delay with up to 20% random jitter, capped at one day.
+func Backoff(initial time.Duration, attempts int) time.Duration {
+	const maxDelay = 24 * time.Hour
+
+	d := maxDelay
+
+	if attempts <= 30 { //nolint:gomnd
+		if e := initial << attempts; e > 0 && e < maxDelay {
+			d = e
+		}
+	}
+
+	return d + time.Duration(rand.Int63n(int64(d)/5+1)) //nolint:gosec,gomnd
+}
+
+// PoolOption ...
+type PoolOption func(*Pool)
+
+// PoolWorkers sets maximum number of concurrently running jobs.
+func PoolWorkers(n int) PoolOption {
+	return func(p *Pool) {
+		p.workers = n
+	}
+}
+
+// PoolPollInterval sets how often the store is polled for due jobs.
+func PoolPollInterval(d time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.pollInterval = d
+	}
+}
+
+// PoolLease sets how long a claimed job is hidden from other workers. It's also the job timeout.
+func PoolLease(d time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.lease = d
+	}
+}
+
+// PoolStatsInterval sets how often queue depth and dead jobs metrics are updated.
+func PoolStatsInterval(d time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.statsInterval = d
+	}
+}
+
+// PoolShutdownTimeout sets how long due jobs are drained and running jobs may continue once shutdown is
+// activated, before their contexts are canceled. Default is 10 seconds, which leaves time for recording
+// the results within the 20 seconds rest.Server waits for workers to stop.
+func PoolShutdownTimeout(d time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.grace = d
+	}
+}
+
+// PoolBackoff sets delay before the first retry, doubled on each subsequent attempt.
+func PoolBackoff(d time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.backoff = d
+	}
+}
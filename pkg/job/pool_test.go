@@ -0,0 +1,323 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/tenant"
+	"go.uber.org/zap"
+)
+
+var errJob = errors.New("job failed")
+
+// fakeStore hands out pending jobs once and records their results.
+type fakeStore struct {
+	mu      sync.Mutex
+	pending []*Job
+	done    []int64
+	retried map[int64]string
+	buried  map[int64]string
+}
+
+func newFakeStore(jobs ...*Job) *fakeStore {
+	return &fakeStore{ //nolint:exhaustivestruct
+		pending: jobs,
+		retried: make(map[int64]string),
+		buried:  make(map[int64]string),
+	}
+}
+
+func (s *fakeStore) Enqueue(_ context.Context, j *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j.ID = int64(len(s.pending) + 1)
+	s.pending = append(s.pending, j)
+
+	return nil
+}
+
+func (s *fakeStore) Claim(_ context.Context, limit int, _ time.Duration) ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if limit > len(s.pending) {
+		limit = len(s.pending)
+	}
+
+	jobs := s.pending[:limit]
+	s.pending = s.pending[limit:]
+
+	return jobs, nil
+}
+
+func (s *fakeStore) Done(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.done = append(s.done, id)
+
+	return nil
+}
+
+func (s *fakeStore) Retry(ctx context.Context, id int64, _ time.Time, cause string) error {
+	if err := ctx.Err(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.retried[id] = cause
+
+	return nil
+}
+
+func (s *fakeStore) Bury(ctx context.Context, id int64, cause string) error {
+	if err := ctx.Err(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buried[id] = cause
+
+	return nil
+}
+
+func (s *fakeStore) Stats(context.Context) ([]*Stats, error) {
+	return nil, nil
+}
+
+// results returns copies of recorded results.
+func (s *fakeStore) results() (done []int64, retried, buried map[int64]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	retried = make(map[int64]string, len(s.retried))
+	for id, cause := range s.retried {
+		retried[id] = cause
+	}
+
+	buried = make(map[int64]string, len(s.buried))
+	for id, cause := range s.buried {
+		buried[id] = cause
+	}
+
+	return append([]int64(nil), s.done...), retried, buried
+}
+
+type payload struct {
+	Value string `json:"value"`
+}
+
+func newJob(id int64, kind, value string, attempts int) *Job {
+	b, _ := json.Marshal(&payload{Value: value})
+
+	return &Job{
+		ID: id, TenantID: "t", Kind: kind, Payload: b, Priority: 0, RunAt: time.Now(), Attempts: attempts,
+		MaxAttempts: 3,
+	}
+}
+
+func newTestPool(t *testing.T, store Store, opts ...PoolOption) *Pool {
+	t.Helper()
+
+	opts = append([]PoolOption{PoolPollInterval(time.Millisecond)}, opts...)
+
+	return NewPool("test", store, zap.NewNop(), opts...)
+}
+
+// run runs pool until stop is called, which waits for Run to return and fails the test if it takes longer
+// than limit.
+func run(t *testing.T, p *Pool, limit time.Duration) (stop func()) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+
+	go func() {
+		p.Run(ctx)
+		close(stopped)
+	}()
+
+	return func() {
+		cancel()
+
+		select {
+		case <-stopped:
+		case <-time.After(limit):
+			t.Fatalf("pool didn't stop within %s", limit)
+		}
+	}
+}
+
+// waitFor polls condition until it's true or fails the test after a while.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolRunsJobs(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore(
+		newJob(1, "ok", "a", 0),
+		newJob(2, "fail", "b", 0),
+		newJob(3, "fail", "c", 2),
+		newJob(4, "permanent", "d", 0),
+		newJob(5, "panic", "e", 0),
+		newJob(6, "unknown", "f", 0),
+		&Job{ID: 7, TenantID: "", Kind: "ok", Payload: []byte("{"), MaxAttempts: 3}, //nolint:exhaustivestruct
+	)
+	p := newTestPool(t, store)
+
+	Register(p, "ok", func(ctx context.Context, pl *payload) error {
+		if tenant.FromContext(ctx) != "t" || pl.Value != "a" {
+			return errJob
+		}
+
+		return nil
+	})
+	Register(p, "fail", func(context.Context, *payload) error { return errJob })
+	Register(p, "permanent", func(context.Context, *payload) error { return Permanent(errJob) })
+	Register(p, "panic", func(context.Context, *payload) error { panic("boom") })
+
+	stop := run(t, p, 5*time.Second)
+
+	waitFor(t, func() bool {
+		done, retried, buried := store.results()
+
+		return len(done)+len(retried)+len(buried) == 7
+	})
+	stop()
+
+	done, retried, buried := store.results()
+
+	if len(done) != 1 || done[0] != 1 {
+		t.Errorf("done %v, want [1]", done)
+	}
+
+	for _, id := range []int64{2, 5, 6} {
+		if _, ok := retried[id]; !ok {
+			t.Errorf("job %d not retried, retried %v", id, retried)
+		}
+	}
+
+	// Job 3 ran out of attempts, job 4 failed permanently and job 7 has malformed payload.
+	for _, id := range []int64{3, 4, 7} {
+		if _, ok := buried[id]; !ok {
+			t.Errorf("job %d not buried, buried %v", id, buried)
+		}
+	}
+}
+
+func TestPoolShutdownWaitsForRunningJobs(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore(newJob(1, "slow", "a", 0))
+	p := newTestPool(t, store, PoolShutdownTimeout(5*time.Second))
+
+	started := make(chan struct{})
+
+	Register(p, "slow", func(ctx context.Context, _ *payload) error {
+		close(started)
+
+		select {
+		case <-time.After(100 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck
+		}
+	})
+
+	stop := run(t, p, 5*time.Second)
+
+	<-started
+	stop()
+
+	if done, retried, _ := store.results(); len(done) != 1 {
+		t.Errorf("done %v, retried %v, want job finished before Run returned", done, retried)
+	}
+}
+
+func TestPoolShutdownCancelsJobsAfterTimeout(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore(newJob(1, "stuck", "a", 0))
+	p := newTestPool(t, store, PoolShutdownTimeout(50*time.Millisecond), PoolLease(time.Hour))
+
+	started := make(chan struct{})
+
+	Register(p, "stuck", func(ctx context.Context, _ *payload) error {
+		close(started)
+		<-ctx.Done()
+
+		return ctx.Err() //nolint:wrapcheck
+	})
+
+	stop := run(t, p, 5*time.Second)
+
+	<-started
+
+	start := time.Now()
+
+	stop()
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("pool stopped after %s, before shutdown timeout", elapsed)
+	}
+
+	// Canceled job is recorded for retry.
+	if _, retried, _ := store.results(); !strings.Contains(retried[1], context.Canceled.Error()) {
+		t.Errorf("retried %v, want job 1 canceled", retried)
+	}
+}
+
+func TestPoolShutdownDrainsDueJobs(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore(newJob(1, "slow", "a", 0), newJob(2, "slow", "b", 0), newJob(3, "slow", "c", 0))
+	p := newTestPool(t, store, PoolWorkers(1), PoolShutdownTimeout(5*time.Second))
+
+	started := make(chan struct{}, 3)
+
+	Register(p, "slow", func(ctx context.Context, _ *payload) error {
+		started <- struct{}{}
+
+		select {
+		case <-time.After(20 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck
+		}
+	})
+
+	stop := run(t, p, 5*time.Second)
+
+	<-started
+	stop()
+
+	if done, retried, _ := store.results(); len(done) != 3 {
+		t.Errorf("done %v, retried %v, want all jobs finished before Run returned", done, retried)
+	}
+}
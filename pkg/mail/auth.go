@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+var errUnexpectedChallenge = errors.New("unexpected server challenge")
+
+// loginAuth implements LOGIN authentication mechanism, which is not provided by net/smtp.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Same safety rule as smtp.PlainAuth applies.
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection") //nolint:goerr113
+	}
+
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name") //nolint:goerr113
+	}
+
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSuffix(string(fromServer), ":")) {
+	case "username":
+		return []byte(a.username), nil
+	case "password":
+		return []byte(a.password), nil
+	}
+
+	return nil, fmt.Errorf("%q: %w", fromServer, errUnexpectedChallenge)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
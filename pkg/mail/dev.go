@@ -0,0 +1,320 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	_ Sender = (*Log)(nil)
+	_ Sender = (*File)(nil)
+	_ Sender = (*Memory)(nil)
+)
+
+// Log implements Sender interface by logging e-mails instead of sending them. It's meant for development.
+type Log struct {
+	logger *zap.Logger
+}
+
+// NewLog creates new log sender.
+func NewLog(logger *zap.Logger) *Log {
+	return &Log{
+		logger: logger,
+	}
+}
+
+// Send implements Sender interface.
+func (s *Log) Send(ctx context.Context, m *Mail) (*Response, error) {
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	id, err := messageID("localhost")
+	if err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	s.logger.Info("mail",
+		zap.String("id", id),
+		zap.String("from", m.From),
+		zap.String("reply_to", m.ReplyTo),
+		zap.Strings("to", m.To),
+		zap.Strings("cc", m.Cc),
+		zap.Strings("bcc", m.Bcc),
+		zap.String("subject", m.Subject),
+		zap.String("text", m.Text),
+		zap.Int("html_length", len(m.HTML)),
+		zap.Strings("attachments", filenames(m.Attachments)),
+		zap.Strings("inline", filenames(m.Inline)),
+		zap.Strings("tags", m.Tags))
+
+	return &Response{
+		Message:  "logged",
+		ID:       id,
+		Provider: "log",
+	}, nil
+}
+
+// File implements Sender interface by writing e-mails as RFC 5322 .eml files to a directory instead of
+// sending them. Files can be opened by most e-mail clients. It's meant for development.
+type File struct {
+	dir string
+}
+
+// NewFile creates new file sender. Directory is created if it doesn't exist.
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:gomnd
+		return nil, fmt.Errorf("new file sender: %w", err)
+	}
+
+	return &File{
+		dir: dir,
+	}, nil
+}
+
+// Send implements Sender interface. Response ID is the path of written file.
+func (s *File) Send(ctx context.Context, m *Mail) (*Response, error) {
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	msg, err := newMessage(m, "localhost")
+	if err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	// File is renamed once written, so that readers never see partial messages.
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if err = msg.writeTo(f); err != nil {
+		_ = f.Close()
+
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	if err = f.Close(); err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	id := strings.Trim(msg.messageID, "<>")
+	name := filepath.Join(s.dir, fmt.Sprintf("%s-%s.eml", msg.date.UTC().Format("20060102T150405"),
+		id[:strings.Index(id, "@")]))
+
+	if err = os.Rename(f.Name(), name); err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	return &Response{
+		Message:  "written",
+		ID:       name,
+		Provider: "file",
+	}, nil
+}
+
+// Memory implements Sender interface by keeping e-mails in memory, where they can be inspected by tests.
+type Memory struct {
+	mu       sync.Mutex
+	messages []*captured
+}
+
+// captured is a sent e-mail with attachments read in full.
+type captured struct {
+	mail        Mail
+	attachments []attachment
+	inline      []attachment
+}
+
+type attachment struct {
+	filename    string
+	contentType string
+	content     []byte
+}
+
+// NewMemory creates new memory sender.
+func NewMemory() *Memory {
+	return &Memory{} //nolint:exhaustivestruct
+}
+
+// Send implements Sender interface. A copy of the e-mail is kept, with attachments read in full.
+func (s *Memory) Send(ctx context.Context, m *Mail) (*Response, error) {
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	c := &captured{mail: copyMail(m)} //nolint:exhaustivestruct
+
+	var err error
+
+	if c.attachments, err = readAttachments(m.Attachments); err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	if c.inline, err = readAttachments(m.Inline); err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, c)
+
+	return &Response{
+		Message:  "captured",
+		ID:       fmt.Sprint(len(s.messages)),
+		Provider: "memory",
+	}, nil
+}
+
+// Messages returns copies of captured e-mails in order they were sent. Attachments of each copy can be read
+// independently of other copies.
+func (s *Memory) Messages() []*Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Mail, len(s.messages))
+
+	for i, c := range s.messages {
+		list[i] = c.copy()
+	}
+
+	return list
+}
+
+// Last returns copy of the last captured e-mail or nil if there is none.
+func (s *Memory) Last() *Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.messages) == 0 {
+		return nil
+	}
+
+	return s.messages[len(s.messages)-1].copy()
+}
+
+// Wait waits until at least n e-mails are captured, which is useful when they are sent in background.
+// It returns false if timeout elapses first.
+func (s *Memory) Wait(n int, timeout time.Duration) bool {
+	const interval = 10 * time.Millisecond
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		s.mu.Lock()
+		ok := len(s.messages) >= n
+		s.mu.Unlock()
+
+		if ok {
+			return true
+		}
+
+		if time.Now().After(deadline) {
+			return false
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// Reset removes captured e-mails.
+func (s *Memory) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = nil
+}
+
+// copy returns deep copy of captured e-mail with new readers over attachments.
+func (c *captured) copy() *Mail {
+	m := copyMail(&c.mail)
+	m.Attachments = c.attachmentList(c.attachments)
+	m.Inline = c.attachmentList(c.inline)
+
+	return &m
+}
+
+func (c *captured) attachmentList(list []attachment) []*Attachment {
+	if list == nil {
+		return nil
+	}
+
+	copies := make([]*Attachment, len(list))
+
+	for i, a := range list {
+		copies[i] = &Attachment{Filename: a.filename, ContentType: a.contentType, Content: bytes.NewReader(a.content)}
+	}
+
+	return copies
+}
+
+// copyMail copies e-mail without attachments, so that changes to the copy don't affect the original.
+func copyMail(m *Mail) Mail {
+	c := *m
+	c.To = copyStrings(m.To)
+	c.Cc = copyStrings(m.Cc)
+	c.Bcc = copyStrings(m.Bcc)
+	c.Tags = copyStrings(m.Tags)
+	c.Attachments = nil
+	c.Inline = nil
+
+	if m.Headers != nil {
+		c.Headers = make(map[string]string, len(m.Headers))
+
+		for k, v := range m.Headers {
+			c.Headers[k] = v
+		}
+	}
+
+	return c
+}
+
+func copyStrings(list []string) []string {
+	if list == nil {
+		return nil
+	}
+
+	return append([]string(nil), list...)
+}
+
+// readAttachments reads attachments in full, so that captured e-mails don't depend on the original readers.
+func readAttachments(list []*Attachment) ([]attachment, error) {
+	if list == nil {
+		return nil, nil
+	}
+
+	read := make([]attachment, 0, len(list))
+
+	for _, a := range list {
+		b, err := io.ReadAll(a.Content)
+		if err != nil {
+			return nil, fmt.Errorf("read attachment %s: %w", a.Filename, err)
+		}
+
+		read = append(read, attachment{filename: a.Filename, contentType: a.ContentType, content: b})
+	}
+
+	return read, nil
+}
+
+func filenames(list []*Attachment) []string {
+	names := make([]string, len(list))
+
+	for i, a := range list {
+		names[i] = a.Filename
+	}
+
+	return names
+}
@@ -0,0 +1,246 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var errRead = errors.New("read failed")
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errRead
+}
+
+func TestLog(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	m := testMail()
+	m.Attachments = []*Attachment{{Filename: "report.pdf", Content: strings.NewReader("pdf")}} //nolint:exhaustivestruct
+
+	res, err := NewLog(zap.New(core)).Send(context.Background(), m)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	entries := logs.FilterMessage("mail").All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(entries))
+	}
+
+	fields := entries[0].ContextMap()
+
+	if fields["id"] != res.ID || fields["subject"] != m.Subject || fields["text"] != m.Text {
+		t.Errorf("logged fields %v don't match e-mail %v", fields, res)
+	}
+
+	if names, ok := fields["attachments"].([]interface{}); !ok || len(names) != 1 || names[0] != "report.pdf" {
+		t.Errorf("logged attachments %v, want [report.pdf]", fields["attachments"])
+	}
+}
+
+func TestLogInvalid(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	m := testMail()
+	m.To = nil
+
+	if _, err := NewLog(zap.New(core)).Send(context.Background(), m); !errors.Is(err, ErrPermanent) {
+		t.Errorf("error = %v, want ErrPermanent", err)
+	}
+
+	if logs.Len() != 0 {
+		t.Error("invalid e-mail was logged")
+	}
+}
+
+func TestFile(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "mail")
+
+	s, err := NewFile(dir)
+	if err != nil {
+		t.Fatalf("new file sender: %v", err)
+	}
+
+	before := time.Now().UTC().Truncate(time.Second)
+
+	res, err := s.Send(context.Background(), testMail())
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	if filepath.Dir(res.ID) != dir {
+		t.Fatalf("file %s is not in %s", res.ID, dir)
+	}
+
+	// Name is derived from date and the local part of Message-Id.
+	match := regexp.MustCompile(`^(\d{8}T\d{6})-([0-9a-f]{32})\.eml$`).FindStringSubmatch(filepath.Base(res.ID))
+	if match == nil {
+		t.Fatalf("unexpected file name %s", filepath.Base(res.ID))
+	}
+
+	date, err := time.Parse("20060102T150405", match[1])
+	if err != nil || date.Before(before) || date.After(time.Now()) {
+		t.Errorf("file name date %s is not the send time", match[1])
+	}
+
+	b, err := os.ReadFile(res.ID)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("parse file: %v", err)
+	}
+
+	if id := msg.Header.Get("Message-Id"); id != "<"+match[2]+"@localhost>" {
+		t.Errorf("Message-Id = %s, doesn't match file name", id)
+	}
+
+	if subject := msg.Header.Get("Subject"); subject != "Hello" {
+		t.Errorf("Subject = %s, want Hello", subject)
+	}
+
+	assertFiles(t, dir, 1)
+}
+
+func TestFileWriteFailure(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	s, err := NewFile(dir)
+	if err != nil {
+		t.Fatalf("new file sender: %v", err)
+	}
+
+	m := testMail()
+	m.Attachments = []*Attachment{{Filename: "broken.txt", Content: failingReader{}}} //nolint:exhaustivestruct
+
+	if _, err = s.Send(context.Background(), m); !errors.Is(err, errRead) {
+		t.Fatalf("error = %v, want read error", err)
+	}
+
+	// Partially written temporary file is removed.
+	assertFiles(t, dir, 0)
+}
+
+func assertFiles(t *testing.T, dir string, n int) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+
+	if len(entries) != n {
+		t.Fatalf("directory has %d files, want %d", len(entries), n)
+	}
+
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".eml") {
+			t.Errorf("unexpected file %s", e.Name())
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemory()
+
+	if s.Last() != nil {
+		t.Fatal("last e-mail of empty sender isn't nil")
+	}
+
+	for _, subject := range []string{"first", "second"} {
+		m := testMail()
+		m.Subject = subject
+		m.Headers = map[string]string{"X-Test": subject}
+		m.Attachments = []*Attachment{{Filename: "a.txt", Content: strings.NewReader(subject)}} //nolint:exhaustivestruct
+
+		if _, err := s.Send(context.Background(), m); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	if !s.Wait(2, time.Second) {
+		t.Fatal("wait timed out")
+	}
+
+	list := s.Messages()
+	if len(list) != 2 || list[0].Subject != "first" || s.Last().Subject != "second" {
+		t.Fatalf("unexpected messages %v", list)
+	}
+
+	// Every copy can be read in full and changed without affecting captured e-mails.
+	for i := 0; i < 2; i++ {
+		m := s.Last()
+
+		b, err := io.ReadAll(m.Attachments[0].Content)
+		if err != nil || string(b) != "second" {
+			t.Fatalf("attachment = %q, %v, want second", b, err)
+		}
+
+		m.To[0] = "changed@example.com"
+		m.Headers["X-Test"] = "changed"
+		m.Attachments[0].Filename = "changed.txt"
+	}
+
+	if m := s.Last(); m.To[0] != "rcpt@example.com" || m.Headers["X-Test"] != "second" ||
+		m.Attachments[0].Filename != "a.txt" {
+		t.Errorf("captured e-mail was changed through a copy: %v", m)
+	}
+
+	s.Reset()
+
+	if len(s.Messages()) != 0 {
+		t.Error("reset didn't remove messages")
+	}
+
+	if s.Wait(1, 20*time.Millisecond) {
+		t.Error("wait succeeded without messages")
+	}
+}
+
+func TestMemoryKeepsContentOfConsumedReaders(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemory()
+	content := strings.NewReader("data")
+	m := testMail()
+	m.Inline = []*Attachment{{Filename: "logo.png", Content: content}} //nolint:exhaustivestruct
+
+	if _, err := s.Send(context.Background(), m); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	// Original reader is consumed.
+	if n, _ := content.Read(make([]byte, 1)); n != 0 {
+		t.Fatal("original reader wasn't consumed")
+	}
+
+	b, err := io.ReadAll(s.Last().Inline[0].Content)
+	if err != nil || string(b) != "data" {
+		t.Errorf("inline = %q, %v, want data", b, err)
+	}
+}
@@ -0,0 +1,211 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var _ Sender = (*Failover)(nil)
+
+// ErrNoProvider is returned when circuit breakers of all providers are open.
+var ErrNoProvider = errors.New("no available provider")
+
+// Provider is a named sender used by Failover.
+type Provider struct {
+	Name   string
+	Sender Sender
+}
+
+// Failover implements Sender interface by trying providers in order of priority until one accepts the e-mail.
+// Next provider is tried only if the failed one certainly hasn't accepted the e-mail (ErrNotAccepted), like
+// when connection or authentication fails or the request is refused. Failures after which the e-mail may
+// have been accepted, like timeouts or connections closed while waiting for response, are returned without
+// trying other providers, so that the e-mail isn't delivered twice. Permanent failures other than
+// authentication are returned too, since other providers would reject the e-mail as well.
+//
+// Each provider has a circuit breaker which opens after consecutive failures, so that unavailable provider
+// is skipped until open timeout elapses and a single trial send is let through.
+type Failover struct {
+	providers        []*provider
+	failureThreshold int
+	openTimeout      time.Duration
+	logger           *zap.Logger
+}
+
+type provider struct {
+	Provider
+	breaker *breaker
+}
+
+// NewFailover creates new failover sender.
+func NewFailover(providers []Provider, logger *zap.Logger, opts ...FailoverOption) *Failover {
+	f := &Failover{
+		providers:        make([]*provider, 0, len(providers)),
+		failureThreshold: 3,               //nolint:gomnd
+		openTimeout:      1 * time.Minute, //nolint:gomnd
+		logger:           logger,
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	for _, p := range providers {
+		f.providers = append(f.providers, &provider{
+			Provider: p,
+			breaker:  &breaker{threshold: f.failureThreshold, timeout: f.openTimeout}, //nolint:exhaustivestruct
+		})
+	}
+
+	return f
+}
+
+// Send implements Sender interface. Response's Provider is set to the name of provider which accepted
+// the e-mail.
+func (f *Failover) Send(ctx context.Context, m *Mail) (*Response, error) {
+	var lastErr error
+
+	for _, p := range f.providers {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if !p.breaker.allow() {
+			continue
+		}
+
+		res, err := p.Sender.Send(ctx, m)
+		if err == nil {
+			p.breaker.success()
+			res.Provider = p.Name
+
+			return res, nil
+		}
+
+		err = fmt.Errorf("%s: %w", p.Name, err)
+
+		switch {
+		case ctx.Err() != nil:
+			// Provider is not to blame for cancellation.
+			p.breaker.release()
+
+			return nil, err
+		case errors.Is(err, ErrPermanent) && !errors.Is(err, ErrAuth):
+			// Provider works, it's the e-mail which is rejected.
+			p.breaker.success()
+
+			return nil, err
+		}
+
+		f.failure(p, err)
+
+		if !errors.Is(err, ErrNotAccepted) {
+			return nil, err
+		}
+
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("send mail: %w", err)
+		}
+
+		return nil, fmt.Errorf("send mail: %w", ErrNoProvider)
+	}
+
+	return nil, lastErr
+}
+
+func (f *Failover) failure(p *provider, err error) {
+	if p.breaker.failure() {
+		f.logger.Warn("mail provider circuit open", zap.String("provider", p.Name), zap.Error(err))
+	} else {
+		f.logger.Warn("mail provider failed", zap.String("provider", p.Name), zap.Error(err))
+	}
+}
+
+// breaker is a circuit breaker. Zero value is closed.
+type breaker struct {
+	threshold int
+	timeout   time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// allow reports whether a call may proceed. After open timeout elapses a single trial call is allowed.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+
+	if b.trial || time.Since(b.openedAt) < b.timeout {
+		return false
+	}
+
+	b.trial = true
+
+	return true
+}
+
+// release ends trial call without recording its result, so that another trial may be made.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+}
+
+// failure records failed call and reports whether the breaker has been opened by it.
+func (b *breaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+
+	if b.failures < b.threshold {
+		return false
+	}
+
+	// Failed trial call opens breaker again.
+	b.openedAt = time.Now()
+	b.trial = false
+
+	return true
+}
+
+// FailoverOption ...
+type FailoverOption func(*Failover)
+
+// FailoverFailureThreshold sets number of consecutive transient failures opening provider's circuit.
+func FailoverFailureThreshold(n int) FailoverOption {
+	return func(f *Failover) {
+		f.failureThreshold = n
+	}
+}
+
+// FailoverOpenTimeout sets how long a provider with open circuit is skipped before a trial send.
+func FailoverOpenTimeout(d time.Duration) FailoverOption {
+	return func(f *Failover) {
+		f.openTimeout = d
+	}
+}
@@ -0,0 +1,267 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeSender returns queued errors, one per call, and succeeds once they run out.
+type fakeSender struct {
+	mu    sync.Mutex
+	errs  []error
+	calls int
+}
+
+func (s *fakeSender) Send(context.Context, *Mail) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Response{Message: "queued", ID: fmt.Sprint(s.calls), Provider: "fake"}, nil
+}
+
+func (s *fakeSender) fail(errs ...error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errs = append(s.errs, errs...)
+}
+
+func (s *fakeSender) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls
+}
+
+var (
+	errUnavailable = notAccepted(errors.New("connection refused"))            //nolint:goerr113
+	errBadPassword = notAccepted(permanent(mark(errors.New("535"), ErrAuth))) //nolint:goerr113
+	errRejected    = notAccepted(permanent(errors.New("550 user unknown")))   //nolint:goerr113
+	errNoResponse  = fmt.Errorf("end of data: %w", io.ErrUnexpectedEOF)
+)
+
+func newTestFailover(opts ...FailoverOption) (*Failover, *fakeSender, *fakeSender) {
+	primary, secondary := &fakeSender{}, &fakeSender{} //nolint:exhaustivestruct
+
+	f := NewFailover([]Provider{{Name: "primary", Sender: primary}, {Name: "secondary", Sender: secondary}},
+		zap.NewNop(), opts...)
+
+	return f, primary, secondary
+}
+
+func TestFailoverClassification(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err       error
+		failover  bool
+		wantErrIs error
+	}{
+		"not accepted":    {err: errUnavailable, failover: true},
+		"auth failure":    {err: errBadPassword, failover: true},
+		"rejected e-mail": {err: errRejected, failover: false, wantErrIs: ErrPermanent},
+		"indeterminate":   {err: errNoResponse, failover: false, wantErrIs: io.ErrUnexpectedEOF},
+		"deadline": {
+			err: fmt.Errorf("end of data: %w", context.DeadlineExceeded), failover: false,
+			wantErrIs: context.DeadlineExceeded,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			f, primary, secondary := newTestFailover()
+			primary.fail(tc.err)
+
+			res, err := f.Send(context.Background(), testMail())
+
+			if tc.failover {
+				if err != nil || res.Provider != "secondary" {
+					t.Fatalf("send = %v, %v, want sent by secondary", res, err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, tc.wantErrIs) {
+				t.Errorf("error = %v, want %v", err, tc.wantErrIs)
+			}
+
+			if secondary.count() != 0 {
+				t.Error("secondary provider was tried")
+			}
+		})
+	}
+}
+
+func TestFailoverAllProvidersFail(t *testing.T) {
+	t.Parallel()
+
+	f, primary, secondary := newTestFailover()
+	primary.fail(errUnavailable)
+	secondary.fail(errBadPassword)
+
+	_, err := f.Send(context.Background(), testMail())
+	if !errors.Is(err, ErrAuth) {
+		t.Errorf("error = %v, want the last provider's error", err)
+	}
+}
+
+func TestFailoverBreaker(t *testing.T) {
+	t.Parallel()
+
+	const timeout = 50 * time.Millisecond
+
+	f, primary, secondary := newTestFailover(FailoverFailureThreshold(2), FailoverOpenTimeout(timeout))
+
+	send := func(want string) {
+		t.Helper()
+
+		res, err := f.Send(context.Background(), testMail())
+		if err != nil || res.Provider != want {
+			t.Fatalf("send = %v, %v, want sent by %s", res, err, want)
+		}
+	}
+
+	// Two consecutive failures open the circuit.
+	primary.fail(errUnavailable, errUnavailable)
+	send("secondary")
+	send("secondary")
+
+	// Open circuit skips primary provider.
+	send("secondary")
+
+	if primary.count() != 2 {
+		t.Fatalf("primary called %d times, want 2", primary.count())
+	}
+
+	// Failed trial after open timeout opens the circuit again.
+	time.Sleep(timeout)
+	primary.fail(errUnavailable)
+	send("secondary")
+	send("secondary")
+
+	if primary.count() != 3 {
+		t.Fatalf("primary called %d times, want 3", primary.count())
+	}
+
+	// Successful trial closes the circuit.
+	time.Sleep(timeout)
+	send("primary")
+	send("primary")
+
+	if secondary.count() != 5 {
+		t.Errorf("secondary called %d times, want 5", secondary.count())
+	}
+}
+
+func TestFailoverBreakerIgnoresRejectedEmails(t *testing.T) {
+	t.Parallel()
+
+	f, primary, _ := newTestFailover(FailoverFailureThreshold(1))
+	primary.fail(errRejected)
+
+	if _, err := f.Send(context.Background(), testMail()); !errors.Is(err, ErrPermanent) {
+		t.Fatalf("error = %v, want ErrPermanent", err)
+	}
+
+	if res, err := f.Send(context.Background(), testMail()); err != nil || res.Provider != "primary" {
+		t.Errorf("send = %v, %v, want sent by primary", res, err)
+	}
+}
+
+func TestFailoverNoProvider(t *testing.T) {
+	t.Parallel()
+
+	f, primary, secondary := newTestFailover(FailoverFailureThreshold(1), FailoverOpenTimeout(time.Hour))
+	primary.fail(errUnavailable)
+	secondary.fail(errUnavailable)
+
+	if _, err := f.Send(context.Background(), testMail()); !errors.Is(err, ErrNotAccepted) {
+		t.Fatalf("error = %v, want ErrNotAccepted", err)
+	}
+
+	if _, err := f.Send(context.Background(), testMail()); !errors.Is(err, ErrNoProvider) {
+		t.Errorf("error = %v, want ErrNoProvider", err)
+	}
+}
+
+func TestBreakerHalfOpenAllowsSingleTrial(t *testing.T) {
+	t.Parallel()
+
+	b := &breaker{threshold: 1, timeout: time.Millisecond} //nolint:exhaustivestruct
+
+	if !b.allow() {
+		t.Fatal("closed breaker doesn't allow calls")
+	}
+
+	if !b.failure() {
+		t.Fatal("failure didn't open breaker")
+	}
+
+	if b.allow() {
+		t.Fatal("open breaker allows calls")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	if !b.allow() {
+		t.Fatal("half-open breaker doesn't allow trial")
+	}
+
+	if b.allow() {
+		t.Fatal("half-open breaker allows concurrent trial")
+	}
+
+	b.release()
+
+	if !b.allow() {
+		t.Fatal("released trial isn't allowed again")
+	}
+
+	b.success()
+
+	if !b.allow() || !b.allow() {
+		t.Error("closed breaker doesn't allow calls")
+	}
+}
+
+func TestFailoverCanceled(t *testing.T) {
+	t.Parallel()
+
+	f, primary, secondary := newTestFailover(FailoverFailureThreshold(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	primary.fail(errUnavailable)
+
+	if _, err := f.Send(ctx, testMail()); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+
+	if primary.count()+secondary.count() != 0 {
+		t.Error("provider was called with canceled context")
+	}
+}
@@ -3,7 +3,13 @@ package mail
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 
 	"github.com/mailgun/mailgun-go/v4"
 )
@@ -24,15 +30,116 @@ func NewMailgun(mg mailgun.Mailgun) *Mailgun {
 
 // Send implements Sender interface.
 func (m *Mailgun) Send(ctx context.Context, message *Mail) (*Response, error) {
-	msg := m.mg.NewMessage(message.From, message.Subject, message.Text, message.To...)
+	msg, err := m.message(message)
+	if err != nil {
+		return nil, fmt.Errorf("send mail: %w", notAccepted(err))
+	}
 
 	res, id, err := m.mg.Send(ctx, msg)
 	if err != nil {
-		return nil, fmt.Errorf("send mail: %w", err)
+		return nil, fmt.Errorf("send mail: %w", classifyMailgunError(err))
 	}
 
 	return &Response{
-		Message: res,
-		ID:      id,
+		Message:  res,
+		ID:       id,
+		Provider: "mailgun",
 	}, nil
 }
+
+// message maps Mail to Mailgun message.
+func (m *Mailgun) message(message *Mail) (*mailgun.Message, error) {
+	if err := message.Validate(); err != nil {
+		return nil, err
+	}
+
+	msg := m.mg.NewMessage(message.From, message.Subject, message.Text, message.To...)
+
+	if message.HTML != "" {
+		msg.SetHtml(message.HTML)
+	}
+
+	if message.ReplyTo != "" {
+		msg.SetReplyTo(message.ReplyTo)
+	}
+
+	for _, cc := range message.Cc {
+		msg.AddCC(cc)
+	}
+
+	for _, bcc := range message.Bcc {
+		msg.AddBCC(bcc)
+	}
+
+	for name, value := range message.Headers {
+		msg.AddHeader(name, value)
+	}
+
+	if len(message.Tags) > 0 {
+		if err := msg.AddTag(message.Tags...); err != nil {
+			return nil, fmt.Errorf("tags: %w", err)
+		}
+	}
+
+	for _, a := range message.Attachments {
+		msg.AddReaderAttachment(a.Filename, readCloser(a.Content))
+	}
+
+	// Mailgun uses file name as Content-ID.
+	for _, a := range message.Inline {
+		msg.AddReaderInline(ContentID(a.Filename), readCloser(a.Content))
+	}
+
+	return msg, nil
+}
+
+// classifyMailgunError marks errors caused by rejected requests as permanent. Authorization failures are
+// treated as transient, so that e-mails are delivered once configuration is fixed. Error responses and
+// failures before the request could be sent are marked as not accepted, while other transport errors are
+// left unmarked, since Mailgun may have accepted the e-mail before the connection broke.
+func classifyMailgunError(err error) error {
+	var ue *mailgun.UnexpectedResponseError
+
+	switch {
+	case errors.As(err, &ue):
+		switch ue.Actual {
+		case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+			err = permanent(err)
+		case http.StatusUnauthorized, http.StatusForbidden:
+			err = mark(err, ErrAuth)
+		}
+
+		return notAccepted(err)
+	case errors.Is(err, mailgun.ErrInvalidMessage):
+		return notAccepted(permanent(err))
+	case notSent(err):
+		return notAccepted(err)
+	}
+
+	return err
+}
+
+// notSent reports whether error occurred before the request could be sent, while resolving host name,
+// connecting or during TLS handshake.
+func notSent(err error) bool {
+	var (
+		dnsErr       *net.DNSError
+		opErr        *net.OpError
+		recordErr    tls.RecordHeaderError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+
+	return errors.As(err, &dnsErr) || (errors.As(err, &opErr) && opErr.Op == "dial") ||
+		errors.As(err, &recordErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
+
+func readCloser(r io.Reader) io.ReadCloser {
+	if rc, ok := r.(io.ReadCloser); ok {
+		return rc
+	}
+
+	return io.NopCloser(r)
+}
@@ -0,0 +1,106 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mailgun/mailgun-go/v4"
+)
+
+func newTestMailgun(url string) *Mailgun {
+	mg := mailgun.NewMailgun("example.com", "key")
+	mg.SetAPIBase(url + "/v3")
+
+	return NewMailgun(mg)
+}
+
+func TestMailgunClassification(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		handler     http.HandlerFunc
+		permanent   bool
+		auth        bool
+		notAccepted bool
+	}{
+		"bad request": {
+			handler:   func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusBadRequest) },
+			permanent: true, notAccepted: true,
+		},
+		"unauthorized": {
+			handler: func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusUnauthorized) },
+			auth:    true, notAccepted: true,
+		},
+		"unavailable": {
+			handler:     func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) },
+			notAccepted: true,
+		},
+		"connection closed after request": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_ = r.ParseMultipartForm(1 << 20)
+
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					_ = conn.Close()
+				}
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(tc.handler)
+			t.Cleanup(srv.Close)
+
+			_, err := newTestMailgun(srv.URL).Send(context.Background(), testMail())
+			if err == nil {
+				t.Fatal("send succeeded")
+			}
+
+			if errors.Is(err, ErrPermanent) != tc.permanent || errors.Is(err, ErrAuth) != tc.auth ||
+				errors.Is(err, ErrNotAccepted) != tc.notAccepted {
+				t.Errorf("error %v: permanent %t, auth %t, not accepted %t, want %t, %t, %t", err,
+					errors.Is(err, ErrPermanent), errors.Is(err, ErrAuth), errors.Is(err, ErrNotAccepted),
+					tc.permanent, tc.auth, tc.notAccepted)
+			}
+		})
+	}
+}
+
+func TestMailgunDialFailure(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	_, err = newTestMailgun("http://"+addr).Send(context.Background(), testMail())
+	if !errors.Is(err, ErrNotAccepted) || errors.Is(err, ErrPermanent) {
+		t.Errorf("error = %v, want transient ErrNotAccepted", err)
+	}
+}
+
+func TestMailgunTLSFailure(t *testing.T) {
+	t.Parallel()
+
+	// Server certificate is not trusted by the client.
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	_, err := newTestMailgun(srv.URL).Send(context.Background(), testMail())
+	if !errors.Is(err, ErrNotAccepted) {
+		t.Errorf("error = %v, want ErrNotAccepted", err)
+	}
+}
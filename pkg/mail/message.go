@@ -0,0 +1,456 @@
+package mail
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// message renders RFC 5322 message. Non-ASCII subject, display names and custom header values are encoded
+// as RFC 2047 encoded words, bodies are quoted-printable and attachments base64. If both text and HTML
+// body are set, they are sent as multipart/alternative, inline files are added to it as multipart/related
+// and attachments wrap everything in multipart/mixed.
+type message struct {
+	mail      *Mail
+	subject   string
+	headers   []field
+	from      *mail.Address
+	to        []*mail.Address
+	cc        []*mail.Address
+	bcc       []*mail.Address
+	replyTo   *mail.Address
+	messageID string
+	date      time.Time
+}
+
+func newMessage(m *Mail, domain string) (*message, error) {
+	if err := m.validateHeaders(); err != nil {
+		return nil, err
+	}
+
+	msg := &message{ //nolint:exhaustivestruct
+		mail: m,
+		date: time.Now(),
+	}
+
+	var err error
+
+	if msg.from, err = mail.ParseAddress(m.From); err != nil {
+		return nil, fmt.Errorf("from: %w", err)
+	}
+
+	if m.ReplyTo != "" {
+		if msg.replyTo, err = mail.ParseAddress(m.ReplyTo); err != nil {
+			return nil, fmt.Errorf("reply-to: %w", err)
+		}
+	}
+
+	if msg.to, err = parseAddresses(m.To); err != nil {
+		return nil, fmt.Errorf("to: %w", err)
+	}
+
+	if msg.cc, err = parseAddresses(m.Cc); err != nil {
+		return nil, fmt.Errorf("cc: %w", err)
+	}
+
+	if msg.bcc, err = parseAddresses(m.Bcc); err != nil {
+		return nil, fmt.Errorf("bcc: %w", err)
+	}
+
+	if len(msg.to)+len(msg.cc)+len(msg.bcc) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	if msg.messageID, err = messageID(domain); err != nil {
+		return nil, err
+	}
+
+	if msg.subject, err = encodeHeader("Subject", m.Subject); err != nil {
+		return nil, err
+	}
+
+	if msg.headers, err = encodeHeaders(m.Headers); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// field is an encoded header field.
+type field struct {
+	name  string
+	value string
+}
+
+// encodeHeaders encodes custom headers sorted by name.
+func encodeHeaders(headers map[string]string) ([]field, error) {
+	fields := make([]field, 0, len(headers))
+
+	for name, value := range headers {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+
+		encoded, err := encodeHeader(name, value)
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, field{name: name, value: encoded})
+	}
+
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+
+	return fields, nil
+}
+
+// encodeHeader encodes non-ASCII value as RFC 2047 encoded words and folds it between words, so that
+// lines don't exceed 78 characters where possible. Values which can't be folded under the 998 characters
+// limit of RFC 5322 are rejected.
+func encodeHeader(name, value string) (string, error) {
+	const (
+		foldLength    = 78
+		maxLineLength = 998
+	)
+
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("%s: line break: %w", name, ErrInvalidHeader)
+	}
+
+	var sb strings.Builder
+
+	line := len(name) + len(": ")
+
+	// Encoded words are separated by spaces too, so folding before a space keeps the value intact.
+	for i, word := range strings.Split(mime.QEncoding.Encode("utf-8", value), " ") {
+		if i > 0 {
+			// Lines consisting of whitespace only are not allowed, so empty words are not folded.
+			if word != "" && line+1+len(word) > foldLength {
+				sb.WriteString("\r\n")
+
+				line = 0
+			}
+
+			sb.WriteByte(' ')
+
+			line++
+		}
+
+		sb.WriteString(word)
+
+		if line += len(word); line > maxLineLength {
+			return "", fmt.Errorf("%s: line too long: %w", name, ErrInvalidHeader)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// recipients returns envelope recipients.
+func (m *message) recipients() []string {
+	rcpts := make([]string, 0, len(m.to)+len(m.cc)+len(m.bcc))
+
+	for _, list := range [][]*mail.Address{m.to, m.cc, m.bcc} {
+		for _, a := range list {
+			rcpts = append(rcpts, a.Address)
+		}
+	}
+
+	return rcpts
+}
+
+// writeTo writes message to w. Attachments are streamed from their readers.
+func (m *message) writeTo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	header := func(name, value string) {
+		fmt.Fprintf(bw, "%s: %s\r\n", name, value)
+	}
+
+	header("Date", m.date.Format(time.RFC1123Z))
+	header("From", m.from.String())
+
+	if len(m.to) > 0 {
+		header("To", joinAddresses(m.to))
+	}
+
+	if len(m.cc) > 0 {
+		header("Cc", joinAddresses(m.cc))
+	}
+
+	if m.replyTo != nil {
+		header("Reply-To", m.replyTo.String())
+	}
+
+	header("Subject", m.subject)
+	header("Message-ID", m.messageID)
+
+	for _, f := range m.headers {
+		header(f.name, f.value)
+	}
+
+	header("MIME-Version", "1.0")
+
+	if err := m.writeMixed(bw); err != nil {
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+
+	return nil
+}
+
+// part writes content headers followed by a blank line and the part's content.
+type part func(w io.Writer) error
+
+func (m *message) writeMixed(w io.Writer) error {
+	if len(m.mail.Attachments) == 0 {
+		return m.writeRelated(w)
+	}
+
+	parts := []part{m.writeRelated}
+
+	for _, a := range m.mail.Attachments {
+		parts = append(parts, filePart(a, "attachment"))
+	}
+
+	return writeMultipart(w, "mixed", parts)
+}
+
+func (m *message) writeRelated(w io.Writer) error {
+	if len(m.mail.Inline) == 0 {
+		return m.writeAlternative(w)
+	}
+
+	parts := []part{m.writeAlternative}
+
+	for _, a := range m.mail.Inline {
+		parts = append(parts, filePart(a, "inline"))
+	}
+
+	return writeMultipart(w, "related", parts)
+}
+
+func (m *message) writeAlternative(w io.Writer) error {
+	switch {
+	case m.mail.HTML == "":
+		return textPart("text/plain", m.mail.Text)(w)
+	case m.mail.Text == "":
+		return textPart("text/html", m.mail.HTML)(w)
+	}
+
+	// Parts are ordered by increasing preference, so clients able to display HTML show it.
+	return writeMultipart(w, "alternative", []part{
+		textPart("text/plain", m.mail.Text),
+		textPart("text/html", m.mail.HTML),
+	})
+}
+
+// writeMultipart writes multipart content type header and parts. Boundaries are written here instead of
+// using multipart.Writer, because parts write their own headers, so that they can be nested.
+func writeMultipart(w io.Writer, subtype string, parts []part) error {
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+
+	if _, err := fmt.Fprintf(w, "Content-Type: multipart/%s; boundary=%q\r\n\r\n", subtype, boundary); err != nil {
+		return fmt.Errorf("write multipart: %w", err)
+	}
+
+	for _, p := range parts {
+		if _, err := fmt.Fprintf(w, "--%s\r\n", boundary); err != nil {
+			return fmt.Errorf("write multipart: %w", err)
+		}
+
+		if err := p(w); err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, "\r\n"); err != nil {
+			return fmt.Errorf("write multipart: %w", err)
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "--%s--\r\n", boundary); err != nil {
+		return fmt.Errorf("write multipart: %w", err)
+	}
+
+	return nil
+}
+
+func textPart(contentType, body string) part {
+	return func(w io.Writer) error {
+		fmt.Fprintf(w, "Content-Type: %s; charset=\"utf-8\"\r\n", contentType)
+		fmt.Fprint(w, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+		qw := quotedprintable.NewWriter(w)
+
+		// Line breaks are normalized to CRLF as required on the wire.
+		body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+
+		if _, err := io.WriteString(qw, body); err != nil {
+			return fmt.Errorf("encode body: %w", err)
+		}
+
+		if err := qw.Close(); err != nil {
+			return fmt.Errorf("encode body: %w", err)
+		}
+
+		return nil
+	}
+}
+
+func filePart(a *Attachment, disposition string) part {
+	return func(w io.Writer) error {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(a.Filename))
+		}
+
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return fmt.Errorf("attachment %s: %w", a.Filename, err)
+		}
+
+		// Parameters are quoted and encoded as needed, so that file names can't break the headers.
+		params["name"] = a.Filename
+
+		fmt.Fprintf(w, "Content-Type: %s\r\n", mime.FormatMediaType(mediaType, params))
+		fmt.Fprintf(w, "Content-Disposition: %s\r\n",
+			mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+
+		if disposition == "inline" {
+			fmt.Fprintf(w, "Content-ID: <%s>\r\n", ContentID(a.Filename))
+		}
+
+		fmt.Fprint(w, "Content-Transfer-Encoding: base64\r\n\r\n")
+
+		enc := base64.NewEncoder(base64.StdEncoding, &lineWrapper{w: w, n: 0})
+
+		if _, err := io.Copy(enc, a.Content); err != nil {
+			return fmt.Errorf("attachment %s: %w", a.Filename, err)
+		}
+
+		if err := enc.Close(); err != nil {
+			return fmt.Errorf("attachment %s: %w", a.Filename, err)
+		}
+
+		return nil
+	}
+}
+
+// lineWrapper breaks lines after 76 characters, as required for base64 encoded content.
+type lineWrapper struct {
+	w io.Writer
+	n int
+}
+
+func (lw *lineWrapper) Write(p []byte) (int, error) {
+	const maxLineLength = 76
+
+	written := 0
+
+	for len(p) > 0 {
+		if lw.n == maxLineLength {
+			if _, err := io.WriteString(lw.w, "\r\n"); err != nil {
+				return written, err //nolint:wrapcheck
+			}
+
+			lw.n = 0
+		}
+
+		chunk := p
+		if l := maxLineLength - lw.n; len(chunk) > l {
+			chunk = chunk[:l]
+		}
+
+		n, err := lw.w.Write(chunk)
+		written += n
+		lw.n += n
+
+		if err != nil {
+			return written, err //nolint:wrapcheck
+		}
+
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+func parseAddresses(list []string) ([]*mail.Address, error) {
+	addrs := make([]*mail.Address, 0, len(list))
+
+	for _, s := range list {
+		a, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", s, err)
+		}
+
+		addrs = append(addrs, a)
+	}
+
+	return addrs, nil
+}
+
+func joinAddresses(addrs []*mail.Address) string {
+	s := make([]string, len(addrs))
+
+	for i, a := range addrs {
+		s[i] = a.String()
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// ContentID returns the Content-ID of inline file, which HTML body refers to as cid:ContentID, or using cid
+// template function. File names consisting of letters, digits, dots, hyphens and underscores are used as
+// they are, the same way Mailgun does it. Other characters are replaced by underscores and a hash of the
+// file name is appended, so that different files don't get the same Content-ID.
+func ContentID(filename string) string {
+	safe := func(r rune) bool {
+		return r < utf8.RuneSelf && (r == '.' || r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+	}
+
+	if filename != "" && strings.IndexFunc(filename, func(r rune) bool { return !safe(r) }) < 0 {
+		return filename
+	}
+
+	sanitized := strings.Map(func(r rune) rune {
+		if safe(r) {
+			return r
+		}
+
+		return '_'
+	}, filename)
+	sum := sha256.Sum256([]byte(filename))
+
+	return sanitized + "." + hex.EncodeToString(sum[:4])
+}
+
+func messageID(domain string) (string, error) {
+	b := make([]byte, 16) //nolint:gomnd
+
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("message id: %w", err)
+	}
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain), nil
+}
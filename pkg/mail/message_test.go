@@ -0,0 +1,283 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/text/language"
+)
+
+func TestEncodeHeaderFolds(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"short":     "Hello",
+		"non-ascii": strings.Repeat("Здраво свете, ", 40),
+		"ascii":     strings.Repeat("Hello world ", 60),
+		"spaces":    "a  b   " + strings.Repeat("c", 70) + "  d",
+		"long word": strings.Repeat("x", 900),
+		"mixed":     strings.Repeat("Grüße aus München ", 30),
+	}
+
+	for name, value := range tests {
+		encoded, err := encodeHeader("Subject", value)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+
+			continue
+		}
+
+		for i, line := range strings.Split("Subject: "+encoded, "\r\n") {
+			if len(line) > 998 {
+				t.Errorf("%s: line %d has %d characters", name, i, len(line))
+			}
+
+			if len(line) > 78 && strings.Contains(strings.TrimSpace(strings.TrimPrefix(line, "Subject: ")), " ") {
+				t.Errorf("%s: line %d has %d characters, but could be folded", name, i, len(line))
+			}
+
+			if i > 0 && strings.TrimSpace(line) == "" {
+				t.Errorf("%s: line %d consists of whitespace only", name, i)
+			}
+		}
+
+		decoded, err := (&mime.WordDecoder{}).DecodeHeader(strings.ReplaceAll(encoded, "\r\n", "")) //nolint:exhaustivestruct
+		if err != nil {
+			t.Errorf("%s: decode: %v", name, err)
+		}
+
+		if decoded != value {
+			t.Errorf("%s: decoded %q, want %q", name, decoded, value)
+		}
+	}
+}
+
+func TestEncodeHeaderRejects(t *testing.T) {
+	t.Parallel()
+
+	for name, value := range map[string]string{
+		"line break": "Hello\r\nBcc: victim@example.com",
+		"too long":   strings.Repeat("x", 1000),
+	} {
+		if _, err := encodeHeader("Subject", value); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("%s: error = %v, want ErrInvalidHeader", name, err)
+		}
+	}
+}
+
+func TestMessageHeaders(t *testing.T) {
+	t.Parallel()
+
+	subject := strings.Repeat("Поздрав из Београда ", 20)
+
+	m := testMail()
+	m.Subject = subject
+	m.Headers = map[string]string{"x-campaign": strings.Repeat("ünïcödé ", 30)}
+
+	msg, err := newMessage(m, "example.com")
+	if err != nil {
+		t.Fatalf("new message: %v", err)
+	}
+
+	b := &bytes.Buffer{}
+	if err = msg.writeTo(b); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	parsed, err := mail.ReadMessage(b)
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+
+	dec := &mime.WordDecoder{} //nolint:exhaustivestruct
+
+	if got, _ := dec.DecodeHeader(parsed.Header.Get("Subject")); got != subject {
+		t.Errorf("subject = %q, want %q", got, subject)
+	}
+
+	if got, _ := dec.DecodeHeader(parsed.Header.Get("X-Campaign")); got != m.Headers["x-campaign"] {
+		t.Errorf("x-campaign = %q, want %q", got, m.Headers["x-campaign"])
+	}
+}
+
+func TestContentID(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"logo.png":       "logo.png",
+		"my_logo-2.png":  "my_logo-2.png",
+		"my logo.png":    "my_logo.png.",
+		"my@logo.png":    "my_logo.png.",
+		"<x>.png":        "_x_.png.",
+		"лого.png":       "____.png.",
+		"":               ".",
+		"a/../../b.png":  "a_.._.._b.png.",
+		"a\r\nb:.png":    "a__b_.png.",
+		"photo (1).jpeg": "photo__1_.jpeg.",
+	}
+
+	seen := make(map[string]string)
+
+	for filename, prefix := range tests {
+		id := ContentID(filename)
+
+		if !strings.HasPrefix(id, prefix) || (strings.HasSuffix(prefix, ".") && len(id) != len(prefix)+8) {
+			t.Errorf("ContentID(%q) = %q, want %q followed by hash", filename, id, prefix)
+		}
+
+		if strings.ContainsAny(id, " <>@\r\n:/\"") {
+			t.Errorf("ContentID(%q) = %q contains unsafe characters", filename, id)
+		}
+
+		if other, ok := seen[id]; ok {
+			t.Errorf("ContentID(%q) = ContentID(%q)", filename, other)
+		}
+
+		seen[id] = filename
+	}
+
+	if ContentID("a b.png") == ContentID("a_b.png") {
+		t.Error("sanitized file name collides with the safe one")
+	}
+}
+
+func TestMessageInlineContentID(t *testing.T) {
+	t.Parallel()
+
+	m := testMail()
+	m.HTML = `<img src="cid:x">`
+	m.Inline = []*Attachment{{Filename: "my logo.png", ContentType: "", Content: strings.NewReader("png")}}
+
+	msg, err := newMessage(m, "example.com")
+	if err != nil {
+		t.Fatalf("new message: %v", err)
+	}
+
+	b := &bytes.Buffer{}
+	if err = msg.writeTo(b); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if want := "Content-ID: <" + ContentID("my logo.png") + ">\r\n"; !strings.Contains(b.String(), want) {
+		t.Errorf("message doesn't contain %q:\n%s", want, b.String())
+	}
+}
+
+func TestTemplatesCID(t *testing.T) {
+	t.Parallel()
+
+	templates, err := NewTemplates(fstest.MapFS{
+		"welcome.html.tmpl": {Data: []byte(`<img src="{{cid .}}">`)}, //nolint:exhaustivestruct
+	})
+	if err != nil {
+		t.Fatalf("new templates: %v", err)
+	}
+
+	m := &Mail{} //nolint:exhaustivestruct
+
+	if err = templates.Render(m, "welcome", language.English, "my logo.png"); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	if want := `<img src="cid:` + ContentID("my logo.png") + `">`; m.HTML != want {
+		t.Errorf("html = %q, want %q", m.HTML, want)
+	}
+}
+
+func TestValidateRejectsContentType(t *testing.T) {
+	t.Parallel()
+
+	for name, contentType := range map[string]string{
+		"line break":       "text/plain\r\nBcc: victim@example.com",
+		"line feed":        "text/plain; charset=utf-8\nBcc: victim@example.com",
+		"invalid":          "text/plain; charset",
+		"inline injection": "image/png\r\n\r\n<script>",
+	} {
+		m := testMail()
+		a := &Attachment{Filename: "a.txt", ContentType: contentType, Content: strings.NewReader("a")}
+
+		if name == "inline injection" {
+			m.Inline = []*Attachment{a}
+		} else {
+			m.Attachments = []*Attachment{a}
+		}
+
+		if err := m.Validate(); !errors.Is(err, ErrInvalidHeader) || !errors.Is(err, ErrPermanent) {
+			t.Errorf("%s: error = %v, want permanent ErrInvalidHeader", name, err)
+		}
+	}
+}
+
+func TestMessageAttachmentHeaders(t *testing.T) {
+	t.Parallel()
+
+	m := testMail()
+	m.Attachments = []*Attachment{
+		{Filename: `quote "final".txt`, ContentType: "text/plain; charset=utf-8", Content: strings.NewReader("a")},
+		{Filename: "понуда.pdf", ContentType: "", Content: strings.NewReader("b")},
+		{Filename: "a\r\nBcc: victim@example.com.txt", ContentType: "text/plain", Content: strings.NewReader("c")},
+	}
+
+	if err := m.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	msg, err := newMessage(m, "example.com")
+	if err != nil {
+		t.Fatalf("new message: %v", err)
+	}
+
+	b := &bytes.Buffer{}
+	if err = msg.writeTo(b); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	parsed, err := mail.ReadMessage(b)
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+
+	_, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("parse content type: %v", err)
+	}
+
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	wantTypes := []string{"text/plain", "text/plain", "application/pdf", "text/plain"}
+
+	for i, want := range wantTypes {
+		p, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+
+		if p.Header.Get("Bcc") != "" {
+			t.Errorf("part %d has injected header", i)
+		}
+
+		mediaType, params, err := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		if err != nil || mediaType != want {
+			t.Errorf("part %d content type = %s, %v, want %s", i, mediaType, err, want)
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		filename := m.Attachments[i-1].Filename
+
+		if params["name"] != filename {
+			t.Errorf("part %d name = %q, want %q", i, params["name"], filename)
+		}
+
+		if got := p.FileName(); got != filename {
+			t.Errorf("part %d filename = %q, want %q", i, got, filename)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/acim/arc/pkg/job"
+)
+
+var _ Sender = (*Queued)(nil)
+
+// JobKind is the kind of jobs sending e-mails.
+const JobKind = "mail.send"
+
+// Queued implements Sender interface by enqueueing e-mails to be sent in background by a job pool, where
+// handler registered by HandleQueued sends them. Failed sends are retried with exponential backoff and
+// e-mails failing permanently are moved to dead state.
+type Queued struct {
+	queue *job.Queue
+	opts  []job.EnqueueOption
+}
+
+// NewQueued creates new queued sender. Options are applied to each enqueued e-mail.
+func NewQueued(queue *job.Queue, opts ...job.EnqueueOption) *Queued {
+	return &Queued{
+		queue: queue,
+		opts:  opts,
+	}
+}
+
+// Send implements Sender interface. Attachments are read in full and stored together with the e-mail.
+// Response ID is the job ID, since e-mail is not sent yet.
+func (s *Queued) Send(ctx context.Context, m *Mail) (*Response, error) {
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("queue mail: %w", err)
+	}
+
+	qm, err := newQueuedMail(m)
+	if err != nil {
+		return nil, fmt.Errorf("queue mail: %w", err)
+	}
+
+	j, err := s.queue.Enqueue(ctx, JobKind, qm, s.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("queue mail: %w", err)
+	}
+
+	return &Response{
+		Message: "queued",
+		ID:      strconv.FormatInt(j.ID, 10), //nolint:gomnd
+	}, nil
+}
+
+// HandleQueued registers handler sending queued e-mails using sender. Errors wrapping ErrPermanent move
+// e-mail to dead state without further attempts.
+func HandleQueued(pool *job.Pool, sender Sender) {
+	job.Register(pool, JobKind, func(ctx context.Context, qm *queuedMail) error {
+		if _, err := sender.Send(ctx, qm.mail()); err != nil {
+			if errors.Is(err, ErrPermanent) {
+				return job.Permanent(err)
+			}
+
+			return err
+		}
+
+		return nil
+	})
+}
+
+// queuedMail is JSON representation of Mail including attachments.
+type queuedMail struct {
+	Mail
+	Attachments []*queuedAttachment `json:"attachments,omitempty"`
+	Inline      []*queuedAttachment `json:"inline,omitempty"`
+}
+
+type queuedAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType,omitempty"`
+	Content     []byte `json:"content"`
+}
+
+func newQueuedMail(m *Mail) (*queuedMail, error) {
+	qm := &queuedMail{Mail: copyMail(m), Attachments: nil, Inline: nil}
+
+	read, err := readAttachments(m.Attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	qm.Attachments = queuedAttachments(read)
+
+	if read, err = readAttachments(m.Inline); err != nil {
+		return nil, err
+	}
+
+	qm.Inline = queuedAttachments(read)
+
+	return qm, nil
+}
+
+func (qm *queuedMail) mail() *Mail {
+	m := qm.Mail
+	m.Attachments = attachmentReaders(qm.Attachments)
+	m.Inline = attachmentReaders(qm.Inline)
+
+	return &m
+}
+
+func queuedAttachments(list []attachment) []*queuedAttachment {
+	qa := make([]*queuedAttachment, 0, len(list))
+
+	for _, a := range list {
+		qa = append(qa, &queuedAttachment{Filename: a.filename, ContentType: a.contentType, Content: a.content})
+	}
+
+	return qa
+}
+
+func attachmentReaders(list []*queuedAttachment) []*Attachment {
+	a := make([]*Attachment, 0, len(list))
+
+	for _, qa := range list {
+		a = append(a, &Attachment{Filename: qa.Filename, ContentType: qa.ContentType, Content: bytes.NewReader(qa.Content)})
+	}
+
+	return a
+}
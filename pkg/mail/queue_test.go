@@ -0,0 +1,155 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/job"
+	"go.uber.org/zap"
+)
+
+// fakeJobs hands out enqueued jobs once and records their results.
+type fakeJobs struct {
+	mu      sync.Mutex
+	pending []*job.Job
+	enqueued,
+	done,
+	buried int
+}
+
+func (s *fakeJobs) Enqueue(_ context.Context, j *job.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.enqueued++
+	j.ID = int64(s.enqueued)
+	s.pending = append(s.pending, j)
+
+	return nil
+}
+
+func (s *fakeJobs) Claim(_ context.Context, limit int, _ time.Duration) ([]*job.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if limit > len(s.pending) {
+		limit = len(s.pending)
+	}
+
+	jobs := s.pending[:limit]
+	s.pending = s.pending[limit:]
+
+	return jobs, nil
+}
+
+func (s *fakeJobs) Done(context.Context, int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.done++
+
+	return nil
+}
+
+func (s *fakeJobs) Retry(context.Context, int64, time.Time, string) error {
+	return nil
+}
+
+func (s *fakeJobs) Bury(context.Context, int64, string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buried++
+
+	return nil
+}
+
+func (s *fakeJobs) Stats(context.Context) ([]*job.Stats, error) {
+	return nil, nil
+}
+
+func (s *fakeJobs) results() (enqueued, done, buried int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.enqueued, s.done, s.buried
+}
+
+// rejectingSender rejects e-mails to rejected@example.com and captures the rest.
+type rejectingSender struct {
+	*Memory
+}
+
+func (s rejectingSender) Send(ctx context.Context, m *Mail) (*Response, error) {
+	if m.To[0] == "rejected@example.com" {
+		return nil, errRejected
+	}
+
+	return s.Memory.Send(ctx, m)
+}
+
+func TestQueued(t *testing.T) {
+	t.Parallel()
+
+	store := &fakeJobs{} //nolint:exhaustivestruct
+	queued := NewQueued(job.NewQueue(store))
+	ctx := context.Background()
+
+	invalid := &Mail{From: "noreply@example.com", Subject: "Hi"} //nolint:exhaustivestruct
+
+	if _, err := queued.Send(ctx, invalid); err == nil {
+		t.Error("queued e-mail without recipients")
+	}
+
+	res, err := queued.Send(ctx, &Mail{ //nolint:exhaustivestruct
+		From: "noreply@example.com", To: []string{"jane@example.com"}, Subject: "Hi", Text: "Hello",
+		Attachments: []*Attachment{{Filename: "a.txt", ContentType: "text/plain", Content: strings.NewReader("hello")}},
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	if res.Message != "queued" || res.ID != "1" {
+		t.Errorf("response = %+v, want queued job 1", res)
+	}
+
+	_, err = queued.Send(ctx, &Mail{ //nolint:exhaustivestruct
+		From: "noreply@example.com", To: []string{"rejected@example.com"}, Subject: "Hi", Text: "Hello",
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	sender := rejectingSender{NewMemory()}
+	pool := job.NewPool("test", store, zap.NewNop(), job.PoolPollInterval(time.Millisecond))
+	HandleQueued(pool, sender)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+
+	go func() {
+		pool.Run(runCtx)
+		close(stopped)
+	}()
+
+	// Queued e-mails are sent before Run returns.
+	cancel()
+	<-stopped
+
+	if enqueued, done, buried := store.results(); enqueued != 2 || done != 1 || buried != 1 {
+		t.Errorf("enqueued %d, done %d, buried %d jobs, want 2, 1 and 1", enqueued, done, buried)
+	}
+
+	m := sender.Last()
+	if m == nil || m.Subject != "Hi" || len(m.Attachments) != 1 {
+		t.Fatalf("sent %+v, want e-mail with attachment", m)
+	}
+
+	if b, err := io.ReadAll(m.Attachments[0].Content); err != nil || string(b) != "hello" {
+		t.Errorf("attachment = %q, %v, want hello", b, err)
+	}
+}
@@ -1,19 +1,170 @@
 package mail
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+)
 
-// Mail contains all data needed to send an e-mail.
+var (
+	// ErrInvalidHeader is returned when custom header name or value is not valid.
+	ErrInvalidHeader = errors.New("invalid header")
+	// ErrNoBody is returned when mail has neither text nor HTML body.
+	ErrNoBody = errors.New("no body")
+	// ErrPermanent is matched by errors which won't go away by retrying, like invalid or rejected addresses.
+	ErrPermanent = errors.New("permanent failure")
+	// ErrNotAccepted is matched by errors after which the provider certainly hasn't accepted the e-mail, so
+	// it may be sent using another provider without risk of delivering it twice.
+	ErrNotAccepted = errors.New("not accepted")
+)
+
+// Mail contains all data needed to send an e-mail. At least one of Text and HTML bodies must be set.
+// Attachments are not encoded to JSON.
 type Mail struct {
 	From    string
+	ReplyTo string
 	Subject string
 	Text    string
+	HTML    string
 	To      []string
+	Cc      []string
+	Bcc     []string
+	// Headers are additional headers, i.e. List-Unsubscribe.
+	Headers map[string]string
+	// Tags are used by providers to categorize e-mails in statistics. Senders not supporting tags ignore them.
+	Tags        []string
+	Attachments []*Attachment `json:"-"`
+	// Inline files are referenced from HTML body as cid:ContentID(filename).
+	Inline []*Attachment `json:"-"`
+}
+
+// Attachment is a file attached to e-mail. Content is read once, while e-mail is being sent.
+type Attachment struct {
+	Filename string
+	// ContentType is detected from filename extension if empty.
+	ContentType string
+	Content     io.Reader
+}
+
+// Validate checks addresses, body and headers. Returned error matches ErrPermanent and ErrNotAccepted.
+func (m *Mail) Validate() error {
+	if err := m.validate(); err != nil {
+		return notAccepted(permanent(err))
+	}
+
+	return nil
+}
+
+func (m *Mail) validate() error {
+	if _, err := mail.ParseAddress(m.From); err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+
+	if m.ReplyTo != "" {
+		if _, err := mail.ParseAddress(m.ReplyTo); err != nil {
+			return fmt.Errorf("reply-to: %w", err)
+		}
+	}
+
+	if len(m.To)+len(m.Cc)+len(m.Bcc) == 0 {
+		return ErrNoRecipients
+	}
+
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		if _, err := parseAddresses(list); err != nil {
+			return err
+		}
+	}
+
+	if m.Text == "" && m.HTML == "" {
+		return ErrNoBody
+	}
+
+	if err := m.validateAttachments(); err != nil {
+		return err
+	}
+
+	return m.validateHeaders()
+}
+
+// validateAttachments makes sure content types of files can't inject headers.
+func (m *Mail) validateAttachments() error {
+	for _, list := range [][]*Attachment{m.Attachments, m.Inline} {
+		for _, a := range list {
+			if a.ContentType == "" {
+				continue
+			}
+
+			if strings.ContainsAny(a.ContentType, "\r\n") {
+				return fmt.Errorf("attachment %q content type: %w", a.Filename, ErrInvalidHeader)
+			}
+
+			if _, _, err := mime.ParseMediaType(a.ContentType); err != nil {
+				return fmt.Errorf("attachment %q content type: %w: %v", a.Filename, ErrInvalidHeader, err) //nolint:errorlint
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateHeaders makes sure custom headers can't inject other headers or override structural ones.
+func (m *Mail) validateHeaders() error {
+	for name, value := range m.Headers {
+		if name == "" || strings.ContainsAny(name, ": \t\r\n") || strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%q: %w", name, ErrInvalidHeader)
+		}
+
+		switch strings.ToLower(name) {
+		case "from", "to", "cc", "bcc", "reply-to", "subject", "date", "message-id", "mime-version",
+			"content-type", "content-transfer-encoding":
+			return fmt.Errorf("%q is set by sender: %w", name, ErrInvalidHeader)
+		}
+	}
+
+	return nil
+}
+
+// markedError makes error match target in addition to errors it wraps.
+type markedError struct {
+	err    error
+	target error
+}
+
+func mark(err, target error) error {
+	return &markedError{err: err, target: target}
+}
+
+func permanent(err error) error {
+	return mark(err, ErrPermanent)
+}
+
+func notAccepted(err error) error {
+	return mark(err, ErrNotAccepted)
+}
+
+func (e *markedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *markedError) Unwrap() error {
+	return e.err
+}
+
+func (e *markedError) Is(target error) bool {
+	return target == e.target //nolint:errorlint,goerr113
 }
 
 // Response contains data returned by mail service.
 type Response struct {
 	Message string
 	ID      string
+	// Provider is the name of provider which accepted the e-mail. Failover sets it to the configured name.
+	Provider string
 }
 
 // Sender is an interface to send e-mails.
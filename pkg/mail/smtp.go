@@ -0,0 +1,422 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var _ Sender = (*SMTP)(nil)
+
+var (
+	// ErrNoRecipients is returned when mail has no recipients.
+	ErrNoRecipients = errors.New("no recipients")
+	// ErrUnsupportedAuth is returned when SMTP authentication mechanism is not supported.
+	ErrUnsupportedAuth = errors.New("unsupported auth mechanism")
+	// ErrNoSTARTTLS is returned when STARTTLS is required, but server doesn't advertise it.
+	ErrNoSTARTTLS = errors.New("server doesn't support STARTTLS")
+	// ErrInvalidConfig is returned when sender configuration is invalid.
+	ErrInvalidConfig = errors.New("invalid config")
+	// ErrAuth is matched by errors caused by server rejecting credentials.
+	ErrAuth = errors.New("authentication failed")
+)
+
+// Security defines how SMTP connection is secured.
+type Security int
+
+// Supported security modes.
+const (
+	// STARTTLS upgrades plain connection using STARTTLS command, usually on port 587.
+	STARTTLS Security = iota
+	// ImplicitTLS connects using TLS from the start, usually on port 465.
+	ImplicitTLS
+	// Plain doesn't use TLS. Use only for local relays.
+	Plain
+)
+
+// ParseSecurity parses security mode name: starttls, tls or plain.
+func ParseSecurity(s string) (Security, error) {
+	switch s {
+	case "starttls":
+		return STARTTLS, nil
+	case "tls":
+		return ImplicitTLS, nil
+	case "plain":
+		return Plain, nil
+	}
+
+	return 0, fmt.Errorf("security %q: %w", s, ErrInvalidConfig)
+}
+
+// SMTP implements Sender interface. Connection is kept open and reused by subsequent sends until it's idle
+// for longer than idle timeout.
+type SMTP struct {
+	host        string
+	addr        string
+	security    Security
+	tlsConfig   *tls.Config
+	auth        smtp.Auth
+	localName   string
+	timeout     time.Duration
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	conn     net.Conn
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+// NewSMTP creates new SMTP sender.
+func NewSMTP(host string, port int, opts ...SMTPOption) *SMTP {
+	s := &SMTP{ //nolint:exhaustivestruct
+		host:        host,
+		addr:        net.JoinHostPort(host, strconv.Itoa(port)),
+		security:    STARTTLS,
+		tlsConfig:   &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}, //nolint:exhaustivestruct
+		localName:   "localhost",
+		timeout:     30 * time.Second, //nolint:gomnd
+		idleTimeout: 30 * time.Second, //nolint:gomnd
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Send implements Sender interface. Response ID is the generated Message-ID.
+func (s *SMTP) Send(ctx context.Context, m *Mail) (*Response, error) {
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	msg, err := newMessage(m, s.localName)
+	if err != nil {
+		return nil, fmt.Errorf("send mail: %w", notAccepted(permanent(err)))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.connect(ctx); err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	stop := watch(ctx, s.conn)
+	err = s.send(msg)
+
+	if stop() {
+		// Connection has been closed on cancellation, even if the e-mail got accepted meanwhile.
+		s.close()
+
+		if err != nil {
+			return nil, fmt.Errorf("send mail: %w", interrupted(ctx, err))
+		}
+	} else if err != nil {
+		// Connection state is unknown, so it's not reused.
+		s.close()
+
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	s.lastUsed = time.Now()
+
+	return &Response{
+		Message:  "queued",
+		ID:       msg.messageID,
+		Provider: "smtp",
+	}, nil
+}
+
+// Close closes connection, if open.
+func (s *SMTP) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil {
+		return nil
+	}
+
+	err := s.client.Quit()
+	s.close()
+
+	if err != nil {
+		return fmt.Errorf("close smtp: %w", err)
+	}
+
+	return nil
+}
+
+// send transfers the e-mail over connected client. Failures before the server replies to the end of data
+// are classified as not accepted, since servers don't deliver incomplete messages.
+func (s *SMTP) send(msg *message) error {
+	if err := s.client.Mail(msg.from.Address); err != nil {
+		return notAccepted(classifySMTPError(fmt.Errorf("mail from: %w", err)))
+	}
+
+	for _, rcpt := range msg.recipients() {
+		if err := s.client.Rcpt(rcpt); err != nil {
+			return notAccepted(classifySMTPError(fmt.Errorf("rcpt to %s: %w", rcpt, err)))
+		}
+	}
+
+	w, err := s.client.Data()
+	if err != nil {
+		return notAccepted(classifySMTPError(fmt.Errorf("data: %w", err)))
+	}
+
+	if err = msg.writeTo(w); err != nil {
+		return notAccepted(fmt.Errorf("data: %w", err))
+	}
+
+	if err = w.Close(); err != nil {
+		var te *textproto.Error
+
+		// Without a reply the server may have accepted the e-mail before the connection broke.
+		if !errors.As(err, &te) {
+			return fmt.Errorf("end of data: %w", err)
+		}
+
+		return notAccepted(classifySMTPError(fmt.Errorf("end of data: %w", err)))
+	}
+
+	return nil
+}
+
+// classifySMTPError marks errors with 5xx reply codes, which are permanent negative completion replies
+// by RFC 5321, as permanent.
+func classifySMTPError(err error) error {
+	var te *textproto.Error
+
+	if errors.As(err, &te) && te.Code >= 500 && te.Code < 600 {
+		return permanent(err)
+	}
+
+	return err
+}
+
+// watch closes conn if context is done before the returned stop function is called. Stop reports whether
+// conn has been closed.
+func watch(ctx context.Context, conn net.Conn) func() bool {
+	done := make(chan struct{})
+	closed := make(chan bool, 1)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+			closed <- true
+		case <-done:
+			closed <- false
+		}
+	}()
+
+	return func() bool {
+		close(done)
+
+		return <-closed
+	}
+}
+
+// interrupted returns context error in place of err caused by connection closed on cancellation, keeping
+// the classification of err.
+func interrupted(ctx context.Context, err error) error {
+	ierr := fmt.Errorf("%w: %v", ctx.Err(), err) //nolint:errorlint
+
+	if errors.Is(err, ErrNotAccepted) {
+		return notAccepted(ierr)
+	}
+
+	return ierr
+}
+
+// connect reuses existing connection if it's still alive or dials a new one. It also sets connection
+// deadline for the current send. Returned errors are classified as not accepted.
+func (s *SMTP) connect(ctx context.Context) error {
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	if s.client != nil {
+		if time.Since(s.lastUsed) < s.idleTimeout {
+			_ = s.conn.SetDeadline(deadline)
+
+			stop := watch(ctx, s.conn)
+			err := s.client.Reset()
+
+			if !stop() && err == nil {
+				return nil
+			}
+		}
+
+		_ = s.client.Quit()
+		s.close()
+	}
+
+	if err := s.dial(ctx, deadline); err != nil {
+		return notAccepted(err)
+	}
+
+	return nil
+}
+
+func (s *SMTP) dial(ctx context.Context, deadline time.Time) error {
+	dialer := &net.Dialer{Deadline: deadline} //nolint:exhaustivestruct
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if s.security == ImplicitTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tlsConfig}).DialContext(ctx, "tcp", s.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.addr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+
+	_ = conn.SetDeadline(deadline)
+
+	stop := watch(ctx, conn)
+	client, err := s.handshake(conn)
+
+	if stop() {
+		if err == nil {
+			_ = client.Close()
+			err = ctx.Err()
+		} else {
+			err = interrupted(ctx, err)
+		}
+	}
+
+	if err != nil {
+		_ = conn.Close()
+
+		return err
+	}
+
+	s.conn = conn
+	s.client = client
+
+	return nil
+}
+
+// handshake greets the server, upgrades connection using STARTTLS and authenticates, as configured.
+// Rejected credentials match ErrAuth and, unless the server replied with a transient 4xx code, ErrPermanent.
+func (s *SMTP) handshake(conn net.Conn) (*smtp.Client, error) {
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return nil, fmt.Errorf("greeting: %w", err)
+	}
+
+	if err := client.Hello(s.localName); err != nil {
+		return nil, fmt.Errorf("hello: %w", err)
+	}
+
+	if s.security == STARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return nil, ErrNoSTARTTLS
+		}
+
+		if err := client.StartTLS(s.tlsConfig); err != nil {
+			return nil, fmt.Errorf("starttls: %w", err)
+		}
+	}
+
+	if s.auth != nil {
+		if err := client.Auth(s.auth); err != nil {
+			var te *textproto.Error
+
+			err = mark(fmt.Errorf("auth: %w", err), ErrAuth)
+
+			if errors.As(err, &te) && te.Code >= 400 && te.Code < 500 {
+				return nil, err
+			}
+
+			return nil, permanent(err)
+		}
+	}
+
+	return client, nil
+}
+
+func (s *SMTP) close() {
+	if s.client != nil {
+		_ = s.client.Close()
+	}
+
+	s.client = nil
+	s.conn = nil
+}
+
+// SMTPOption ...
+type SMTPOption func(*SMTP)
+
+// SMTPSecurity sets connection security mode. Default is STARTTLS.
+func SMTPSecurity(sec Security) SMTPOption {
+	return func(s *SMTP) {
+		s.security = sec
+	}
+}
+
+// SMTPTLSConfig sets TLS configuration used by STARTTLS and implicit TLS.
+func SMTPTLSConfig(cfg *tls.Config) SMTPOption {
+	return func(s *SMTP) {
+		s.tlsConfig = cfg
+	}
+}
+
+// SMTPAuth sets authentication mechanism (plain, login or cram-md5) and credentials.
+// Credentials are sent only over TLS, except to localhost.
+func SMTPAuth(mechanism, username, password string) (SMTPOption, error) {
+	var newAuth func(host string) smtp.Auth
+
+	switch mechanism {
+	case "plain":
+		newAuth = func(host string) smtp.Auth { return smtp.PlainAuth("", username, password, host) }
+	case "login":
+		newAuth = func(host string) smtp.Auth { return &loginAuth{username: username, password: password, host: host} }
+	case "cram-md5":
+		newAuth = func(string) smtp.Auth { return smtp.CRAMMD5Auth(username, password) }
+	default:
+		return nil, fmt.Errorf("%q: %w", mechanism, ErrUnsupportedAuth)
+	}
+
+	return func(s *SMTP) {
+		s.auth = newAuth(s.host)
+	}, nil
+}
+
+// SMTPLocalName sets host name sent in EHLO command and used in generated Message-IDs.
+func SMTPLocalName(name string) SMTPOption {
+	return func(s *SMTP) {
+		s.localName = name
+	}
+}
+
+// SMTPTimeout sets maximum duration of a single send, including dialing.
+func SMTPTimeout(d time.Duration) SMTPOption {
+	return func(s *SMTP) {
+		s.timeout = d
+	}
+}
+
+// SMTPIdleTimeout sets how long an unused connection is kept for reuse. Servers usually drop idle
+// connections after a few minutes, so it should stay well below that.
+func SMTPIdleTimeout(d time.Duration) SMTPOption {
+	return func(s *SMTP) {
+		s.idleTimeout = d
+	}
+}
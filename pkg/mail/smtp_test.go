@@ -0,0 +1,551 @@
+package mail
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5" //nolint:gosec
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testUsername = "user"
+	testPassword = "secret"
+)
+
+// smtpServer is a minimal SMTP server standing in for mail providers in tests.
+type smtpServer struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+	// startTLS enables advertising STARTTLS extension.
+	startTLS bool
+	// authTemporary makes server reply to authentication with a transient error.
+	authTemporary bool
+	// rcptReplies contain replies to RCPT command by address, default is 250.
+	rcptReplies map[string]string
+	// stall, if set, makes server wait for it to be closed before replying to the end of data.
+	stall chan struct{}
+
+	mu sync.Mutex
+	// dataReply is the reply to the end of data, default is 250. If it's "drop", connection is closed
+	// without replying.
+	dataReply string
+	conns     int
+	tls       []bool
+	messages  []string
+}
+
+func newSMTPServer(t *testing.T, implicitTLS bool, configure ...func(s *smtpServer)) (*smtpServer, *tls.Config) {
+	t.Helper()
+
+	serverConfig, clientConfig := testTLSConfigs(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	if implicitTLS {
+		ln = tls.NewListener(ln, serverConfig)
+	}
+
+	s := &smtpServer{ //nolint:exhaustivestruct
+		ln:          ln,
+		tlsConfig:   serverConfig,
+		rcptReplies: make(map[string]string),
+	}
+
+	for _, c := range configure {
+		c(s)
+	}
+
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go s.serve()
+
+	return s, clientConfig
+}
+
+// sender creates SMTP sender connecting to the server.
+func (s *smtpServer) sender(t *testing.T, opts ...SMTPOption) *SMTP {
+	t.Helper()
+
+	sender := NewSMTP("127.0.0.1", s.ln.Addr().(*net.TCPAddr).Port, opts...)
+	t.Cleanup(func() { _ = sender.Close() })
+
+	return sender
+}
+
+func (s *smtpServer) stats() (conns int, tls []bool, messages []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.conns, append([]bool(nil), s.tls...), append([]string(nil), s.messages...)
+}
+
+func (s *smtpServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns++
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpServer) handle(conn net.Conn) { //nolint:cyclop,funlen
+	defer func() { _ = conn.Close() }()
+
+	_, isTLS := conn.(*tls.Conn)
+	tc := textproto.NewConn(conn)
+
+	_ = tc.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			ext := []string{"localhost", "AUTH PLAIN LOGIN CRAM-MD5"}
+			if s.startTLS && !isTLS {
+				ext = append(ext, "STARTTLS")
+			}
+
+			for i, e := range ext {
+				sep := "-"
+				if i == len(ext)-1 {
+					sep = " "
+				}
+
+				_ = tc.PrintfLine("250%s%s", sep, e)
+			}
+		case "STARTTLS":
+			_ = tc.PrintfLine("220 Ready to start TLS")
+
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+
+			conn = tlsConn
+			tc = textproto.NewConn(conn)
+			isTLS = true
+		case "AUTH":
+			ok, temporary := s.auth(tc, arg)
+
+			switch {
+			case temporary:
+				_ = tc.PrintfLine("454 4.7.0 Temporary authentication failure")
+			case ok:
+				_ = tc.PrintfLine("235 2.7.0 Authentication successful")
+			default:
+				_ = tc.PrintfLine("535 5.7.8 Authentication credentials invalid")
+			}
+		case "MAIL", "RSET", "NOOP":
+			_ = tc.PrintfLine("250 OK")
+		case "RCPT":
+			addr := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			if reply, ok := s.rcptReplies[addr]; ok {
+				_ = tc.PrintfLine("%s", reply)
+			} else {
+				_ = tc.PrintfLine("250 OK")
+			}
+		case "DATA":
+			_ = tc.PrintfLine("354 Go ahead")
+
+			data, err := tc.ReadDotBytes()
+			if err != nil {
+				return
+			}
+
+			s.mu.Lock()
+			s.tls = append(s.tls, isTLS)
+			s.messages = append(s.messages, string(data))
+			dataReply := s.dataReply
+			s.mu.Unlock()
+
+			if s.stall != nil {
+				<-s.stall
+
+				return
+			}
+
+			switch dataReply {
+			case "drop":
+				return
+			case "":
+				_ = tc.PrintfLine("250 OK queued")
+			default:
+				_ = tc.PrintfLine("%s", s.dataReply)
+			}
+		case "QUIT":
+			_ = tc.PrintfLine("221 Bye")
+
+			return
+		default:
+			_ = tc.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// auth runs authentication exchange and reports whether credentials are valid.
+func (s *smtpServer) auth(tc *textproto.Conn, arg string) (ok, temporary bool) {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+
+	if s.authTemporary {
+		return false, true
+	}
+
+	challenge := func(c string) string {
+		_ = tc.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(c)))
+
+		line, _ := tc.ReadLine()
+		b, _ := base64.StdEncoding.DecodeString(line)
+
+		return string(b)
+	}
+
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		b, _ := base64.StdEncoding.DecodeString(initial)
+
+		return string(b) == "\x00"+testUsername+"\x00"+testPassword, false
+	case "LOGIN":
+		username := challenge("Username:")
+		password := challenge("Password:")
+
+		return username == testUsername && password == testPassword, false
+	case "CRAM-MD5":
+		const c = "<1896.697170952@localhost>"
+
+		mac := hmac.New(md5.New, []byte(testPassword))
+		mac.Write([]byte(c))
+
+		return challenge(c) == testUsername+" "+hex.EncodeToString(mac.Sum(nil)), false
+	}
+
+	return false, false
+}
+
+// testTLSConfigs returns server configuration with self-signed certificate for 127.0.0.1 and client
+// configuration trusting it.
+func testTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	template := &x509.Certificate{ //nolint:exhaustivestruct
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"}, //nolint:exhaustivestruct
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server = &tls.Config{ //nolint:exhaustivestruct
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}, //nolint:exhaustivestruct
+		MinVersion:   tls.VersionTLS12,
+	}
+	client = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1", MinVersion: tls.VersionTLS12} //nolint:exhaustivestruct
+
+	return server, client
+}
+
+func testMail() *Mail {
+	return &Mail{ //nolint:exhaustivestruct
+		From:    "Sender <sender@example.com>",
+		To:      []string{"rcpt@example.com"},
+		Subject: "Hello",
+		Text:    "Hello world",
+	}
+}
+
+func smtpAuth(t *testing.T, mechanism, password string) SMTPOption {
+	t.Helper()
+
+	opt, err := SMTPAuth(mechanism, testUsername, password)
+	if err != nil {
+		t.Fatalf("smtp auth: %v", err)
+	}
+
+	return opt
+}
+
+func TestSMTPSecurity(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		implicitTLS bool
+		security    Security
+	}{
+		"starttls":     {implicitTLS: false, security: STARTTLS},
+		"implicit tls": {implicitTLS: true, security: ImplicitTLS},
+		"plain":        {implicitTLS: false, security: Plain},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, tlsConfig := newSMTPServer(t, tc.implicitTLS, func(s *smtpServer) { s.startTLS = true })
+
+			s := srv.sender(t, SMTPSecurity(tc.security), SMTPTLSConfig(tlsConfig),
+				smtpAuth(t, "plain", testPassword))
+
+			res, err := s.Send(context.Background(), testMail())
+			if err != nil {
+				t.Fatalf("send: %v", err)
+			}
+
+			_, tlsUsed, messages := srv.stats()
+
+			if len(messages) != 1 || !strings.Contains(messages[0], "Message-ID: "+res.ID) {
+				t.Fatalf("messages %q, want one with Message-ID %s", messages, res.ID)
+			}
+
+			if want := tc.security != Plain; tlsUsed[0] != want {
+				t.Errorf("tls = %t, want %t", tlsUsed[0], want)
+			}
+		})
+	}
+}
+
+func TestSMTPRequiresSTARTTLS(t *testing.T) {
+	t.Parallel()
+
+	srv, tlsConfig := newSMTPServer(t, false)
+	s := srv.sender(t, SMTPTLSConfig(tlsConfig))
+
+	_, err := s.Send(context.Background(), testMail())
+	if !errors.Is(err, ErrNoSTARTTLS) || !errors.Is(err, ErrNotAccepted) {
+		t.Errorf("error = %v, want ErrNoSTARTTLS and ErrNotAccepted", err)
+	}
+}
+
+func TestSMTPAuth(t *testing.T) {
+	t.Parallel()
+
+	for _, mechanism := range []string{"plain", "login", "cram-md5"} {
+		mechanism := mechanism
+
+		t.Run(mechanism, func(t *testing.T) {
+			t.Parallel()
+
+			srv, tlsConfig := newSMTPServer(t, false, func(s *smtpServer) { s.startTLS = true })
+
+			s := srv.sender(t, SMTPTLSConfig(tlsConfig), smtpAuth(t, mechanism, testPassword))
+			if _, err := s.Send(context.Background(), testMail()); err != nil {
+				t.Errorf("send: %v", err)
+			}
+
+			bad := srv.sender(t, SMTPTLSConfig(tlsConfig), smtpAuth(t, mechanism, "wrong"))
+
+			_, err := bad.Send(context.Background(), testMail())
+			if !errors.Is(err, ErrAuth) || !errors.Is(err, ErrPermanent) || !errors.Is(err, ErrNotAccepted) {
+				t.Errorf("wrong password error = %v, want ErrAuth, ErrPermanent and ErrNotAccepted", err)
+			}
+		})
+	}
+}
+
+func TestSMTPAuthTemporaryFailure(t *testing.T) {
+	t.Parallel()
+
+	srv, tlsConfig := newSMTPServer(t, false, func(s *smtpServer) {
+		s.startTLS = true
+		s.authTemporary = true
+	})
+
+	s := srv.sender(t, SMTPTLSConfig(tlsConfig), smtpAuth(t, "plain", testPassword))
+
+	_, err := s.Send(context.Background(), testMail())
+	if !errors.Is(err, ErrAuth) || errors.Is(err, ErrPermanent) {
+		t.Errorf("error = %v, want transient ErrAuth", err)
+	}
+}
+
+func TestSMTPReusesConnection(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := newSMTPServer(t, false)
+	s := srv.sender(t, SMTPSecurity(Plain), SMTPIdleTimeout(time.Hour))
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.Send(context.Background(), testMail()); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+
+	if conns, _, messages := srv.stats(); conns != 1 || len(messages) != 3 {
+		t.Errorf("%d connections and %d messages, want 1 and 3", conns, len(messages))
+	}
+
+	idle := srv.sender(t, SMTPSecurity(Plain), SMTPIdleTimeout(time.Nanosecond))
+
+	for i := 0; i < 2; i++ {
+		if _, err := idle.Send(context.Background(), testMail()); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+
+	if conns, _, _ := srv.stats(); conns != 3 {
+		t.Errorf("%d connections, want idle connection to be replaced", conns)
+	}
+}
+
+func TestSMTPReplyClassification(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		rcpt        string
+		dataReply   string
+		permanent   bool
+		notAccepted bool
+	}{
+		"rejected recipient": {rcpt: "unknown@example.com", permanent: true, notAccepted: true},
+		"deferred recipient": {rcpt: "busy@example.com", permanent: false, notAccepted: true},
+		"rejected data":      {dataReply: "554 5.6.0 Message rejected", permanent: true, notAccepted: true},
+		"deferred data":      {dataReply: "451 4.3.0 Try again later", permanent: false, notAccepted: true},
+		"dropped after data": {dataReply: "drop", permanent: false, notAccepted: false},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, _ := newSMTPServer(t, false, func(s *smtpServer) {
+				s.rcptReplies["unknown@example.com"] = "550 5.1.1 User unknown"
+				s.rcptReplies["busy@example.com"] = "450 4.2.1 Mailbox busy"
+				s.dataReply = tc.dataReply
+			})
+
+			m := testMail()
+			if tc.rcpt != "" {
+				m.To = append(m.To, tc.rcpt)
+			}
+
+			s := srv.sender(t, SMTPSecurity(Plain))
+
+			_, err := s.Send(context.Background(), m)
+			if err == nil {
+				t.Fatal("send succeeded")
+			}
+
+			if errors.Is(err, ErrPermanent) != tc.permanent {
+				t.Errorf("error %v permanent = %t, want %t", err, !tc.permanent, tc.permanent)
+			}
+
+			if errors.Is(err, ErrNotAccepted) != tc.notAccepted {
+				t.Errorf("error %v not accepted = %t, want %t", err, !tc.notAccepted, tc.notAccepted)
+			}
+
+			// Failed connection is not reused.
+			srv.mu.Lock()
+			srv.dataReply = ""
+			srv.mu.Unlock()
+
+			if _, err = s.Send(context.Background(), testMail()); err != nil {
+				t.Errorf("send after failure: %v", err)
+			}
+
+			if conns, _, _ := srv.stats(); conns != 2 {
+				t.Errorf("%d connections, want 2", conns)
+			}
+		})
+	}
+}
+
+func TestSMTPDialFailure(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	_, err = NewSMTP("127.0.0.1", port, SMTPSecurity(Plain)).Send(context.Background(), testMail())
+	if !errors.Is(err, ErrNotAccepted) || errors.Is(err, ErrPermanent) {
+		t.Errorf("error = %v, want transient ErrNotAccepted", err)
+	}
+}
+
+func TestSMTPClosesConnectionOnCancel(t *testing.T) {
+	t.Parallel()
+
+	stall := make(chan struct{})
+	srv, _ := newSMTPServer(t, false, func(s *smtpServer) { s.stall = stall })
+
+	t.Cleanup(func() { close(stall) })
+
+	s := srv.sender(t, SMTPSecurity(Plain), SMTPTimeout(time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+
+	_, err := s.Send(ctx, testMail())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("send returned after %s", elapsed)
+	}
+
+	// Message may have been accepted before the server replied.
+	if errors.Is(err, ErrNotAccepted) {
+		t.Errorf("error %v is classified as not accepted", err)
+	}
+}
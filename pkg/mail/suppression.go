@@ -0,0 +1,131 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+var _ Sender = (*Suppressing)(nil)
+
+// ErrSuppressed is returned when all recipients of e-mail are suppressed.
+var ErrSuppressed = errors.New("all recipients suppressed")
+
+// Suppression reasons.
+const (
+	SuppressionBounce      = "bounce"
+	SuppressionComplaint   = "complaint"
+	SuppressionUnsubscribe = "unsubscribe"
+)
+
+// Event is a delivery event reported by mail provider.
+type Event struct {
+	// ID is provider's event ID.
+	ID string
+	// MessageID is the Message-ID of e-mail without angle brackets, i.e. Response.ID returned by Mailgun
+	// and SMTP senders trimmed of them.
+	MessageID string
+	// Event is provider's event type, i.e. delivered, failed or complained.
+	Event     string
+	Recipient string
+	// Severity of failure, permanent or temporary.
+	Severity    string
+	Reason      string
+	Code        int
+	Description string
+	Timestamp   time.Time
+}
+
+// EventStore records delivery events.
+type EventStore interface {
+	// AddEvent stores event. Storing the same event more than once has no effect.
+	AddEvent(ctx context.Context, e *Event) error
+	// Events returns events of the e-mail ordered by time. Message ID may be enclosed in angle brackets.
+	Events(ctx context.Context, messageID string) ([]*Event, error)
+}
+
+// Suppressions is a list of addresses e-mails should not be sent to. Addresses are compared case
+// insensitively.
+type Suppressions interface {
+	// Suppress adds address to the list or updates the reason.
+	Suppress(ctx context.Context, address, reason string) error
+	// Unsuppress removes address from the list.
+	Unsuppress(ctx context.Context, address string) error
+	// Suppressed returns those of the addresses which are on the list.
+	Suppressed(ctx context.Context, addresses []string) ([]string, error)
+}
+
+// Suppressing implements Sender interface by removing suppressed recipients before e-mail is sent by
+// the next sender.
+type Suppressing struct {
+	next         Sender
+	suppressions Suppressions
+}
+
+// NewSuppressing creates new suppressing sender.
+func NewSuppressing(next Sender, suppressions Suppressions) *Suppressing {
+	return &Suppressing{
+		next:         next,
+		suppressions: suppressions,
+	}
+}
+
+// Send implements Sender interface. If all recipients are suppressed, returned error matches both
+// ErrSuppressed and ErrPermanent.
+func (s *Suppressing) Send(ctx context.Context, m *Mail) (*Response, error) {
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("send mail: %w", err)
+	}
+
+	addresses := make([]string, 0, len(m.To)+len(m.Cc)+len(m.Bcc))
+
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		addrs, _ := parseAddresses(list) // validated above
+
+		for _, a := range addrs {
+			addresses = append(addresses, a.Address)
+		}
+	}
+
+	suppressed, err := s.suppressions.Suppressed(ctx, addresses)
+	if err != nil {
+		return nil, fmt.Errorf("send mail: suppressions: %w", err)
+	}
+
+	if len(suppressed) == 0 {
+		return s.next.Send(ctx, m) //nolint:wrapcheck
+	}
+
+	skip := make(map[string]bool, len(suppressed))
+	for _, a := range suppressed {
+		skip[strings.ToLower(a)] = true
+	}
+
+	c := *m
+	c.To = withoutSuppressed(m.To, skip)
+	c.Cc = withoutSuppressed(m.Cc, skip)
+	c.Bcc = withoutSuppressed(m.Bcc, skip)
+
+	if len(c.To)+len(c.Cc)+len(c.Bcc) == 0 {
+		return nil, fmt.Errorf("send mail: %w", permanent(ErrSuppressed))
+	}
+
+	return s.next.Send(ctx, &c) //nolint:wrapcheck
+}
+
+func withoutSuppressed(list []string, skip map[string]bool) []string {
+	kept := make([]string, 0, len(list))
+
+	for _, s := range list {
+		if a, err := mail.ParseAddress(s); err == nil && skip[strings.ToLower(a.Address)] {
+			continue
+		}
+
+		kept = append(kept, s)
+	}
+
+	return kept
+}
@@ -0,0 +1,285 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	ttemplate "text/template"
+
+	"golang.org/x/text/language"
+)
+
+// ErrTemplateNotFound is returned when there is no template with the given name.
+var ErrTemplateNotFound = errors.New("template not found")
+
+// Template file name suffixes.
+const (
+	subjectSuffix = ".subject.tmpl"
+	textSuffix    = ".txt.tmpl"
+	htmlSuffix    = ".html.tmpl"
+)
+
+// htmlFuncs are functions available in HTML templates.
+var htmlFuncs = htemplate.FuncMap{ //nolint:gochecknoglobals
+	// cid returns URL of inline file with the given name.
+	"cid": func(filename string) htemplate.URL {
+		return htemplate.URL("cid:" + ContentID(filename)) //nolint:gosec
+	},
+}
+
+// Templates renders e-mails from named templates. Template named welcome consists of welcome.subject.tmpl,
+// welcome.txt.tmpl and welcome.html.tmpl files in the root of file system, where at least one of the body
+// templates must exist. Text templates are parsed together with all .txt.tmpl files found in layouts and
+// partials directories and HTML templates with all .html.tmpl files found there, so they can define and use
+// shared templates.
+//
+// HTML templates refer to inline files using cid function, i.e. <img src="{{cid "logo.png"}}">.
+//
+// Translations are files having language tag before the suffix, i.e. welcome.de.txt.tmpl or
+// layouts/base.de.txt.tmpl, while files without it are English. Translated templates use translated layouts
+// and partials, if there are any, and English ones otherwise.
+type Templates struct {
+	pages map[string]*localizedPage
+}
+
+// localizedPage contains translations of a template, English being the first if it exists.
+type localizedPage struct {
+	tags    []language.Tag
+	matcher language.Matcher
+	pages   []*page
+}
+
+type page struct {
+	subject *ttemplate.Template
+	text    *ttemplate.Template
+	html    *htemplate.Template
+}
+
+// NewTemplates parses all templates found in fsys, which is usually an embed.FS.
+func NewTemplates(fsys fs.FS) (*Templates, error) {
+	sharedText, err := sharedFiles(fsys, textSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedHTML, err := sharedFiles(fsys, htmlSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read templates: %w", err)
+	}
+
+	pages := make(map[string]map[language.Tag]*page)
+
+	get := func(name string, tag language.Tag) *page {
+		if pages[name] == nil {
+			pages[name] = make(map[language.Tag]*page)
+		}
+
+		if pages[name][tag] == nil {
+			pages[name][tag] = &page{} //nolint:exhaustivestruct
+		}
+
+		return pages[name][tag]
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		file := e.Name()
+
+		switch {
+		case strings.HasSuffix(file, subjectSuffix):
+			name, tag, err := splitName(file, subjectSuffix)
+			if err != nil {
+				return nil, err
+			}
+
+			if get(name, tag).subject, err = ttemplate.ParseFS(fsys, file); err != nil {
+				return nil, fmt.Errorf("parse %s: %w", file, err)
+			}
+		case strings.HasSuffix(file, textSuffix):
+			name, tag, err := splitName(file, textSuffix)
+			if err != nil {
+				return nil, err
+			}
+
+			if get(name, tag).text, err = ttemplate.ParseFS(fsys, withShared(file, sharedText, tag)...); err != nil {
+				return nil, fmt.Errorf("parse %s: %w", file, err)
+			}
+		case strings.HasSuffix(file, htmlSuffix):
+			name, tag, err := splitName(file, htmlSuffix)
+			if err != nil {
+				return nil, err
+			}
+
+			get(name, tag).html, err = htemplate.New(file).Funcs(htmlFuncs).ParseFS(fsys, withShared(file, sharedHTML, tag)...)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", file, err)
+			}
+		}
+	}
+
+	t := &Templates{
+		pages: make(map[string]*localizedPage, len(pages)),
+	}
+
+	for name, translations := range pages {
+		t.pages[name] = newLocalizedPage(translations)
+	}
+
+	return t, nil
+}
+
+// Render sets subject and bodies of m by executing template name with data. Translation best matching
+// the language is used. Subject is left untouched if there is no subject template.
+func (t *Templates) Render(m *Mail, name string, lang language.Tag, data interface{}) error {
+	lp, ok := t.pages[name]
+	if !ok {
+		return fmt.Errorf("%s: %w", name, ErrTemplateNotFound)
+	}
+
+	_, i, _ := lp.matcher.Match(lang)
+	p := lp.pages[i]
+
+	if p.text == nil && p.html == nil {
+		return fmt.Errorf("%s (%s): %w", name, lp.tags[i], ErrTemplateNotFound)
+	}
+
+	b := &bytes.Buffer{}
+
+	if p.subject != nil {
+		if err := p.subject.Execute(b, data); err != nil {
+			return fmt.Errorf("render %s subject: %w", name, err)
+		}
+
+		// Line breaks are not allowed in the header.
+		m.Subject = strings.Join(strings.Fields(b.String()), " ")
+	}
+
+	if p.text != nil {
+		b.Reset()
+
+		if err := p.text.Execute(b, data); err != nil {
+			return fmt.Errorf("render %s text: %w", name, err)
+		}
+
+		m.Text = b.String()
+	}
+
+	if p.html != nil {
+		b.Reset()
+
+		if err := p.html.Execute(b, data); err != nil {
+			return fmt.Errorf("render %s html: %w", name, err)
+		}
+
+		m.HTML = b.String()
+	}
+
+	return nil
+}
+
+func newLocalizedPage(translations map[language.Tag]*page) *localizedPage {
+	lp := &localizedPage{ //nolint:exhaustivestruct
+		tags:  make([]language.Tag, 0, len(translations)),
+		pages: make([]*page, 0, len(translations)),
+	}
+
+	for tag := range translations {
+		lp.tags = append(lp.tags, tag)
+	}
+
+	// Matcher falls back to the first tag.
+	sort.Slice(lp.tags, func(i, j int) bool {
+		if lp.tags[i] == language.English || lp.tags[j] == language.English {
+			return lp.tags[i] == language.English
+		}
+
+		return lp.tags[i].String() < lp.tags[j].String()
+	})
+
+	for _, tag := range lp.tags {
+		lp.pages = append(lp.pages, translations[tag])
+	}
+
+	lp.matcher = language.NewMatcher(lp.tags)
+
+	return lp
+}
+
+// sharedFiles returns layouts and partials with the suffix, by language and file name without language.
+func sharedFiles(fsys fs.FS, suffix string) (map[language.Tag]map[string]string, error) {
+	files := make(map[language.Tag]map[string]string)
+
+	for _, dir := range []string{"layouts", "partials"} {
+		matches, err := fs.Glob(fsys, path.Join(dir, "*"+suffix))
+		if err != nil {
+			return nil, fmt.Errorf("glob %s: %w", dir, err)
+		}
+
+		for _, file := range matches {
+			name, tag, err := splitName(file, suffix)
+			if err != nil {
+				return nil, err
+			}
+
+			if files[tag] == nil {
+				files[tag] = make(map[string]string)
+			}
+
+			files[tag][name] = file
+		}
+	}
+
+	return files, nil
+}
+
+// withShared returns file followed by shared files in its language, falling back to English ones.
+func withShared(file string, shared map[language.Tag]map[string]string, tag language.Tag) []string {
+	files := []string{file}
+
+	for name, f := range shared[language.English] {
+		if translated, ok := shared[tag][name]; ok {
+			f = translated
+		}
+
+		files = append(files, f)
+	}
+
+	for name, f := range shared[tag] {
+		if _, ok := shared[language.English][name]; !ok {
+			files = append(files, f)
+		}
+	}
+
+	return files
+}
+
+// splitName splits file name into template name, including directory, and language, which is English if
+// not present.
+func splitName(file, suffix string) (string, language.Tag, error) {
+	name := strings.TrimSuffix(file, suffix)
+
+	i := strings.LastIndex(name, ".")
+	if i < 0 || strings.Contains(name[i:], "/") {
+		return name, language.English, nil
+	}
+
+	tag, err := language.Parse(name[i+1:])
+	if err != nil {
+		return "", language.Und, fmt.Errorf("template %s: language: %w", file, err)
+	}
+
+	return name[:i], tag, nil
+}
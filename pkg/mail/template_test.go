@@ -0,0 +1,149 @@
+package mail
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/text/language"
+)
+
+// templateFile returns template file with the content.
+func templateFile(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)} //nolint:exhaustivestruct
+}
+
+func newTestTemplates(t *testing.T) *Templates {
+	t.Helper()
+
+	templates, err := NewTemplates(fstest.MapFS{
+		"layouts/base.txt.tmpl":    templateFile(`{{define "base"}}{{template "body" .}} {{template "ft"}}{{end}}`),
+		"layouts/base.de.txt.tmpl": templateFile(`{{define "base"}}DE {{template "body" .}} {{template "ft"}}{{end}}`),
+		"layouts/base.html.tmpl":   templateFile(`{{define "base"}}<p>{{template "body" .}}</p>{{end}}`),
+		"partials/ft.txt.tmpl":     templateFile(`{{define "ft"}}Bye{{end}}`),
+		"partials/ft.sr.txt.tmpl":  templateFile(`{{define "ft"}}Поздрав{{end}}`),
+		"partials/ps.de.txt.tmpl":  templateFile(`{{define "ps"}}Tschüss{{end}}`),
+		"welcome.subject.tmpl":     templateFile("Welcome\n  {{.}}\n"),
+		"welcome.txt.tmpl":         templateFile(`{{template "base" .}}{{define "body"}}Hello {{.}}{{end}}`),
+		"welcome.html.tmpl":        templateFile(`{{template "base" .}}{{define "body"}}Hello {{.}}{{end}}`),
+		"welcome.de.subject.tmpl":  templateFile("Willkommen {{.}}"),
+		"welcome.de.txt.tmpl":      templateFile(`{{template "base" .}}{{template "ps"}}{{define "body"}}Hallo {{.}}{{end}}`),
+		"welcome.sr.subject.tmpl":  templateFile("Добродошли {{.}}"),
+		"welcome.sr.txt.tmpl":      templateFile(`{{template "base" .}}{{define "body"}}Здраво {{.}}{{end}}`),
+		"reset.de.txt.tmpl":        templateFile(`Passwort zurücksetzen`),
+		"reset.sr.txt.tmpl":        templateFile(`Ресетовање лозинке`),
+		"notice.subject.tmpl":      templateFile("Notice"),
+		"README.md":                templateFile("not a template"),
+	})
+	if err != nil {
+		t.Fatalf("new templates: %v", err)
+	}
+
+	return templates
+}
+
+func TestTemplatesRender(t *testing.T) {
+	t.Parallel()
+
+	templates := newTestTemplates(t)
+
+	tests := map[string]struct {
+		name    string
+		lang    language.Tag
+		subject string
+		text    string
+		html    string
+		wantErr error
+	}{
+		"layout and partial": {
+			name: "welcome", lang: language.English,
+			subject: "Welcome <Jane>", text: "Hello <Jane> Bye", html: "<p>Hello &lt;Jane&gt;</p>",
+		},
+		"translated layout": {
+			name: "welcome", lang: language.German,
+			subject: "Willkommen <Jane>", text: "DE Hallo <Jane> ByeTschüss",
+		},
+		"translated partial": {
+			name: "welcome", lang: language.Serbian,
+			subject: "Добродошли <Jane>", text: "Здраво <Jane> Поздрав",
+		},
+		"regional variant": {
+			name: "welcome", lang: language.MustParse("de-AT"),
+			subject: "Willkommen <Jane>", text: "DE Hallo <Jane> ByeTschüss",
+		},
+		"script variant": {
+			name: "welcome", lang: language.MustParse("sr-Latn"),
+			subject: "Добродошли <Jane>", text: "Здраво <Jane> Поздрав",
+		},
+		"unsupported language": {
+			name: "welcome", lang: language.Japanese,
+			subject: "Welcome <Jane>", text: "Hello <Jane> Bye", html: "<p>Hello &lt;Jane&gt;</p>",
+		},
+		"without english": {
+			name: "reset", lang: language.Japanese,
+			subject: "original", text: "Passwort zurücksetzen",
+		},
+		"without english matching": {
+			name: "reset", lang: language.Serbian,
+			subject: "original", text: "Ресетовање лозинке",
+		},
+		"missing template": {
+			name: "goodbye", lang: language.English,
+			subject: "original", wantErr: ErrTemplateNotFound,
+		},
+		"missing body": {
+			name: "notice", lang: language.English,
+			subject: "original", wantErr: ErrTemplateNotFound,
+		},
+		"layout only": {
+			name: "layouts/base", lang: language.English,
+			subject: "original", wantErr: ErrTemplateNotFound,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &Mail{Subject: "original"} //nolint:exhaustivestruct
+
+			if err := templates.Render(m, tc.name, tc.lang, "<Jane>"); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("render error = %v, want %v", err, tc.wantErr)
+			}
+
+			if m.Subject != tc.subject || m.Text != tc.text || m.HTML != tc.html {
+				t.Errorf("rendered %q, %q, %q, want %q, %q, %q", m.Subject, m.Text, m.HTML, tc.subject, tc.text, tc.html)
+			}
+		})
+	}
+}
+
+func TestNewTemplatesInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]fstest.MapFS{
+		"invalid language":         {"welcome.123456789.txt.tmpl": templateFile("Hello")},
+		"invalid layout language":  {"layouts/base.123456789.txt.tmpl": templateFile("Hello")},
+		"invalid subject":          {"welcome.subject.tmpl": templateFile("{{.")},
+		"invalid text":             {"welcome.txt.tmpl": templateFile("{{end}}")},
+		"invalid html":             {"welcome.html.tmpl": templateFile(`{{template "missing" .}`)},
+		"invalid layout":           {"welcome.txt.tmpl": templateFile("Hi"), "layouts/base.txt.tmpl": templateFile("{{if}}")},
+		"undefined html function":  {"welcome.html.tmpl": templateFile("{{unknown}}")},
+		"undefined text function":  {"welcome.txt.tmpl": templateFile(`{{cid "logo.png"}}`)},
+		"invalid partial language": {"partials/ft.123456789.html.tmpl": templateFile("Bye")},
+	}
+
+	for name, fsys := range tests {
+		fsys := fsys
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := NewTemplates(fsys); err == nil {
+				t.Error("parsed invalid templates")
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/acim/arc/pkg/i18n"
+	"github.com/acim/arc/pkg/store"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// Admin middleware rejects requests of users who are not administrators. Token subject has to be an
+// existing administrator of the request tenant, so tokens of deleted or demoted users are rejected before
+// they expire.
+// It should be used after jwtauth.Verifier, jwtauth.Authenticator and TenantToken.
+func Admin(users store.Users) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := ResponseFromContext(r.Context())
+			loc := i18n.FromContext(r.Context())
+
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				res.SetStatus(http.StatusUnauthorized).AddError(loc.T(http.StatusText(http.StatusUnauthorized)))
+
+				return
+			}
+
+			id, _ := claims["sub"].(string)
+			if id == "" {
+				res.SetStatusForbidden(loc.T(http.StatusText(http.StatusForbidden)))
+
+				return
+			}
+
+			u, err := users.FindByID(r.Context(), id)
+			if err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					res.SetStatusForbidden(loc.T(http.StatusText(http.StatusForbidden)))
+				} else {
+					res.SetStatusInternalServerError("")
+				}
+
+				return
+			}
+
+			if !u.Admin {
+				res.SetStatusForbidden(loc.T(http.StatusText(http.StatusForbidden)))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
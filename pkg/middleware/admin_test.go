@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+var errDatabase = errors.New("database unavailable")
+
+// fakeUsers finds administrator with ID "admin", user with ID "user" and fails for user with ID "broken".
+type fakeUsers struct {
+	store.Users
+}
+
+func (fakeUsers) FindByID(_ context.Context, id string) (*model.User, error) {
+	switch id {
+	case "admin":
+		return &model.User{ID: id, Admin: true}, nil //nolint:exhaustivestruct
+	case "user":
+		return &model.User{ID: id}, nil //nolint:exhaustivestruct
+	case "broken":
+		return nil, errDatabase
+	default:
+		return nil, store.ErrNotFound
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	t.Parallel()
+
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+	h := RenderJSON(jwtauth.Verifier(auth)(jwtauth.Authenticator(Admin(fakeUsers{})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ResponseFromContext(r.Context()).SetStatus(http.StatusNoContent)
+		})))))
+
+	tests := map[string]struct {
+		claims map[string]interface{}
+		status int
+	}{
+		"admin":        {claims: map[string]interface{}{"sub": "admin"}, status: http.StatusNoContent},
+		"non-admin":    {claims: map[string]interface{}{"sub": "user"}, status: http.StatusForbidden},
+		"deleted user": {claims: map[string]interface{}{"sub": "deleted"}, status: http.StatusForbidden},
+		"no subject":   {claims: map[string]interface{}{}, status: http.StatusForbidden},
+		"store error":  {claims: map[string]interface{}{"sub": "broken"}, status: http.StatusInternalServerError},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, token, err := auth.Encode(tc.claims)
+			if err != nil {
+				t.Fatalf("encode token: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/submissions", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Errorf("status = %d, want %d", rec.Code, tc.status)
+			}
+		})
+	}
+}
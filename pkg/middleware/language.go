@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/acim/arc/pkg/i18n"
+)
+
+// Language middleware negotiates response language using Accept-Language header and puts localizer
+// of the matched language into request context.
+func Language(c *i18n.Catalog) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tag := c.Match(r.Header.Get("Accept-Language"))
+
+			w.Header().Add("Vary", "Accept-Language")
+			w.Header().Set("Content-Language", tag.String())
+
+			next.ServeHTTP(w, r.WithContext(i18n.NewContext(r.Context(), c.Localizer(tag))))
+		})
+	}
+}
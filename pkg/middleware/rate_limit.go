@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/acim/arc/pkg/i18n"
+)
+
+// RateLimit middleware allows at most limit requests per client IP address within window and rejects the
+// rest with http.StatusTooManyRequests. Counters are kept in memory, so each instance of the service
+// limits separately. Use chi's RealIP middleware before this one if the service runs behind a proxy.
+func RateLimit(limit int, window time.Duration) func(next http.Handler) http.Handler {
+	l := &rateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+
+			if wait, ok := l.allow(ip, time.Now()); !ok {
+				res := ResponseFromContext(r.Context())
+				res.SetHeader("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds())))).
+					SetStatus(http.StatusTooManyRequests).
+					AddError(i18n.FromContext(r.Context()).T(http.StatusText(http.StatusTooManyRequests)))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	windows   map[string]*rateWindow
+	lastPrune time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// allow counts request of the key and returns time until the window resets if limit is exceeded.
+func (l *rateLimiter) allow(key string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) > l.window {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= l.window {
+				delete(l.windows, k)
+			}
+		}
+
+		l.lastPrune = now
+	}
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &rateWindow{start: now, count: 0}
+		l.windows[key] = w
+	}
+
+	if w.count >= l.limit {
+		return w.start.Add(l.window).Sub(now), false
+	}
+
+	w.count++
+
+	return 0, true
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWindowReset(t *testing.T) {
+	t.Parallel()
+
+	l := &rateLimiter{limit: 2, window: time.Minute, windows: make(map[string]*rateWindow)} //nolint:exhaustivestruct
+	start := time.Unix(1700000000, 0)
+
+	for i := 0; i < 2; i++ {
+		if _, ok := l.allow("192.0.2.1", start.Add(time.Duration(i)*time.Second)); !ok {
+			t.Fatalf("request %d rejected", i+1)
+		}
+	}
+
+	wait, ok := l.allow("192.0.2.1", start.Add(20*time.Second))
+	if ok || wait != 40*time.Second {
+		t.Fatalf("allow = %v, %t, want 40s, false", wait, ok)
+	}
+
+	// Other clients are counted separately.
+	if _, ok = l.allow("192.0.2.2", start.Add(20*time.Second)); !ok {
+		t.Fatal("other client rejected")
+	}
+
+	// Counter is reset when the window elapses.
+	if _, ok = l.allow("192.0.2.1", start.Add(time.Minute)); !ok {
+		t.Fatal("request after window reset rejected")
+	}
+
+	// Expired windows are pruned.
+	if _, ok = l.allow("192.0.2.3", start.Add(2*time.Minute+time.Second)); !ok {
+		t.Fatal("new client rejected")
+	}
+
+	if len(l.windows) != 1 {
+		t.Errorf("expired windows kept: %d windows, want 1", len(l.windows))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	h := RenderJSON(RateLimit(1, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ResponseFromContext(r.Context()).SetStatusAccepted()
+	})))
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/mail", nil)
+		req.RemoteAddr = remoteAddr
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	if rec := send("192.0.2.1:1234"); rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+
+	// Port is ignored.
+	rec := send("192.0.2.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	if retry := rec.Header().Get("Retry-After"); retry != "3600" {
+		t.Errorf("Retry-After = %q, want 3600", retry)
+	}
+}
@@ -74,6 +74,28 @@ func (r *Response) SetStatusNotFound(err string) *Response {
 	return r
 }
 
+// SetStatusConflict sets status code to http.StatusConflict.
+func (r *Response) SetStatusConflict(err string) *Response {
+	r.statusCode = http.StatusConflict
+
+	if err != "" {
+		r.AddError(err)
+	}
+
+	return r
+}
+
+// SetStatusUnprocessableEntity sets status code to http.StatusUnprocessableEntity.
+func (r *Response) SetStatusUnprocessableEntity(err string) *Response {
+	r.statusCode = http.StatusUnprocessableEntity
+
+	if err != "" {
+		r.AddError(err)
+	}
+
+	return r
+}
+
 // SetStatusAccepted sets status code to http.StatusAccepted.
 func (r *Response) SetStatusAccepted() *Response {
 	r.statusCode = http.StatusAccepted
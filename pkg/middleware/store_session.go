@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/acim/arc/pkg/store"
+)
+
+// StoreSession middleware starts a store session per request, so that reads following a write within
+// the same request are served from the primary database.
+func StoreSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(store.NewSession(r.Context())))
+	})
+}
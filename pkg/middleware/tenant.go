@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/acim/arc/pkg/i18n"
+	"github.com/acim/arc/pkg/tenant"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// Tenant middleware puts tenant ID found by the first successful resolver into request context.
+// Requests not identifying a tenant belong to the default tenant.
+func Tenant(resolvers ...tenant.Resolver) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, resolve := range resolvers {
+				if id, ok := resolve(r); ok {
+					r = r.WithContext(tenant.NewContext(r.Context(), id))
+
+					break
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TenantToken middleware rejects tokens issued for a tenant other than the one of the request.
+// It should be used after jwtauth.Verifier and jwtauth.Authenticator.
+func TenantToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := ResponseFromContext(r.Context())
+		loc := i18n.FromContext(r.Context())
+
+		_, claims, err := jwtauth.FromContext(r.Context())
+		if err != nil {
+			res.SetStatus(http.StatusUnauthorized).AddError(loc.T(http.StatusText(http.StatusUnauthorized)))
+
+			return
+		}
+
+		id, _ := claims[tenant.Claim].(string)
+		if id != tenant.FromContext(r.Context()) {
+			res.SetStatusForbidden(loc.T(http.StatusText(http.StatusForbidden)))
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
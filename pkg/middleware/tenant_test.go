@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/acim/arc/pkg/tenant"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+func TestTenant(t *testing.T) {
+	t.Parallel()
+
+	var got string
+
+	h := Tenant(tenant.FromSubdomain("example.com"), tenant.FromHeader("X-Tenant"))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = tenant.FromContext(r.Context())
+		}))
+
+	tests := map[string]struct {
+		host   string
+		header string
+		want   string
+	}{
+		"first resolver":  {host: "acme.example.com", header: "globex", want: "acme"},
+		"second resolver": {host: "example.com", header: "globex", want: "globex"},
+		"default tenant":  {host: "example.com", want: ""},
+	}
+
+	for name, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = tc.host
+
+		if tc.header != "" {
+			req.Header.Set("X-Tenant", tc.header)
+		}
+
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if got != tc.want {
+			t.Errorf("%s: tenant = %q, want %q", name, got, tc.want)
+		}
+	}
+}
+
+func TestTenantToken(t *testing.T) {
+	t.Parallel()
+
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+	h := RenderJSON(Tenant(tenant.FromSubdomain("example.com"))(
+		jwtauth.Verifier(auth)(jwtauth.Authenticator(TenantToken(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ResponseFromContext(r.Context()).SetStatus(http.StatusNoContent)
+			}))))))
+
+	tests := map[string]struct {
+		host   string
+		claims map[string]interface{}
+		status int
+	}{
+		"same tenant": {
+			host: "acme.example.com", claims: map[string]interface{}{tenant.Claim: "acme"}, status: http.StatusNoContent,
+		},
+		"default tenant": {
+			host: "example.com", claims: map[string]interface{}{"sub": "jane"}, status: http.StatusNoContent,
+		},
+		"other tenant": {
+			host: "acme.example.com", claims: map[string]interface{}{tenant.Claim: "globex"}, status: http.StatusForbidden,
+		},
+		"default tenant token": {
+			host: "acme.example.com", claims: map[string]interface{}{"sub": "jane"}, status: http.StatusForbidden,
+		},
+		"tenant token on default tenant": {
+			host: "example.com", claims: map[string]interface{}{tenant.Claim: "acme"}, status: http.StatusForbidden,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, token, err := auth.Encode(tc.claims)
+			if err != nil {
+				t.Fatalf("encode token: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/auth", nil)
+			req.Host = tc.host
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Errorf("status = %d, want %d", rec.Code, tc.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalidEmail is returned if e-mail address can not be normalized.
+var ErrInvalidEmail = errors.New("invalid e-mail address")
+
+// NormalizeEmail trims surrounding white space and converts domain part to lower case ASCII (IDNA) form.
+// Local part is left intact as it is case sensitive by the standard, stores should compare it
+// case insensitively.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndex(email, "@")
+	if at < 1 || at == len(email)-1 {
+		return "", fmt.Errorf("normalize %q: %w", email, ErrInvalidEmail)
+	}
+
+	domain, err := idna.Lookup.ToASCII(email[at+1:])
+	if err != nil {
+		return "", fmt.Errorf("normalize %q: %w", email, ErrInvalidEmail)
+	}
+
+	return email[:at+1] + strings.ToLower(domain), nil
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Delivery statuses of submissions.
+const (
+	// SubmissionPending submission is stored, but not yet delivered.
+	SubmissionPending = "pending"
+	// SubmissionSent submission is sent by e-mail.
+	SubmissionSent = "sent"
+	// SubmissionFailed the last attempt to send submission failed. Queued submissions may still be retried.
+	SubmissionFailed = "failed"
+)
+
+// Submission model is a message sent through the contact form.
+type Submission struct {
+	ID string `json:"id"`
+	// TenantID is set by stores from the tenant of the context the submission is inserted with.
+	TenantID  string `json:"tenantId,omitempty"`
+	FirstName string `json:"firstName,omitempty"`
+	LastName  string `json:"lastName,omitempty"`
+	Company   string `json:"company"`
+	Email     string `json:"email"`
+	Subject   string `json:"subject"`
+	Text      string `json:"text"`
+	// Language is BCP 47 tag of the language the form was submitted in.
+	Language  string `json:"language"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+	Status    string `json:"status"`
+	// MessageID is the ID of the e-mail returned by the mail provider which sent it.
+	MessageID string `json:"messageId,omitempty"`
+	Provider  string `json:"provider,omitempty"`
+	// Error is the cause of the last failed delivery.
+	Error     string     `json:"error,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	HandledAt *time.Time `json:"handledAt,omitempty"`
+	// HandledBy is the ID of the user who marked submission as handled.
+	HandledBy string `json:"handledBy,omitempty"`
+}
+
+// NewSubmission creates new pending submission model.
+func NewSubmission() (*Submission, error) {
+	id, err := NewID()
+	if err != nil {
+		return nil, fmt.Errorf("new uuid: %w", err)
+	}
+
+	return &Submission{ //nolint:exhaustivestruct
+		ID:     id.String(),
+		Status: SubmissionPending,
+	}, nil
+}
+
+// Name returns full name of the visitor.
+func (s *Submission) Name() string {
+	return strings.TrimSpace(s.FirstName + " " + s.LastName)
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -11,22 +12,54 @@ import (
 // ErrEmptyPassword is returned if user supplied an empty password.
 var ErrEmptyPassword = errors.New("empty password")
 
+// IDGenerator generates primary keys of new models.
+type IDGenerator func() (uuid.UUID, error)
+
+// NewID is used to generate primary keys of new models. It defaults to UUIDv7 so that keys sort by
+// creation time. It may be replaced at program start, any UUID version is accepted by stores.
+var NewID IDGenerator = uuid.NewV7 //nolint:gochecknoglobals
+
 // User model.
 type User struct {
-	ID       string `json:"id"`
+	ID string `json:"id"`
+	// TenantID is set by stores from the tenant of the context the user is inserted with.
+	TenantID string `json:"tenantId,omitempty"`
 	Email    string `json:"email"`
 	Password string `json:",omitempty"`
+	// Admin users may manage contact form submissions. It is granted by operators, users can't change it.
+	Admin bool `json:"admin"`
+	Profile
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// Version is incremented on each update and used for optimistic locking.
+	Version int `json:"version"`
+}
+
+// Profile contains user's personal preferences.
+type Profile struct {
+	DisplayName string `json:"displayName"`
+	// Locale is BCP 47 language tag, i.e. en-US.
+	Locale string `json:"locale"`
+	// Timezone is IANA time zone name, i.e. Europe/Berlin.
+	Timezone  string `json:"timezone"`
+	AvatarURL string `json:"avatarUrl"`
 }
 
 // NewUser creates new user model.
 func NewUser(email, password string) (*User, error) {
-	uuid, err := uuid.NewUUID()
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return nil, fmt.Errorf("new user: %w", err)
+	}
+
+	id, err := NewID()
 	if err != nil {
 		return nil, fmt.Errorf("new uuid: %w", err)
 	}
 
-	u := &User{
-		ID:       uuid.String(),
+	u := &User{ //nolint:exhaustivestruct
+		ID:       id.String(),
 		Email:    email,
 		Password: password,
 	}
@@ -0,0 +1,43 @@
+// Package outbox contains transactional outbox relay delivering messages stored together with domain
+// changes to registered handlers.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrNoHandler is returned when there is no handler registered for the message topic.
+var ErrNoHandler = errors.New("no handler")
+
+// Message is an outbox entry.
+type Message struct {
+	ID       int64
+	TenantID string
+	Topic    string
+	Payload  json.RawMessage
+	Attempts int
+}
+
+// Writer adds messages to outbox. Implementations write within the transaction found in context, so that
+// a message is stored only if the surrounding domain changes are committed.
+type Writer interface {
+	Add(ctx context.Context, topic string, payload interface{}) error
+}
+
+// Store is used by Relay to fetch and acknowledge messages.
+type Store interface {
+	// Claim returns up to limit pending messages and hides them from other relays for the lease duration.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*Message, error)
+	// Done marks message as delivered.
+	Done(ctx context.Context, id int64) error
+	// Retry records failed attempt and schedules next one.
+	Retry(ctx context.Context, id int64, next time.Time, cause string) error
+	// Fail marks message as permanently failed.
+	Fail(ctx context.Context, id int64, cause string) error
+}
+
+// Handler delivers message. Message is retried if handler returns an error, so handlers should be idempotent.
+type Handler func(ctx context.Context, msg *Message) error
@@ -0,0 +1,173 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/acim/arc/pkg/tenant"
+	"go.uber.org/zap"
+)
+
+// Relay polls outbox store and delivers messages to handlers registered by topic.
+type Relay struct {
+	store        Store
+	handlers     map[string]Handler
+	pollInterval time.Duration
+	batchSize    int
+	lease        time.Duration
+	maxAttempts  int
+	backoff      time.Duration
+	logger       *zap.Logger
+}
+
+// NewRelay creates new relay.
+func NewRelay(store Store, logger *zap.Logger, opts ...RelayOption) *Relay {
+	r := &Relay{
+		store:        store,
+		handlers:     make(map[string]Handler),
+		pollInterval: time.Second,
+		batchSize:    10,              //nolint:gomnd
+		lease:        time.Minute,     //nolint:gomnd
+		maxAttempts:  10,              //nolint:gomnd
+		backoff:      5 * time.Second, //nolint:gomnd
+		logger:       logger,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Handle registers handler for the topic.
+func (r *Relay) Handle(topic string, h Handler) {
+	r.handlers[topic] = h
+}
+
+// Run implements rest.Worker interface. Messages being delivered when context is canceled are finished
+// within the lease duration.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.drain(ctx)
+		}
+	}
+}
+
+// drain relays batches of messages until there are no more pending or context is canceled.
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		if r.relay(ctx) < r.batchSize {
+			return
+		}
+	}
+}
+
+// relay delivers a batch of messages and returns number of claimed messages.
+func (r *Relay) relay(ctx context.Context) int {
+	msgs, err := r.store.Claim(ctx, r.batchSize, r.lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Error("outbox claim", zap.Error(err))
+		}
+
+		return 0
+	}
+
+	for _, msg := range msgs {
+		r.deliver(msg)
+	}
+
+	return len(msgs)
+}
+
+func (r *Relay) deliver(msg *Message) {
+	// Delivery is detached from the worker context so that shutdown doesn't interrupt it.
+	ctx, cancel := context.WithTimeout(tenant.NewContext(context.Background(), msg.TenantID), r.lease)
+	defer cancel()
+
+	err := r.handle(ctx, msg)
+	if err == nil {
+		if err = r.store.Done(ctx, msg.ID); err != nil {
+			r.logger.Error("outbox done", zap.Int64("id", msg.ID), zap.Error(err))
+		}
+
+		return
+	}
+
+	r.logger.Warn("outbox deliver", zap.Int64("id", msg.ID), zap.String("topic", msg.Topic),
+		zap.Int("attempt", msg.Attempts+1), zap.Error(err))
+
+	if msg.Attempts+1 >= r.maxAttempts {
+		err = r.store.Fail(ctx, msg.ID, err.Error())
+	} else {
+		err = r.store.Retry(ctx, msg.ID, time.Now().Add(backoff(r.backoff, msg.Attempts)), err.Error())
+	}
+
+	if err != nil {
+		r.logger.Error("outbox record failure", zap.Int64("id", msg.ID), zap.Error(err))
+	}
+}
+
+func (r *Relay) handle(ctx context.Context, msg *Message) error {
+	h, ok := r.handlers[msg.Topic]
+	if !ok {
+		return fmt.Errorf("topic %s: %w", msg.Topic, ErrNoHandler)
+	}
+
+	return h(ctx, msg)
+}
+
+// backoff returns exponentially growing delay, capped at one day.
+func backoff(initial time.Duration, attempts int) time.Duration {
+	const maxDelay = 24 * time.Hour
+
+	if attempts > 30 { //nolint:gomnd
+		return maxDelay
+	}
+
+	if d := initial << attempts; d > 0 && d < maxDelay {
+		return d
+	}
+
+	return maxDelay
+}
+
+// RelayOption ...
+type RelayOption func(*Relay)
+
+// RelayPollInterval sets how often the store is polled for new messages.
+func RelayPollInterval(d time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.pollInterval = d
+	}
+}
+
+// RelayBatchSize sets maximum number of messages claimed at once.
+func RelayBatchSize(n int) RelayOption {
+	return func(r *Relay) {
+		r.batchSize = n
+	}
+}
+
+// RelayMaxAttempts sets number of delivery attempts after which message is marked as failed.
+func RelayMaxAttempts(n int) RelayOption {
+	return func(r *Relay) {
+		r.maxAttempts = n
+	}
+}
+
+// RelayBackoff sets delay before the first retry, doubled on each subsequent attempt.
+func RelayBackoff(d time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.backoff = d
+	}
+}
@@ -0,0 +1,270 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/tenant"
+	"go.uber.org/zap"
+)
+
+var errDeliver = errors.New("deliver")
+
+type retry struct {
+	id    int64
+	next  time.Time
+	cause string
+}
+
+// fakeStore hands out pending messages in order and records acknowledgements.
+type fakeStore struct {
+	mu      sync.Mutex
+	pending []*Message
+	claims  []int
+	done    []int64
+	retries []retry
+	failed  map[int64]string
+}
+
+func newFakeStore(msgs ...*Message) *fakeStore {
+	return &fakeStore{ //nolint:exhaustivestruct
+		pending: msgs,
+		failed:  make(map[int64]string),
+	}
+}
+
+func (s *fakeStore) Claim(_ context.Context, limit int, _ time.Duration) ([]*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if limit > len(s.pending) {
+		limit = len(s.pending)
+	}
+
+	msgs := s.pending[:limit]
+	s.pending = s.pending[limit:]
+	s.claims = append(s.claims, len(msgs))
+
+	return msgs, nil
+}
+
+func (s *fakeStore) Done(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.done = append(s.done, id)
+
+	return nil
+}
+
+func (s *fakeStore) Retry(_ context.Context, id int64, next time.Time, cause string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.retries = append(s.retries, retry{id: id, next: next, cause: cause})
+
+	return nil
+}
+
+func (s *fakeStore) Fail(_ context.Context, id int64, cause string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failed[id] = cause
+
+	return nil
+}
+
+func messages(n int) []*Message {
+	msgs := make([]*Message, 0, n)
+
+	for i := 1; i <= n; i++ {
+		msgs = append(msgs, &Message{ID: int64(i), TenantID: "t", Topic: "topic", Payload: nil, Attempts: 0})
+	}
+
+	return msgs
+}
+
+func TestRelayDeliversInOrderAndMarksDone(t *testing.T) {
+	t.Parallel()
+
+	s := newFakeStore(messages(5)...)
+	r := NewRelay(s, zap.NewNop(), RelayBatchSize(2))
+
+	var delivered []int64
+
+	r.Handle("topic", func(ctx context.Context, msg *Message) error {
+		if got := tenant.FromContext(ctx); got != msg.TenantID {
+			t.Errorf("tenant = %q, want %q", got, msg.TenantID)
+		}
+
+		delivered = append(delivered, msg.ID)
+
+		return nil
+	})
+
+	r.drain(context.Background())
+
+	want := []int64{1, 2, 3, 4, 5}
+
+	if !equal(delivered, want) {
+		t.Errorf("delivered %v, want %v", delivered, want)
+	}
+
+	if !equal(s.done, want) {
+		t.Errorf("done %v, want %v", s.done, want)
+	}
+
+	// Draining stops after the first batch which isn't full.
+	if len(s.claims) != 3 {
+		t.Errorf("claims %v, want 3", s.claims)
+	}
+
+	if len(s.retries) != 0 || len(s.failed) != 0 {
+		t.Errorf("retries %v, failed %v, want none", s.retries, s.failed)
+	}
+}
+
+func TestRelayRetriesWithBackoff(t *testing.T) {
+	t.Parallel()
+
+	msg := &Message{ID: 1, TenantID: "", Topic: "topic", Payload: nil, Attempts: 2}
+	s := newFakeStore(msg)
+	r := NewRelay(s, zap.NewNop(), RelayBackoff(time.Second), RelayMaxAttempts(5))
+	r.Handle("topic", func(context.Context, *Message) error { return errDeliver })
+
+	start := time.Now()
+
+	r.drain(context.Background())
+
+	if len(s.retries) != 1 {
+		t.Fatalf("retries %v, want one", s.retries)
+	}
+
+	// Third attempt failed, so the next one is delayed by the initial backoff doubled twice.
+	if next := s.retries[0].next; next.Before(start.Add(4*time.Second)) || next.After(time.Now().Add(4*time.Second)) {
+		t.Errorf("next attempt in %s, want 4s", next.Sub(start))
+	}
+
+	if s.retries[0].cause != errDeliver.Error() {
+		t.Errorf("cause = %q, want %q", s.retries[0].cause, errDeliver.Error())
+	}
+
+	if len(s.done) != 0 || len(s.failed) != 0 {
+		t.Errorf("done %v, failed %v, want none", s.done, s.failed)
+	}
+}
+
+func TestRelayFailsAfterMaxAttempts(t *testing.T) {
+	t.Parallel()
+
+	msg := &Message{ID: 1, TenantID: "", Topic: "topic", Payload: nil, Attempts: 4}
+	s := newFakeStore(msg)
+	r := NewRelay(s, zap.NewNop(), RelayMaxAttempts(5))
+	r.Handle("topic", func(context.Context, *Message) error { return errDeliver })
+
+	r.drain(context.Background())
+
+	if cause, ok := s.failed[1]; !ok || cause != errDeliver.Error() {
+		t.Errorf("failed %v, want message 1 failed with %q", s.failed, errDeliver.Error())
+	}
+
+	if len(s.retries) != 0 {
+		t.Errorf("retries %v, want none", s.retries)
+	}
+}
+
+func TestRelayRetriesWithoutHandler(t *testing.T) {
+	t.Parallel()
+
+	s := newFakeStore(messages(1)...)
+	r := NewRelay(s, zap.NewNop())
+
+	r.drain(context.Background())
+
+	if len(s.retries) != 1 || !strings.Contains(s.retries[0].cause, ErrNoHandler.Error()) {
+		t.Errorf("retries %v, want one caused by missing handler", s.retries)
+	}
+}
+
+func TestRelayRun(t *testing.T) {
+	t.Parallel()
+
+	s := newFakeStore(messages(3)...)
+	r := NewRelay(s, zap.NewNop(), RelayPollInterval(time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	delivered := make(chan int64, 3)
+
+	r.Handle("topic", func(_ context.Context, msg *Message) error {
+		delivered <- msg.ID
+
+		return nil
+	})
+
+	stopped := make(chan struct{})
+
+	go func() {
+		r.Run(ctx)
+		close(stopped)
+	}()
+
+	for want := int64(1); want <= 3; want++ {
+		select {
+		case id := <-delivered:
+			if id != want {
+				t.Errorf("delivered %d, want %d", id, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not delivered")
+		}
+	}
+
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("relay didn't stop")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{4, 16 * time.Second},
+		{17, 24 * time.Hour},
+		{31, 24 * time.Hour},
+		{100, 24 * time.Hour},
+	}
+
+	for _, tc := range tests {
+		if got := backoff(time.Second, tc.attempts); got != tc.want {
+			t.Errorf("backoff(1s, %d) = %s, want %s", tc.attempts, got, tc.want)
+		}
+	}
+}
+
+func equal(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
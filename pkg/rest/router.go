@@ -3,6 +3,7 @@ package rest
 import (
 	"net/http"
 
+	"github.com/acim/arc/pkg/i18n"
 	arcmw "github.com/acim/arc/pkg/middleware"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -28,11 +29,12 @@ func DefaultRouter(serviceName string, allowedOrigins []string, logger *zap.Logg
 	r.Use(middleware.Recoverer)
 	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
 		res := arcmw.ResponseFromContext(r.Context())
-		res.SetStatusNotFound(http.StatusText(http.StatusNotFound))
+		res.SetStatusNotFound(i18n.FromContext(r.Context()).T(http.StatusText(http.StatusNotFound)))
 	})
 	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
 		res := arcmw.ResponseFromContext(r.Context())
-		res.SetStatus(http.StatusMethodNotAllowed).AddError(http.StatusText(http.StatusMethodNotAllowed))
+		res.SetStatus(http.StatusMethodNotAllowed).
+			AddError(i18n.FromContext(r.Context()).T(http.StatusText(http.StatusMethodNotAllowed)))
 	})
 
 	return r
@@ -45,7 +47,7 @@ func getCORS(allowedOrigins []string) *cors.Cors {
 
 	return cors.New(cors.Options{ //nolint:exhaustivestruct
 		AllowedOrigins:   allowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
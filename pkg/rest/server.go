@@ -22,6 +22,7 @@ type Server struct {
 	server        *http.Server
 	metricsServer *http.Server
 	valve         *valve.Valve
+	workers       []namedWorker
 	logger        *zap.Logger
 }
 
@@ -44,7 +45,7 @@ func NewServer(serviceName string, serverPort, metricsPort int, router *chi.Mux,
 	return s
 }
 
-// Run starts REST and metrics servers.
+// Run starts REST and metrics servers and workers.
 func (s *Server) Run() {
 	go func() {
 		s.logger.Info("metrics server", zap.String("name", s.serviceName), zap.String("port", s.metricsServer.Addr))
@@ -54,6 +55,8 @@ func (s *Server) Run() {
 		}
 	}()
 
+	s.startWorkers()
+
 	go s.shutdown()
 
 	s.logger.Info("rest server", zap.String("name", s.serviceName), zap.String("port", s.server.Addr))
@@ -0,0 +1,60 @@
+package rest
+
+import (
+	"context"
+
+	"github.com/go-chi/valve"
+	"go.uber.org/zap"
+)
+
+// Worker is a background process run by Server alongside REST and metrics servers.
+type Worker interface {
+	// Run should return once the context is canceled, which happens when shutdown is activated.
+	Run(ctx context.Context)
+}
+
+// WorkerFunc is an adapter allowing use of ordinary functions as workers.
+type WorkerFunc func(ctx context.Context)
+
+// Run implements Worker interface.
+func (f WorkerFunc) Run(ctx context.Context) {
+	f(ctx)
+}
+
+// AddWorker registers worker to be started by Run. Shutdown waits for running workers to return.
+func (s *Server) AddWorker(name string, w Worker) {
+	s.workers = append(s.workers, namedWorker{name: name, worker: w})
+}
+
+type namedWorker struct {
+	name   string
+	worker Worker
+}
+
+func (s *Server) startWorkers() {
+	for _, w := range s.workers {
+		ctx, cancel := context.WithCancel(s.valve.Context())
+		lever := valve.Lever(ctx)
+
+		if err := lever.Open(); err != nil {
+			s.logger.Error("worker", zap.String("name", w.name), zap.Error(err))
+			cancel()
+
+			continue
+		}
+
+		go func() {
+			<-lever.Stop()
+			cancel()
+		}()
+
+		go func(w namedWorker) {
+			defer lever.Close()
+			defer cancel()
+
+			s.logger.Info("worker started", zap.String("name", w.name))
+			w.worker.Run(ctx)
+			s.logger.Info("worker stopped", zap.String("name", w.name))
+		}(w)
+	}
+}
@@ -0,0 +1,276 @@
+// Package schedule contains periodic task scheduler driven by cron expressions.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// Task is a periodic task. Context is canceled on shutdown.
+type Task func(ctx context.Context) error
+
+// Locker provides mutual exclusion of tasks across replicas.
+type Locker interface {
+	// TryLock attempts to acquire lock by name without waiting. If lock is acquired, the returned unlock
+	// function must be called to release it.
+	TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error)
+}
+
+var _ prometheus.Collector = (*Scheduler)(nil)
+
+// Scheduler runs tasks according to their cron schedules. Scheduler implements prometheus.Collector, it
+// should be registered by the caller.
+type Scheduler struct {
+	entries  []*entry
+	locker   Locker
+	lockHold time.Duration
+	location *time.Location
+	logger   *zap.Logger
+	lastRun  *prometheus.GaugeVec
+	nextRun  *prometheus.GaugeVec
+	runs     *prometheus.CounterVec
+}
+
+type entry struct {
+	name     string
+	schedule cron.Schedule
+	task     Task
+	next     time.Time
+	running  bool
+}
+
+// NewScheduler creates new scheduler.
+func NewScheduler(serviceName string, logger *zap.Logger, opts ...SchedulerOption) *Scheduler {
+	s := &Scheduler{ //nolint:exhaustivestruct
+		locker:   nil,
+		lockHold: 5 * time.Second, //nolint:gomnd
+		location: time.UTC,
+		logger:   logger,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.lastRun = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{ //nolint:exhaustivestruct
+			Name:        "schedule_task_last_run_timestamp_seconds",
+			Help:        "Time of the last completed run partitioned by task.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+		[]string{"task"},
+	)
+
+	s.nextRun = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{ //nolint:exhaustivestruct
+			Name:        "schedule_task_next_run_timestamp_seconds",
+			Help:        "Time of the next scheduled run partitioned by task.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+		[]string{"task"},
+	)
+
+	s.runs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{ //nolint:exhaustivestruct
+			Name:        "schedule_task_runs_total",
+			Help:        "Number of task runs partitioned by task and result (ok, error or skipped).",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+		[]string{"task", "result"},
+	)
+
+	return s
+}
+
+// Describe implements prometheus.Collector interface.
+func (s *Scheduler) Describe(ch chan<- *prometheus.Desc) {
+	s.lastRun.Describe(ch)
+	s.nextRun.Describe(ch)
+	s.runs.Describe(ch)
+}
+
+// Collect implements prometheus.Collector interface.
+func (s *Scheduler) Collect(ch chan<- prometheus.Metric) {
+	s.lastRun.Collect(ch)
+	s.nextRun.Collect(ch)
+	s.runs.Collect(ch)
+}
+
+// Add registers task to run according to standard five field cron expression. Descriptors like @hourly
+// and @every 10m are supported too. Tasks must be added before Run is called.
+func (s *Scheduler) Add(name, spec string, task Task) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("schedule %s: %w", name, err)
+	}
+
+	s.entries = append(s.entries, &entry{ //nolint:exhaustivestruct
+		name:     name,
+		schedule: schedule,
+		task:     task,
+	})
+
+	return nil
+}
+
+// Run implements rest.Worker interface. Run returns after the context is canceled and running tasks return.
+// Run of a task is skipped if its previous run hasn't finished yet.
+func (s *Scheduler) Run(ctx context.Context) {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+
+	defer wg.Wait()
+
+	now := time.Now().In(s.location)
+	for _, e := range s.entries {
+		s.schedule(e, now)
+	}
+
+	timer := time.NewTimer(s.wait(now))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		now = time.Now().In(s.location)
+
+		for _, e := range s.entries {
+			if e.next.After(now) {
+				continue
+			}
+
+			s.schedule(e, now)
+
+			mu.Lock()
+			running := e.running
+			e.running = true
+			mu.Unlock()
+
+			if running {
+				s.logger.Warn("schedule overlap", zap.String("task", e.name))
+				s.runs.WithLabelValues(e.name, "skipped").Inc()
+
+				continue
+			}
+
+			wg.Add(1)
+
+			go func(e *entry) {
+				defer wg.Done()
+
+				s.run(ctx, e)
+
+				mu.Lock()
+				e.running = false
+				mu.Unlock()
+			}(e)
+		}
+
+		timer.Reset(s.wait(now))
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, e *entry) {
+	start := time.Now()
+
+	if s.locker != nil {
+		unlock, ok, err := s.locker.TryLock(ctx, e.name)
+		if err != nil {
+			s.logger.Error("schedule lock", zap.String("task", e.name), zap.Error(err))
+			s.runs.WithLabelValues(e.name, "error").Inc()
+
+			return
+		}
+
+		if !ok {
+			s.logger.Debug("schedule locked by another replica", zap.String("task", e.name))
+			s.runs.WithLabelValues(e.name, "skipped").Inc()
+
+			return
+		}
+
+		defer s.release(ctx, unlock, start)
+	}
+
+	if err := e.task(ctx); err != nil {
+		s.logger.Error("schedule run", zap.String("task", e.name), zap.Error(err))
+		s.runs.WithLabelValues(e.name, "error").Inc()
+
+		return
+	}
+
+	s.logger.Info("schedule run", zap.String("task", e.name), zap.Duration("duration", time.Since(start)))
+	s.runs.WithLabelValues(e.name, "ok").Inc()
+	s.lastRun.WithLabelValues(e.name).SetToCurrentTime()
+}
+
+// release calls unlock once lock hold elapses since start, so that replicas whose clocks are slightly
+// behind don't run the same task again.
+func (s *Scheduler) release(ctx context.Context, unlock func(), start time.Time) {
+	t := time.NewTimer(time.Until(start.Add(s.lockHold)))
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+
+	unlock()
+}
+
+func (s *Scheduler) schedule(e *entry, now time.Time) {
+	e.next = e.schedule.Next(now)
+	s.nextRun.WithLabelValues(e.name).Set(float64(e.next.Unix()))
+}
+
+// wait returns duration until the earliest next run.
+func (s *Scheduler) wait(now time.Time) time.Duration {
+	const idle = time.Hour
+
+	d := idle
+
+	for _, e := range s.entries {
+		if w := e.next.Sub(now); w < d {
+			d = w
+		}
+	}
+
+	return d
+}
+
+// SchedulerOption ...
+type SchedulerOption func(*Scheduler)
+
+// SchedulerLocker makes each task run hold a lock, so that only one replica runs it.
+func SchedulerLocker(l Locker) SchedulerOption {
+	return func(s *Scheduler) {
+		s.locker = l
+	}
+}
+
+// SchedulerLockHold sets minimum duration for which lock is held, covering clock skew between replicas.
+// It should be shorter than the shortest interval between task runs. Default is 5 seconds.
+func SchedulerLockHold(d time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.lockHold = d
+	}
+}
+
+// SchedulerLocation sets time zone in which schedules are interpreted. Default is UTC.
+func SchedulerLocation(loc *time.Location) SchedulerOption {
+	return func(s *Scheduler) {
+		s.location = loc
+	}
+}
@@ -0,0 +1,281 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+var errTask = errors.New("task failed")
+
+// every is a schedule with sub-second interval, which cron expressions don't support.
+type every time.Duration
+
+func (d every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(d))
+}
+
+// memLocker is an in-memory Locker shared by schedulers standing in for replicas.
+type memLocker struct {
+	mu     sync.Mutex
+	locked map[string]bool
+	err    error
+}
+
+func newMemLocker() *memLocker {
+	return &memLocker{locked: make(map[string]bool)} //nolint:exhaustivestruct
+}
+
+func (l *memLocker) TryLock(_ context.Context, name string) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return nil, false, l.err
+	}
+
+	if l.locked[name] {
+		return nil, false, nil
+	}
+
+	l.locked[name] = true
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		delete(l.locked, name)
+	}, true, nil
+}
+
+func newTestScheduler(opts ...SchedulerOption) *Scheduler {
+	return NewScheduler("test", zap.NewNop(), append([]SchedulerOption{SchedulerLockHold(0)}, opts...)...)
+}
+
+// add registers task with sub-second interval.
+func add(s *Scheduler, name string, d time.Duration, task Task) *entry {
+	e := &entry{name: name, schedule: every(d), task: task} //nolint:exhaustivestruct
+	s.entries = append(s.entries, e)
+
+	return e
+}
+
+// start runs scheduler until returned function is called, which waits for Run to return.
+func start(s *Scheduler) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+
+	go func() {
+		s.Run(ctx)
+		close(stopped)
+	}()
+
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
+
+// waitFor polls condition until it's true or fails the test after a while.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerRunsTasks(t *testing.T) {
+	t.Parallel()
+
+	s := newTestScheduler()
+
+	var ok, failed int32
+
+	add(s, "ok", 5*time.Millisecond, func(context.Context) error {
+		atomic.AddInt32(&ok, 1)
+
+		return nil
+	})
+	add(s, "failing", 5*time.Millisecond, func(context.Context) error {
+		atomic.AddInt32(&failed, 1)
+
+		return errTask
+	})
+
+	stop := start(s)
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&ok) >= 3 && atomic.LoadInt32(&failed) >= 3 })
+	stop()
+
+	if n := testutil.ToFloat64(s.runs.WithLabelValues("ok", "ok")); n != float64(atomic.LoadInt32(&ok)) {
+		t.Errorf("ok runs = %v, want %d", n, atomic.LoadInt32(&ok))
+	}
+
+	if n := testutil.ToFloat64(s.runs.WithLabelValues("failing", "error")); n != float64(atomic.LoadInt32(&failed)) {
+		t.Errorf("failed runs = %v, want %d", n, atomic.LoadInt32(&failed))
+	}
+
+	if ts := testutil.ToFloat64(s.lastRun.WithLabelValues("ok")); ts < float64(time.Now().Add(-time.Minute).Unix()) {
+		t.Errorf("last run = %v, want recent", ts)
+	}
+
+	// Failed runs don't count as completed.
+	if n := testutil.CollectAndCount(s.lastRun); n != 1 {
+		t.Errorf("last run series = %d, want 1", n)
+	}
+
+	if n := testutil.CollectAndCount(s.nextRun); n != 2 {
+		t.Errorf("next run series = %d, want 2", n)
+	}
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	t.Parallel()
+
+	s := newTestScheduler()
+	release := make(chan struct{})
+
+	var running, runs int32
+
+	add(s, "slow", time.Millisecond, func(context.Context) error {
+		if atomic.AddInt32(&running, 1) > 1 {
+			t.Error("overlapping runs")
+		}
+
+		atomic.AddInt32(&runs, 1)
+		<-release
+		atomic.AddInt32(&running, -1)
+
+		return nil
+	})
+
+	stop := start(s)
+
+	waitFor(t, func() bool { return testutil.ToFloat64(s.runs.WithLabelValues("slow", "skipped")) >= 3 })
+	close(release)
+	stop()
+
+	if n := atomic.LoadInt32(&runs); n != 1 {
+		t.Errorf("runs = %d, want 1", n)
+	}
+}
+
+func TestSchedulerLockExcludesReplicas(t *testing.T) {
+	t.Parallel()
+
+	locker := newMemLocker()
+	a := newTestScheduler(SchedulerLocker(locker))
+	b := newTestScheduler(SchedulerLocker(locker))
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var runs int32
+
+	task := func(context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		close(started)
+		<-release
+
+		return nil
+	}
+
+	ea := add(a, "purge", time.Hour, task)
+	eb := add(b, "purge", time.Hour, task)
+	ctx := context.Background()
+	done := make(chan struct{})
+
+	go func() {
+		a.run(ctx, ea)
+		close(done)
+	}()
+
+	<-started
+	b.run(ctx, eb)
+	close(release)
+	<-done
+
+	if n := atomic.LoadInt32(&runs); n != 1 {
+		t.Errorf("runs = %d, want 1", n)
+	}
+
+	if n := testutil.ToFloat64(b.runs.WithLabelValues("purge", "skipped")); n != 1 {
+		t.Errorf("skipped runs = %v, want 1", n)
+	}
+
+	// Lock is released once the run finishes.
+	if _, ok, _ := locker.TryLock(ctx, "purge"); !ok {
+		t.Error("lock not released")
+	}
+}
+
+func TestSchedulerLockError(t *testing.T) {
+	t.Parallel()
+
+	locker := newMemLocker()
+	locker.err = errTask
+	s := newTestScheduler(SchedulerLocker(locker))
+	e := add(s, "purge", time.Hour, func(context.Context) error {
+		t.Error("task ran without lock")
+
+		return nil
+	})
+
+	s.run(context.Background(), e)
+
+	if n := testutil.ToFloat64(s.runs.WithLabelValues("purge", "error")); n != 1 {
+		t.Errorf("error runs = %v, want 1", n)
+	}
+}
+
+func TestSchedulerAdd(t *testing.T) {
+	t.Parallel()
+
+	s := newTestScheduler()
+
+	for _, spec := range []string{"0 3 * * *", "@daily", "@every 10m"} {
+		if err := s.Add(spec, spec, func(context.Context) error { return nil }); err != nil {
+			t.Errorf("add %q: %v", spec, err)
+		}
+	}
+
+	if err := s.Add("invalid", "every day", func(context.Context) error { return nil }); err == nil {
+		t.Error("added task with invalid schedule")
+	}
+}
+
+func TestSchedulerIsCollector(t *testing.T) {
+	t.Parallel()
+
+	s := newTestScheduler()
+	add(s, "purge", time.Hour, func(context.Context) error { return nil })
+	s.run(context.Background(), s.entries[0])
+
+	// Schedulers of the same service don't conflict until registered to the same registry.
+	if err := prometheus.NewRegistry().Register(newTestScheduler()); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	registry := prometheus.NewRegistry()
+
+	if err := registry.Register(s); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if n, err := testutil.GatherAndCount(registry, "schedule_task_runs_total"); err != nil || n != 1 {
+		t.Errorf("gathered %d runs series, %v, want 1", n, err)
+	}
+}
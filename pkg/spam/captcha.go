@@ -0,0 +1,116 @@
+package spam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var _ Verifier = (*HTTPVerifier)(nil)
+
+// Verification endpoints of supported CAPTCHA services.
+const (
+	HCaptchaURL  = "https://api.hcaptcha.com/siteverify"
+	TurnstileURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+)
+
+// Errors.
+var (
+	ErrCaptchaFailed      = errors.New("captcha verification failed")
+	ErrCaptchaUnavailable = errors.New("captcha service unavailable")
+)
+
+// Verifier verifies CAPTCHA response submitted by a client.
+type Verifier interface {
+	// Verify returns ErrCaptchaFailed if response is not valid. Other errors mean verification could not be
+	// done.
+	Verify(ctx context.Context, response, remoteIP string) error
+}
+
+// HTTPVerifier implements Verifier interface using siteverify protocol shared by hCaptcha, Cloudflare
+// Turnstile and reCAPTCHA.
+type HTTPVerifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewHTTPVerifier creates new verifier posting responses to url, i.e. HCaptchaURL or TurnstileURL.
+func NewHTTPVerifier(url, secret string, opts ...HTTPVerifierOption) *HTTPVerifier {
+	v := &HTTPVerifier{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second}, //nolint:exhaustivestruct,gomnd
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// Verify implements Verifier interface.
+func (v *HTTPVerifier) Verify(ctx context.Context, response, remoteIP string) error {
+	if response == "" {
+		return ErrCaptchaFailed
+	}
+
+	form := url.Values{"secret": {v.secret}, "response": {response}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("captcha verify: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("captcha verify: %w: %v", ErrCaptchaUnavailable, err) //nolint:errorlint
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha verify: %w: status %d", ErrCaptchaUnavailable, res.StatusCode)
+	}
+
+	result := &struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}{}
+
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		return fmt.Errorf("captcha verify: %w: %v", ErrCaptchaUnavailable, err) //nolint:errorlint
+	}
+
+	if !result.Success {
+		for _, code := range result.ErrorCodes {
+			if code == "missing-input-secret" || code == "invalid-input-secret" {
+				return fmt.Errorf("captcha verify: %w: %s", ErrCaptchaUnavailable, code)
+			}
+		}
+
+		return fmt.Errorf("%w: %s", ErrCaptchaFailed, strings.Join(result.ErrorCodes, ", "))
+	}
+
+	return nil
+}
+
+// HTTPVerifierOption ...
+type HTTPVerifierOption func(*HTTPVerifier)
+
+// HTTPVerifierClient sets http client used to call verification endpoint.
+func HTTPVerifierClient(c *http.Client) HTTPVerifierOption {
+	return func(v *HTTPVerifier) {
+		v.client = c
+	}
+}
@@ -0,0 +1,93 @@
+package spam_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/spam"
+)
+
+// newCaptchaServer starts a stand-in of siteverify endpoint accepting response "valid" from 192.0.2.1
+// when posted with secret "secret".
+func newCaptchaServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.ParseForm() != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.PostForm.Get("response") == "slow":
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		case r.PostForm.Get("response") == "broken":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.PostForm.Get("secret") != "secret":
+			fmt.Fprint(w, `{"success":false,"error-codes":["invalid-input-secret"]}`)
+		case r.PostForm.Get("response") != "valid" || r.PostForm.Get("remoteip") != "192.0.2.1":
+			fmt.Fprint(w, `{"success":false,"error-codes":["invalid-input-response"]}`)
+		default:
+			fmt.Fprint(w, `{"success":true}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestHTTPVerifier(t *testing.T) {
+	t.Parallel()
+
+	srv := newCaptchaServer(t)
+
+	tests := map[string]struct {
+		secret   string
+		response string
+		err      error
+	}{
+		"success":        {secret: "secret", response: "valid"},
+		"failure":        {secret: "secret", response: "invalid", err: spam.ErrCaptchaFailed},
+		"empty response": {secret: "secret", response: "", err: spam.ErrCaptchaFailed},
+		"invalid secret": {secret: "wrong", response: "valid", err: spam.ErrCaptchaUnavailable},
+		"server error":   {secret: "secret", response: "broken", err: spam.ErrCaptchaUnavailable},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			v := spam.NewHTTPVerifier(srv.URL, tc.secret)
+
+			if err := v.Verify(context.Background(), tc.response, "192.0.2.1"); !errors.Is(err, tc.err) {
+				t.Errorf("verify = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestHTTPVerifierTimeout(t *testing.T) {
+	t.Parallel()
+
+	srv := newCaptchaServer(t)
+	v := spam.NewHTTPVerifier(srv.URL, "secret",
+		spam.HTTPVerifierClient(&http.Client{Timeout: 50 * time.Millisecond})) //nolint:exhaustivestruct
+
+	err := v.Verify(context.Background(), "slow", "192.0.2.1")
+	if !errors.Is(err, spam.ErrCaptchaUnavailable) || errors.Is(err, spam.ErrCaptchaFailed) {
+		t.Errorf("verify = %v, want %v", err, spam.ErrCaptchaUnavailable)
+	}
+}
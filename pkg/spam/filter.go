@@ -0,0 +1,84 @@
+package spam
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Errors.
+var (
+	ErrTooManyLinks = errors.New("too many links")
+	ErrKeyword      = errors.New("forbidden keyword")
+)
+
+var linkRegexp = regexp.MustCompile(`(?i)\b(?:https?://|www\.)`)
+
+// Filter rejects texts using simple content heuristics.
+type Filter struct {
+	maxLinks int
+	keywords []string
+}
+
+// NewFilter creates new content filter.
+func NewFilter(opts ...FilterOption) *Filter {
+	f := &Filter{
+		maxLinks: 2, //nolint:gomnd
+		keywords: nil,
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// MaxLinks returns maximal number of links allowed.
+func (f *Filter) MaxLinks() int {
+	return f.maxLinks
+}
+
+// Check returns ErrTooManyLinks or ErrKeyword if texts contain more links than allowed in total or any of
+// the keywords.
+func (f *Filter) Check(texts ...string) error {
+	links := 0
+
+	for _, text := range texts {
+		links += len(linkRegexp.FindAllStringIndex(text, -1))
+
+		lower := strings.ToLower(text)
+		for _, k := range f.keywords {
+			if strings.Contains(lower, k) {
+				return ErrKeyword
+			}
+		}
+	}
+
+	if f.maxLinks >= 0 && links > f.maxLinks {
+		return ErrTooManyLinks
+	}
+
+	return nil
+}
+
+// FilterOption ...
+type FilterOption func(*Filter)
+
+// FilterMaxLinks sets maximal number of links allowed. Negative value disables the check.
+func FilterMaxLinks(n int) FilterOption {
+	return func(f *Filter) {
+		f.maxLinks = n
+	}
+}
+
+// FilterKeywords sets keywords which are not allowed, case insensitive.
+func FilterKeywords(keywords ...string) FilterOption {
+	return func(f *Filter) {
+		for _, k := range keywords {
+			if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+				f.keywords = append(f.keywords, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package spam_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/acim/arc/pkg/spam"
+)
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts  []spam.FilterOption
+		texts []string
+		err   error
+	}{
+		"no links": {texts: []string{"Hello", "How are you?"}},
+		"links at limit": {
+			texts: []string{"See https://example.com", "and www.example.org"},
+		},
+		"links above limit": {
+			texts: []string{"http://a.example", "HTTPS://b.example", "www.c.example"},
+			err:   spam.ErrTooManyLinks,
+		},
+		"links counted in total": {
+			opts:  []spam.FilterOption{spam.FilterMaxLinks(1)},
+			texts: []string{"http://a.example", "http://b.example"},
+			err:   spam.ErrTooManyLinks,
+		},
+		"no links allowed": {
+			opts:  []spam.FilterOption{spam.FilterMaxLinks(0)},
+			texts: []string{"visit www.example.com"},
+			err:   spam.ErrTooManyLinks,
+		},
+		"link check disabled": {
+			opts:  []spam.FilterOption{spam.FilterMaxLinks(-1)},
+			texts: []string{"http://a.example http://b.example http://c.example"},
+		},
+		"domain without scheme": {texts: []string{"example.com example.org example.net"}},
+		"keyword": {
+			opts:  []spam.FilterOption{spam.FilterKeywords(" Casino ", "", "crypto")},
+			texts: []string{"Hello", "Best CASINO bonus"},
+			err:   spam.ErrKeyword,
+		},
+		"no keyword": {
+			opts:  []spam.FilterOption{spam.FilterKeywords("casino")},
+			texts: []string{"Hello", "Quote request"},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := spam.NewFilter(tc.opts...).Check(tc.texts...); !errors.Is(err, tc.err) {
+				t.Errorf("check = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestFilterEmptyKeywordsIgnored(t *testing.T) {
+	t.Parallel()
+
+	if err := spam.NewFilter(spam.FilterKeywords("", " ")).Check("Hello world"); err != nil {
+		t.Errorf("check = %v, want nil", err)
+	}
+}
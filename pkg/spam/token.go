@@ -0,0 +1,97 @@
+// Package spam contains protection of public forms against automated submissions.
+package spam
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors.
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("expired token")
+	ErrTooFast      = errors.New("form submitted too fast")
+)
+
+// Tokens issues and verifies signed timestamp tokens. A form fetches a token when rendered and submits it
+// back, which proves it has not been posted sooner than minimal fill time.
+type Tokens struct {
+	secret []byte
+	minAge time.Duration
+	maxAge time.Duration
+}
+
+// NewTokens creates new token issuer signing tokens with secret.
+func NewTokens(secret string, opts ...TokensOption) *Tokens {
+	t := &Tokens{
+		secret: []byte(secret),
+		minAge: 3 * time.Second, //nolint:gomnd
+		maxAge: 2 * time.Hour,   //nolint:gomnd
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// Issue returns new token issued at now.
+func (t *Tokens) Issue(now time.Time) string {
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	return ts + "." + t.sign(ts)
+}
+
+// Verify checks that token is signed and submitted at now within allowed age.
+func (t *Tokens) Verify(token string, now time.Time) error {
+	ts, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(t.sign(ts))) {
+		return ErrInvalidToken
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	age := now.Sub(time.Unix(sec, 0))
+
+	switch {
+	case age < t.minAge:
+		return ErrTooFast
+	case age > t.maxAge:
+		return ErrExpiredToken
+	default:
+		return nil
+	}
+}
+
+func (t *Tokens) sign(ts string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	_, _ = mac.Write([]byte(ts))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// TokensOption ...
+type TokensOption func(*Tokens)
+
+// TokensMinAge sets minimal time between issuing and submitting token.
+func TokensMinAge(d time.Duration) TokensOption {
+	return func(t *Tokens) {
+		t.minAge = d
+	}
+}
+
+// TokensMaxAge sets time after which token expires.
+func TokensMaxAge(d time.Duration) TokensOption {
+	return func(t *Tokens) {
+		t.maxAge = d
+	}
+}
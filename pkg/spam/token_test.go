@@ -0,0 +1,53 @@
+package spam_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/spam"
+)
+
+func TestTokens(t *testing.T) {
+	t.Parallel()
+
+	tokens := spam.NewTokens("secret", spam.TokensMinAge(3*time.Second), spam.TokensMaxAge(time.Hour))
+	issued := time.Unix(1700000000, 0)
+	token := tokens.Issue(issued)
+
+	ts, signature, _ := strings.Cut(token, ".")
+	other := spam.NewTokens("other").Issue(issued)
+
+	tests := map[string]struct {
+		token string
+		now   time.Time
+		err   error
+	}{
+		"valid":              {token: token, now: issued.Add(time.Minute), err: nil},
+		"at minimal age":     {token: token, now: issued.Add(3 * time.Second), err: nil},
+		"at maximal age":     {token: token, now: issued.Add(time.Hour), err: nil},
+		"too fast":           {token: token, now: issued.Add(time.Second), err: spam.ErrTooFast},
+		"expired":            {token: token, now: issued.Add(time.Hour + time.Second), err: spam.ErrExpiredToken},
+		"other secret":       {token: other, now: issued.Add(time.Minute), err: spam.ErrInvalidToken},
+		"tampered timestamp": {token: "1700000100." + signature, now: issued.Add(time.Minute), err: spam.ErrInvalidToken},
+		"tampered signature": {
+			token: ts + "." + strings.Repeat("0", len(signature)), now: issued.Add(time.Minute),
+			err: spam.ErrInvalidToken,
+		},
+		"missing signature": {token: ts, now: issued.Add(time.Minute), err: spam.ErrInvalidToken},
+		"empty":             {token: "", now: issued.Add(time.Minute), err: spam.ErrInvalidToken},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tokens.Verify(tc.token, tc.now); !errors.Is(err, tc.err) {
+				t.Errorf("verify = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
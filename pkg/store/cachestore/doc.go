@@ -0,0 +1,2 @@
+// Package cachestore contains caching decorators for store implementations.
+package cachestore
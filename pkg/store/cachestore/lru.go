@@ -0,0 +1,155 @@
+package cachestore
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acim/arc/pkg/model"
+)
+
+type entry struct {
+	user    *model.User
+	expires time.Time
+}
+
+// lru is bounded least recently used cache of users with expiration, indexed by tenant and ID or e-mail.
+// Users loaded from the store are added only if they were not invalidated since the load started, so
+// that a load racing with an update can't put the stale user back.
+type lru struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	ll      *list.List
+	byID    map[string]*list.Element
+	byEmail map[string]*list.Element
+	// epoch is incremented on each invalidation, invalidated holds the epoch of the last invalidation of
+	// a user and is cleared once no loads are running.
+	epoch       uint64
+	invalidated map[string]uint64
+	loading     int
+}
+
+func newLRU(size int, ttl time.Duration) *lru {
+	return &lru{ //nolint:exhaustivestruct
+		size:        size,
+		ttl:         ttl,
+		ll:          list.New(),
+		byID:        make(map[string]*list.Element, size),
+		byEmail:     make(map[string]*list.Element, size),
+		invalidated: make(map[string]uint64),
+	}
+}
+
+func (c *lru) getByID(tenantID, id string) (*model.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.get(c.byID[idKey(tenantID, id)])
+}
+
+func (c *lru) getByEmail(tenantID, email string) (*model.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.get(c.byEmail[emailKey(tenantID, email)])
+}
+
+// beginLoad registers a running load and returns the epoch it started in.
+func (c *lru) beginLoad() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.loading++
+
+	return c.epoch
+}
+
+// endLoad adds user loaded by the load started in epoch, if any and not invalidated since.
+func (c *lru) endLoad(epoch uint64, u *model.User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if u != nil && c.invalidated[idKey(u.TenantID, u.ID)] <= epoch {
+		c.add(u)
+	}
+
+	c.loading--
+	if c.loading == 0 {
+		c.invalidated = make(map[string]uint64)
+	}
+}
+
+// add must be called with lock held.
+func (c *lru) add(u *model.User) {
+	c.removeElement(c.byID[idKey(u.TenantID, u.ID)])
+	c.removeElement(c.byEmail[emailKey(u.TenantID, u.Email)])
+
+	el := c.ll.PushFront(&entry{user: copyUser(u), expires: time.Now().Add(c.ttl)})
+	c.byID[idKey(u.TenantID, u.ID)] = el
+	c.byEmail[emailKey(u.TenantID, u.Email)] = el
+
+	for c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *lru) remove(tenantID, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+
+	if c.loading > 0 {
+		c.invalidated[idKey(tenantID, id)] = c.epoch
+	}
+
+	c.removeElement(c.byID[idKey(tenantID, id)])
+}
+
+// get must be called with lock held.
+func (c *lru) get(el *list.Element) (*model.User, bool) {
+	if el == nil {
+		return nil, false
+	}
+
+	e := el.Value.(*entry) //nolint:forcetypeassert
+
+	if time.Now().After(e.expires) {
+		c.removeElement(el)
+
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+
+	return copyUser(e.user), true
+}
+
+// removeElement must be called with lock held.
+func (c *lru) removeElement(el *list.Element) {
+	if el == nil {
+		return
+	}
+
+	e := c.ll.Remove(el).(*entry) //nolint:forcetypeassert
+	delete(c.byID, idKey(e.user.TenantID, e.user.ID))
+	delete(c.byEmail, emailKey(e.user.TenantID, e.user.Email))
+}
+
+func idKey(tenantID, id string) string {
+	return tenantID + "\x00" + id
+}
+
+// emailKey matches case insensitive e-mail lookup of the stores.
+func emailKey(tenantID, email string) string {
+	return tenantID + "\x00" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// copyUser prevents callers from modifying cached users, i.e. by clearing the password.
+func copyUser(u *model.User) *model.User {
+	c := *u
+
+	return &c
+}
@@ -0,0 +1,217 @@
+package cachestore
+
+import (
+	"context"
+	"time"
+
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+	"github.com/acim/arc/pkg/tenant"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	_ store.Users          = (*Users)(nil)
+	_ prometheus.Collector = (*Users)(nil)
+)
+
+// Users is store.Users decorator caching users found by ID or e-mail, separately for each tenant. Concurrent lookups of the same
+// missing user result in a single call to the decorated store. Lookups within a transaction or following a write
+// in the same store session bypass the cache, so that they see their own writes. Users implements
+// prometheus.Collector, it should be registered by the caller.
+type Users struct {
+	next        store.Users
+	size        int
+	ttl         time.Duration
+	loadTimeout time.Duration
+	cache       *lru
+	group       singleflight.Group
+	requests    *prometheus.CounterVec
+}
+
+// NewUsers creates new caching users store.
+func NewUsers(next store.Users, serviceName string, opts ...UsersOption) *Users {
+	u := &Users{ //nolint:exhaustivestruct
+		next:        next,
+		size:        1000,             //nolint:gomnd
+		ttl:         time.Minute,      //nolint:gomnd
+		loadTimeout: 10 * time.Second, //nolint:gomnd
+	}
+
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	u.cache = newLRU(u.size, u.ttl)
+
+	u.requests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{ //nolint:exhaustivestruct
+			Name:        "store_cache_requests_total",
+			Help:        "Number of store cache lookups partitioned by store and result (hit or miss).",
+			ConstLabels: prometheus.Labels{"service": serviceName, "store": "users"},
+		},
+		[]string{"result"},
+	)
+
+	return u
+}
+
+// Describe implements prometheus.Collector interface.
+func (s *Users) Describe(ch chan<- *prometheus.Desc) {
+	s.requests.Describe(ch)
+}
+
+// Collect implements prometheus.Collector interface.
+func (s *Users) Collect(ch chan<- prometheus.Metric) {
+	s.requests.Collect(ch)
+}
+
+// FindByID implements store.Users interface.
+func (s *Users) FindByID(ctx context.Context, id string) (*model.User, error) {
+	if bypass(ctx) {
+		return s.next.FindByID(ctx, id) //nolint:wrapcheck
+	}
+
+	tenantID := tenant.FromContext(ctx)
+
+	if u, ok := s.cache.getByID(tenantID, id); ok {
+		s.requests.WithLabelValues("hit").Inc()
+
+		return u, nil
+	}
+
+	s.requests.WithLabelValues("miss").Inc()
+
+	return s.load(ctx, "id:"+idKey(tenantID, id), func(ctx context.Context) (*model.User, error) {
+		return s.next.FindByID(ctx, id) //nolint:wrapcheck
+	})
+}
+
+// FindByEmail implements store.Users interface.
+func (s *Users) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	if bypass(ctx) {
+		return s.next.FindByEmail(ctx, email) //nolint:wrapcheck
+	}
+
+	tenantID := tenant.FromContext(ctx)
+
+	if u, ok := s.cache.getByEmail(tenantID, email); ok {
+		s.requests.WithLabelValues("hit").Inc()
+
+		return u, nil
+	}
+
+	s.requests.WithLabelValues("miss").Inc()
+
+	return s.load(ctx, "email:"+emailKey(tenantID, email), func(ctx context.Context) (*model.User, error) {
+		return s.next.FindByEmail(ctx, email) //nolint:wrapcheck
+	})
+}
+
+// List implements store.Users interface. Results are not cached.
+func (s *Users) List(ctx context.Context, after string, limit int) ([]*model.User, error) {
+	return s.next.List(ctx, after, limit) //nolint:wrapcheck
+}
+
+// Insert implements store.Users interface.
+func (s *Users) Insert(ctx context.Context, user *model.User) error {
+	return s.next.Insert(ctx, user) //nolint:wrapcheck
+}
+
+// Update implements store.Users interface. User is evicted from cache both before and after the update
+// so that concurrent lookups can't cache stale data.
+func (s *Users) Update(ctx context.Context, user *model.User) error {
+	tenantID := tenant.FromContext(ctx)
+
+	s.cache.remove(tenantID, user.ID)
+	defer s.cache.remove(tenantID, user.ID)
+
+	return s.next.Update(ctx, user) //nolint:wrapcheck
+}
+
+// Delete implements store.Users interface.
+func (s *Users) Delete(ctx context.Context, id string) error {
+	tenantID := tenant.FromContext(ctx)
+
+	s.cache.remove(tenantID, id)
+	defer s.cache.remove(tenantID, id)
+
+	return s.next.Delete(ctx, id) //nolint:wrapcheck
+}
+
+// load calls find once for all concurrent callers with the same key and caches the result. Find runs with
+// a context detached from the callers, so that a canceled caller doesn't fail the others, each caller
+// waits only until its own context is done. Detached context keeps the tenant and request ID of the caller
+// starting the load.
+func (s *Users) load(ctx context.Context, key string,
+	find func(ctx context.Context) (*model.User, error),
+) (*model.User, error) {
+	ch := s.group.DoChan(key, func() (interface{}, error) {
+		epoch := s.cache.beginLoad()
+
+		lctx, cancel := context.WithTimeout(detach(ctx), s.loadTimeout)
+		defer cancel()
+
+		u, err := find(lctx)
+		s.cache.endLoad(epoch, u)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return u, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err() //nolint:wrapcheck
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err //nolint:wrapcheck
+		}
+
+		return copyUser(res.Val.(*model.User)), nil //nolint:forcetypeassert
+	}
+}
+
+// detach returns context carrying tenant and request ID found in ctx, but not its deadline or cancellation.
+func detach(ctx context.Context) context.Context {
+	dctx := tenant.NewContext(context.Background(), tenant.FromContext(ctx))
+
+	if id := middleware.GetReqID(ctx); id != "" {
+		dctx = context.WithValue(dctx, middleware.RequestIDKey, id)
+	}
+
+	return dctx
+}
+
+// bypass returns true if lookup should not use cache since it may not see writes made in its context.
+func bypass(ctx context.Context) bool {
+	return store.InTx(ctx) || store.Written(ctx)
+}
+
+// UsersOption ...
+type UsersOption func(*Users)
+
+// UsersSize sets maximum number of cached users.
+func UsersSize(n int) UsersOption {
+	return func(u *Users) {
+		u.size = n
+	}
+}
+
+// UsersTTL sets how long users are kept in cache.
+func UsersTTL(d time.Duration) UsersOption {
+	return func(u *Users) {
+		u.ttl = d
+	}
+}
+
+// UsersLoadTimeout sets timeout of loading users from the decorated store.
+func UsersLoadTimeout(d time.Duration) UsersOption {
+	return func(u *Users) {
+		u.loadTimeout = d
+	}
+}
@@ -0,0 +1,363 @@
+package cachestore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+	"github.com/acim/arc/pkg/tenant"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// fakeUsers counts lookups and optionally blocks them until gate is closed. Lookups return users as they
+// were when the lookup started. Request ID of the last lookup is recorded.
+type fakeUsers struct {
+	mu        sync.Mutex
+	requestID string
+	users     map[string]*model.User
+	finds     atomic.Int32
+	gate      chan struct{}
+}
+
+func newFakeUsers(users ...*model.User) *fakeUsers {
+	f := &fakeUsers{users: make(map[string]*model.User)} //nolint:exhaustivestruct
+
+	for _, u := range users {
+		f.users[idKey(u.TenantID, u.ID)] = u
+	}
+
+	return f
+}
+
+func (f *fakeUsers) find(ctx context.Context, match func(*model.User) bool) (*model.User, error) {
+	f.finds.Add(1)
+
+	var found *model.User
+
+	f.mu.Lock()
+	f.requestID = middleware.GetReqID(ctx)
+	for _, u := range f.users {
+		if u.TenantID == tenant.FromContext(ctx) && match(u) {
+			found = copyUser(u)
+		}
+	}
+	f.mu.Unlock()
+
+	if f.gate != nil {
+		select {
+		case <-f.gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if found == nil {
+		return nil, store.ErrNotFound
+	}
+
+	return found, nil
+}
+
+func (f *fakeUsers) FindByID(ctx context.Context, id string) (*model.User, error) {
+	return f.find(ctx, func(u *model.User) bool { return u.ID == id })
+}
+
+func (f *fakeUsers) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	return f.find(ctx, func(u *model.User) bool { return u.Email == email })
+}
+
+func (f *fakeUsers) List(context.Context, string, int) ([]*model.User, error) {
+	return nil, nil
+}
+
+func (f *fakeUsers) Insert(context.Context, *model.User) error {
+	return nil
+}
+
+func (f *fakeUsers) Update(ctx context.Context, u *model.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.users[idKey(tenant.FromContext(ctx), u.ID)] = copyUser(u)
+
+	return nil
+}
+
+func (f *fakeUsers) Delete(context.Context, string) error {
+	return nil
+}
+
+func user(id string) *model.User {
+	return &model.User{ID: id, Email: id + "@example.com"} //nolint:exhaustivestruct
+}
+
+func TestUsersCachesLookups(t *testing.T) {
+	t.Parallel()
+
+	next := newFakeUsers(user("a"))
+	users := NewUsers(next, "test")
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := users.FindByID(ctx, "a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := users.FindByEmail(ctx, "A@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := next.finds.Load(); n != 1 {
+		t.Errorf("finds = %d, want 1", n)
+	}
+}
+
+func TestUsersSeparatesTenants(t *testing.T) {
+	t.Parallel()
+
+	other := user("a")
+	other.TenantID = "other"
+	other.Email = "other@example.com"
+	users := NewUsers(newFakeUsers(user("a"), other), "test")
+
+	u, err := users.FindByID(tenant.NewContext(context.Background(), "other"), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Email != "other@example.com" {
+		t.Errorf("email = %s, want other@example.com", u.Email)
+	}
+
+	if u, _ = users.FindByID(context.Background(), "a"); u.Email != "a@example.com" {
+		t.Errorf("email = %s, want a@example.com", u.Email)
+	}
+}
+
+func TestUsersLoadKeepsRequestID(t *testing.T) {
+	t.Parallel()
+
+	next := newFakeUsers(user("a"))
+	users := NewUsers(next, "test")
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-1")
+
+	if _, err := users.FindByID(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	next.mu.Lock()
+	defer next.mu.Unlock()
+
+	if next.requestID != "req-1" {
+		t.Errorf("request id = %q, want req-1", next.requestID)
+	}
+}
+
+func TestUsersExpires(t *testing.T) {
+	t.Parallel()
+
+	next := newFakeUsers(user("a"))
+	users := NewUsers(next, "test", UsersTTL(10*time.Millisecond))
+	ctx := context.Background()
+
+	_, _ = users.FindByID(ctx, "a")
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, _ = users.FindByID(ctx, "a")
+
+	if n := next.finds.Load(); n != 2 {
+		t.Errorf("finds = %d, want 2", n)
+	}
+}
+
+func TestUsersEvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	next := newFakeUsers(user("a"), user("b"), user("c"))
+	users := NewUsers(next, "test", UsersSize(2))
+	ctx := context.Background()
+
+	for _, id := range []string{"a", "b", "a", "c"} {
+		_, _ = users.FindByID(ctx, id)
+	}
+
+	next.finds.Store(0)
+
+	_, _ = users.FindByID(ctx, "a")
+	_, _ = users.FindByID(ctx, "c")
+
+	if n := next.finds.Load(); n != 0 {
+		t.Errorf("finds of recently used = %d, want 0", n)
+	}
+
+	_, _ = users.FindByID(ctx, "b")
+
+	if n := next.finds.Load(); n != 1 {
+		t.Errorf("finds of evicted = %d, want 1", n)
+	}
+}
+
+func TestUsersSharesConcurrentLoads(t *testing.T) {
+	t.Parallel()
+
+	next := newFakeUsers(user("a"))
+	next.gate = make(chan struct{})
+	users := NewUsers(next, "test")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := users.FindByID(context.Background(), "a"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(next.gate)
+	wg.Wait()
+
+	if n := next.finds.Load(); n != 1 {
+		t.Errorf("finds = %d, want 1", n)
+	}
+}
+
+func TestUsersCanceledCallerDoesNotFailOthers(t *testing.T) {
+	t.Parallel()
+
+	next := newFakeUsers(user("a"))
+	next.gate = make(chan struct{})
+	users := NewUsers(next, "test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+
+	go func() {
+		_, err := users.FindByID(ctx, "a")
+		first <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	second := make(chan error)
+
+	go func() {
+		_, err := users.FindByID(context.Background(), "a")
+		second <- err
+	}()
+
+	cancel()
+
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled caller error = %v, want context.Canceled", err)
+	}
+
+	close(next.gate)
+
+	if err := <-second; err != nil {
+		t.Errorf("waiting caller error = %v, want nil", err)
+	}
+}
+
+func TestUsersLoadRacingUpdateIsNotCached(t *testing.T) {
+	t.Parallel()
+
+	next := newFakeUsers(user("a"))
+	next.gate = make(chan struct{})
+	users := NewUsers(next, "test")
+	ctx := context.Background()
+	loaded := make(chan *model.User)
+
+	go func() {
+		u, _ := users.FindByID(ctx, "a")
+		loaded <- u
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	// Update completes while the load is running, the load then returns the stale user.
+	updated := user("a")
+	updated.DisplayName = "updated"
+
+	if err := users.Update(ctx, updated); err != nil {
+		t.Fatal(err)
+	}
+
+	close(next.gate)
+
+	if u := <-loaded; u.DisplayName != "" {
+		t.Fatal("load didn't return stale user")
+	}
+
+	next.gate = nil
+
+	u, err := users.FindByID(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.DisplayName != "updated" {
+		t.Error("stale user loaded concurrently with update was cached")
+	}
+}
+
+func TestUsersUpdateEvicts(t *testing.T) {
+	t.Parallel()
+
+	next := newFakeUsers(user("a"))
+	users := NewUsers(next, "test")
+	ctx := context.Background()
+
+	u, _ := users.FindByID(ctx, "a")
+	u.DisplayName = "updated"
+
+	if err := users.Update(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+
+	if u, _ = users.FindByID(ctx, "a"); u.DisplayName != "updated" {
+		t.Errorf("display name = %q, want updated", u.DisplayName)
+	}
+}
+
+func TestUsersBypassesCache(t *testing.T) {
+	t.Parallel()
+
+	written := store.NewSession(context.Background())
+	store.MarkWritten(written)
+
+	for name, ctx := range map[string]context.Context{
+		"transaction":   store.NewTxContext(context.Background()),
+		"session write": written,
+	} {
+		next := newFakeUsers(user("a"))
+		users := NewUsers(next, "test")
+
+		_, _ = users.FindByID(ctx, "a")
+		_, _ = users.FindByID(ctx, "a")
+		_, _ = users.FindByEmail(ctx, "a@example.com")
+
+		if n := next.finds.Load(); n != 3 {
+			t.Errorf("%s: finds = %d, want 3", name, n)
+		}
+
+		next.finds.Store(0)
+		_, _ = users.FindByID(context.Background(), "a")
+
+		if n := next.finds.Load(); n != 1 {
+			t.Errorf("%s: bypassed lookup was cached", name)
+		}
+	}
+}
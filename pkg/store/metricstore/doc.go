@@ -0,0 +1,2 @@
+// Package metricstore contains store decorators exposing query metrics and logging slow queries.
+package metricstore
@@ -0,0 +1,111 @@
+package metricstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/acim/arc/pkg/store"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var _ prometheus.Collector = (*Instrumenter)(nil)
+
+// Instrumenter records duration and errors of store method calls. It is shared by all instrumented stores.
+// Instrumenter implements prometheus.Collector, it should be registered by the caller.
+type Instrumenter struct {
+	logger        *zap.Logger
+	slowThreshold time.Duration
+	buckets       []float64
+	duration      *prometheus.HistogramVec
+	errors        *prometheus.CounterVec
+}
+
+// NewInstrumenter creates new instrumenter.
+func NewInstrumenter(serviceName string, logger *zap.Logger, opts ...InstrumenterOption) *Instrumenter {
+	in := &Instrumenter{ //nolint:exhaustivestruct
+		logger:        logger,
+		slowThreshold: 200 * time.Millisecond,                                 //nolint:gomnd
+		buckets:       []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1}, //nolint:gomnd
+	}
+
+	for _, opt := range opts {
+		opt(in)
+	}
+
+	in.duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{ //nolint:exhaustivestruct
+		Name:        "store_query_duration_seconds",
+		Help:        "Duration of store method calls partitioned by store and method.",
+		ConstLabels: prometheus.Labels{"service": serviceName},
+		Buckets:     in.buckets,
+	},
+		[]string{"store", "method"},
+	)
+
+	in.errors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{ //nolint:exhaustivestruct
+			Name:        "store_query_errors_total",
+			Help:        "Number of failed store method calls partitioned by store and method.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+		[]string{"store", "method"},
+	)
+
+	return in
+}
+
+// Describe implements prometheus.Collector interface.
+func (in *Instrumenter) Describe(ch chan<- *prometheus.Desc) {
+	in.duration.Describe(ch)
+	in.errors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector interface.
+func (in *Instrumenter) Collect(ch chan<- prometheus.Metric) {
+	in.duration.Collect(ch)
+	in.errors.Collect(ch)
+}
+
+// Observe calls fn and records its duration. Errors other than store.ErrNotFound are counted and calls
+// slower than the threshold are logged together with the request ID found in context.
+func (in *Instrumenter) Observe(ctx context.Context, storeName, method string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	elapsed := time.Since(start)
+
+	in.duration.WithLabelValues(storeName, method).Observe(elapsed.Seconds())
+
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		in.errors.WithLabelValues(storeName, method).Inc()
+	}
+
+	if elapsed >= in.slowThreshold {
+		in.logger.Warn("slow query",
+			zap.String("store", storeName),
+			zap.String("method", method),
+			zap.Duration("duration", elapsed),
+			zap.Error(err),
+			zap.String("request_id", middleware.GetReqID(ctx)))
+	}
+
+	return err
+}
+
+// InstrumenterOption ...
+type InstrumenterOption func(*Instrumenter)
+
+// InstrumenterSlowThreshold sets duration above which calls are logged.
+func InstrumenterSlowThreshold(d time.Duration) InstrumenterOption {
+	return func(in *Instrumenter) {
+		in.slowThreshold = d
+	}
+}
+
+// InstrumenterBuckets sets histogram buckets in seconds.
+func InstrumenterBuckets(buckets []float64) InstrumenterOption {
+	return func(in *Instrumenter) {
+		in.buckets = buckets
+	}
+}
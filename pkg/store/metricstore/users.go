@@ -0,0 +1,80 @@
+package metricstore
+
+import (
+	"context"
+
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+)
+
+var _ store.Users = (*Users)(nil)
+
+const usersStore = "users"
+
+// Users is store.Users decorator recording metrics of each call.
+type Users struct {
+	next store.Users
+	in   *Instrumenter
+}
+
+// NewUsers creates new instrumented users store.
+func NewUsers(next store.Users, in *Instrumenter) *Users {
+	return &Users{
+		next: next,
+		in:   in,
+	}
+}
+
+// FindByID implements store.Users interface.
+func (s *Users) FindByID(ctx context.Context, id string) (u *model.User, err error) {
+	err = s.in.Observe(ctx, usersStore, "FindByID", func() error {
+		u, err = s.next.FindByID(ctx, id)
+
+		return err //nolint:wrapcheck
+	})
+
+	return u, err
+}
+
+// FindByEmail implements store.Users interface.
+func (s *Users) FindByEmail(ctx context.Context, email string) (u *model.User, err error) {
+	err = s.in.Observe(ctx, usersStore, "FindByEmail", func() error {
+		u, err = s.next.FindByEmail(ctx, email)
+
+		return err //nolint:wrapcheck
+	})
+
+	return u, err
+}
+
+// List implements store.Users interface.
+func (s *Users) List(ctx context.Context, after string, limit int) (users []*model.User, err error) {
+	err = s.in.Observe(ctx, usersStore, "List", func() error {
+		users, err = s.next.List(ctx, after, limit)
+
+		return err //nolint:wrapcheck
+	})
+
+	return users, err
+}
+
+// Insert implements store.Users interface.
+func (s *Users) Insert(ctx context.Context, user *model.User) error {
+	return s.in.Observe(ctx, usersStore, "Insert", func() error {
+		return s.next.Insert(ctx, user) //nolint:wrapcheck
+	})
+}
+
+// Update implements store.Users interface.
+func (s *Users) Update(ctx context.Context, user *model.User) error {
+	return s.in.Observe(ctx, usersStore, "Update", func() error {
+		return s.next.Update(ctx, user) //nolint:wrapcheck
+	})
+}
+
+// Delete implements store.Users interface.
+func (s *Users) Delete(ctx context.Context, id string) error {
+	return s.in.Observe(ctx, usersStore, "Delete", func() error {
+		return s.next.Delete(ctx, id) //nolint:wrapcheck
+	})
+}
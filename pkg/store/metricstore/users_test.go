@@ -0,0 +1,121 @@
+package metricstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var errDatabase = errors.New("database unavailable")
+
+// fakeUsers finds user with ID "a" after the delay, fails for user with ID "broken" and misses others.
+type fakeUsers struct {
+	store.Users
+	delay time.Duration
+}
+
+func (f fakeUsers) FindByID(_ context.Context, id string) (*model.User, error) {
+	time.Sleep(f.delay)
+
+	switch id {
+	case "a":
+		return &model.User{ID: id}, nil //nolint:exhaustivestruct
+	case "broken":
+		return nil, errDatabase
+	default:
+		return nil, store.ErrNotFound
+	}
+}
+
+func TestUsersRecordsCalls(t *testing.T) {
+	t.Parallel()
+
+	in := NewInstrumenter("test", zap.NewNop())
+	users := NewUsers(fakeUsers{}, in) //nolint:exhaustivestruct
+	ctx := context.Background()
+
+	if u, err := users.FindByID(ctx, "a"); err != nil || u.ID != "a" {
+		t.Errorf("find = %v, %v, want user a", u, err)
+	}
+
+	if _, err := users.FindByID(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("find missing error = %v, want ErrNotFound", err)
+	}
+
+	if _, err := users.FindByID(ctx, "broken"); !errors.Is(err, errDatabase) {
+		t.Errorf("find broken error = %v, want %v", err, errDatabase)
+	}
+
+	if n := testutil.CollectAndCount(in.duration); n != 1 {
+		t.Errorf("duration series = %d, want 1", n)
+	}
+
+	// Not found users are expected, only other errors are counted.
+	if n := testutil.ToFloat64(in.errors.WithLabelValues(usersStore, "FindByID")); n != 1 {
+		t.Errorf("errors = %v, want 1", n)
+	}
+}
+
+func TestUsersLogsSlowCalls(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	in := NewInstrumenter("test", zap.New(core), InstrumenterSlowThreshold(10*time.Millisecond))
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-1")
+
+	if _, err := NewUsers(fakeUsers{}, in).FindByID(ctx, "a"); err != nil { //nolint:exhaustivestruct
+		t.Fatal(err)
+	}
+
+	if n := logs.Len(); n != 0 {
+		t.Errorf("logged %d fast calls", n)
+	}
+
+	slow := fakeUsers{delay: 20 * time.Millisecond} //nolint:exhaustivestruct
+
+	if _, err := NewUsers(slow, in).FindByID(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logs.FilterMessage("slow query").All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d slow calls, want 1", len(entries))
+	}
+
+	fields := entries[0].ContextMap()
+	if fields["request_id"] != "req-1" || fields["store"] != usersStore || fields["method"] != "FindByID" {
+		t.Errorf("logged %v, want request_id, store and method", fields)
+	}
+}
+
+func TestInstrumenterIsCollector(t *testing.T) {
+	t.Parallel()
+
+	// Instrumenters of the same service don't conflict until registered to the same registry.
+	a := NewInstrumenter("test", zap.NewNop())
+	b := NewInstrumenter("test", zap.NewNop())
+
+	if err := prometheus.NewRegistry().Register(a); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	registry := prometheus.NewRegistry()
+
+	if err := registry.Register(b); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if err := registry.Register(NewInstrumenter("test", zap.NewNop())); err == nil {
+		t.Error("registered the same metrics twice")
+	}
+}
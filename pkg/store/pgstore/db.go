@@ -1,15 +1,57 @@
 package pgstore
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// NewDB creates new Postgres database handle.
-func NewDB(hostname, username, password, databaseName string) (*sqlx.DB, error) {
+// NewDB creates new Postgres connection pool and pings the server.
+func NewDB(ctx context.Context, hostname, username, password, databaseName string) (*pgxpool.Pool, error) {
+	pool, err := NewReplicaDB(ctx, hostname, username, password, databaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
+
+		return nil, fmt.Errorf("ping: %w", err)
+	}
+
+	return pool, nil
+}
+
+// NewReplicaDB creates connection pool like NewDB, but without connecting to the server, so that an
+// unreachable replica doesn't prevent startup. Its health is checked by Replicas instead.
+func NewReplicaDB(ctx context.Context, hostname, username, password, databaseName string) (*pgxpool.Pool, error) {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=disable",
 		hostname, username, password, databaseName)
 
-	return sqlx.Connect("pgx", dsn) //nolint:wrapcheck
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("new pool: %w", err)
+	}
+
+	return pool, nil
+}
+
+// querier is implemented by both *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// conn returns the transaction from context, if any, or the pool otherwise.
+func conn(ctx context.Context, pool *pgxpool.Pool) querier { //nolint:ireturn
+	if tx := txFromContext(ctx); tx != nil {
+		return tx
+	}
+
+	return pool
 }
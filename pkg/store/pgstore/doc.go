@@ -1,2 +1,8 @@
 // Package pgstore contains PostgreSQL store implementation.
+//
+// Tenant scope: users and submissions are scoped to the tenant found in context by their queries, users
+// may additionally be isolated by row level security (MigratorRowLevelSecurity and UsersRowLevelSecurity).
+// Outbox messages and jobs record the tenant they were created in, but are claimed across all tenants by
+// relays and workers, which restore the tenant into the handler's context. Mail events and suppressions
+// are global, because they are reported by the mail provider per address and not per tenant.
 package pgstore
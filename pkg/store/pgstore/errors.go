@@ -0,0 +1,48 @@
+package pgstore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/acim/arc/pkg/store"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLSTATE codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html.
+const (
+	codeUniqueViolation     = "23505"
+	codeExclusionViolation  = "23P01"
+	codeForeignKeyViolation = "23503"
+	codeNotNullViolation    = "23502"
+	codeCheckViolation      = "23514"
+	codeStringTooLong       = "22001"
+)
+
+// mapError translates Postgres errors to store sentinel errors.
+func mapError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case codeUniqueViolation, codeExclusionViolation:
+		return fmt.Errorf("%w: %s", store.ErrConflict, describe(pgErr))
+	case codeForeignKeyViolation, codeNotNullViolation, codeCheckViolation, codeStringTooLong:
+		return fmt.Errorf("%w: %s", store.ErrConstraint, describe(pgErr))
+	default:
+		return err
+	}
+}
+
+func describe(pgErr *pgconn.PgError) string {
+	if pgErr.ConstraintName != "" {
+		return fmt.Sprintf("%s (%s)", pgErr.Message, pgErr.ConstraintName)
+	}
+
+	if pgErr.ColumnName != "" {
+		return fmt.Sprintf("%s (%s)", pgErr.Message, pgErr.ColumnName)
+	}
+
+	return pgErr.Message
+}
@@ -0,0 +1,127 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/acim/arc/pkg/job"
+	"github.com/acim/arc/pkg/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ job.Store = (*Jobs)(nil)
+
+// Jobs implements job.Store interface.
+type Jobs struct {
+	pool *pgxpool.Pool
+}
+
+// NewJobs creates new jobs store.
+func NewJobs(pool *pgxpool.Pool) *Jobs {
+	return &Jobs{
+		pool: pool,
+	}
+}
+
+// Enqueue implements job.Store interface. Job is stored within the transaction found in context, if any.
+func (s *Jobs) Enqueue(ctx context.Context, j *job.Job) error {
+	j.TenantID = tenant.FromContext(ctx)
+
+	err := conn(ctx, s.pool).QueryRow(ctx, `INSERT INTO "job" ("tenant_id", "kind", "payload", "priority", "run_at",
+		"max_attempts") VALUES ($1, $2, $3, $4, $5, $6) RETURNING "id"`,
+		j.TenantID, j.Kind, []byte(j.Payload), j.Priority, j.RunAt, j.MaxAttempts).Scan(&j.ID)
+	if err != nil {
+		return fmt.Errorf("job enqueue: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// Claim implements job.Store interface.
+func (s *Jobs) Claim(ctx context.Context, limit int, lease time.Duration) ([]*job.Job, error) {
+	rows, err := s.pool.Query(ctx, `UPDATE "job" SET "locked_until"=now()+$2::interval
+		WHERE "id" IN (
+			SELECT "id" FROM "job"
+			WHERE "dead_at" IS NULL AND "run_at"<=now() AND ("locked_until" IS NULL OR "locked_until"<now())
+			ORDER BY "priority" DESC, "run_at" LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING "id", "tenant_id", "kind", "payload", "priority", "run_at", "attempts", "max_attempts"`,
+		limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("job claim: %w", err)
+	}
+
+	jobs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*job.Job, error) {
+		j := &job.Job{} //nolint:exhaustivestruct
+
+		return j, row.Scan(&j.ID, &j.TenantID, &j.Kind, &j.Payload, &j.Priority, &j.RunAt, //nolint:wrapcheck
+			&j.Attempts, &j.MaxAttempts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("job claim: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// Done implements job.Store interface.
+func (s *Jobs) Done(ctx context.Context, id int64) error {
+	if _, err := s.pool.Exec(ctx, `DELETE FROM "job" WHERE "id"=$1`, id); err != nil {
+		return fmt.Errorf("job done: %w", err)
+	}
+
+	return nil
+}
+
+// Retry implements job.Store interface.
+func (s *Jobs) Retry(ctx context.Context, id int64, next time.Time, cause string) error {
+	_, err := s.pool.Exec(ctx, `UPDATE "job" SET "attempts"="attempts"+1, "run_at"=$2, "last_error"=$3,
+		"locked_until"=NULL WHERE "id"=$1`, id, next, cause)
+	if err != nil {
+		return fmt.Errorf("job retry: %w", err)
+	}
+
+	return nil
+}
+
+// Stats implements job.Store interface.
+func (s *Jobs) Stats(ctx context.Context) ([]*job.Stats, error) {
+	rows, err := s.pool.Query(ctx, `SELECT "kind", count(*) FILTER (WHERE "dead_at" IS NULL),
+		count(*) FILTER (WHERE "dead_at" IS NOT NULL) FROM "job" GROUP BY "kind"`)
+	if err != nil {
+		return nil, fmt.Errorf("job stats: %w", err)
+	}
+
+	stats, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*job.Stats, error) {
+		s := &job.Stats{} //nolint:exhaustivestruct
+
+		return s, row.Scan(&s.Kind, &s.Pending, &s.Dead) //nolint:wrapcheck
+	})
+	if err != nil {
+		return nil, fmt.Errorf("job stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Bury implements job.Store interface.
+func (s *Jobs) Bury(ctx context.Context, id int64, cause string) error {
+	_, err := s.pool.Exec(ctx, `UPDATE "job" SET "attempts"="attempts"+1, "dead_at"=now(), "last_error"=$2,
+		"locked_until"=NULL WHERE "id"=$1`, id, cause)
+	if err != nil {
+		return fmt.Errorf("job bury: %w", err)
+	}
+
+	return nil
+}
+
+// Purge deletes jobs which died before t.
+func (s *Jobs) Purge(ctx context.Context, before time.Time) error {
+	if _, err := s.pool.Exec(ctx, `DELETE FROM "job" WHERE "dead_at"<$1`, before); err != nil {
+		return fmt.Errorf("job purge: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package pgstore_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/job"
+	"github.com/acim/arc/pkg/store/pgstore"
+)
+
+func TestJobsPurge(t *testing.T) {
+	t.Parallel()
+
+	s := pgstore.NewJobs(newPool(t))
+	q := job.NewQueue(s)
+	ctx := context.Background()
+
+	for _, kind := range []string{"dead", "pending"} {
+		if _, err := q.Enqueue(ctx, kind, struct{}{}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	jobs, err := s.Claim(ctx, 2, time.Minute)
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("claim = %v, %v, want 2 jobs", jobs, err)
+	}
+
+	for _, j := range jobs {
+		if j.Kind == "dead" {
+			if err = s.Bury(ctx, j.ID, "failed"); err != nil {
+				t.Fatalf("bury: %v", err)
+			}
+		}
+	}
+
+	if err = s.Purge(ctx, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+
+	if stats := jobStats(ctx, t, s); stats["dead"] != 1 {
+		t.Errorf("purged job which died recently, stats %v", stats)
+	}
+
+	if err = s.Purge(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+
+	if stats := jobStats(ctx, t, s); stats["dead"] != 0 || stats["pending"] != 1 {
+		t.Errorf("stats %v, want only pending job", stats)
+	}
+}
+
+// jobStats returns number of jobs by kind.
+func jobStats(ctx context.Context, t *testing.T, s *pgstore.Jobs) map[string]int {
+	t.Helper()
+
+	stats, err := s.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+
+	count := make(map[string]int, len(stats))
+
+	for _, st := range stats {
+		count[st.Kind] = st.Pending + st.Dead
+	}
+
+	return count
+}
@@ -0,0 +1,65 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/acim/arc/pkg/schedule"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ schedule.Locker = (*Locker)(nil)
+
+// lockerNamespace is the first key of two-key advisory locks taken by Locker.
+const lockerNamespace = 7_126_347
+
+// Locker implements schedule.Locker interface using session level advisory locks. Lock is held by a
+// dedicated connection, so it is released by the server if the process dies.
+type Locker struct {
+	pool *pgxpool.Pool
+}
+
+// NewLocker creates new locker.
+func NewLocker(pool *pgxpool.Pool) *Locker {
+	return &Locker{
+		pool: pool,
+	}
+}
+
+// TryLock implements schedule.Locker interface.
+func (l *Locker) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	c, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("try lock %s: %w", name, err)
+	}
+
+	var ok bool
+
+	err = c.QueryRow(ctx, "SELECT pg_try_advisory_lock($1, hashtext($2))", lockerNamespace, name).Scan(&ok)
+	if err != nil {
+		c.Release()
+
+		return nil, false, fmt.Errorf("try lock %s: %w", name, err)
+	}
+
+	if !ok {
+		c.Release()
+
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //nolint:gomnd
+		defer cancel()
+
+		if _, err := c.Exec(ctx, "SELECT pg_advisory_unlock($1, hashtext($2))", lockerNamespace, name); err != nil {
+			// Closing the connection is the only remaining way to release the lock.
+			_ = c.Conn().Close(ctx)
+		}
+
+		c.Release()
+	}
+
+	return unlock, true, nil
+}
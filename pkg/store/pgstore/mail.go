@@ -0,0 +1,135 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acim/arc/pkg/mail"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	_ mail.EventStore   = (*MailEvents)(nil)
+	_ mail.Suppressions = (*Suppressions)(nil)
+)
+
+// MailEvents implements mail.EventStore interface. Events are not bound to tenants since they are
+// reported by mail provider.
+type MailEvents struct {
+	pool *pgxpool.Pool
+}
+
+// NewMailEvents creates new mail events store.
+func NewMailEvents(pool *pgxpool.Pool) *MailEvents {
+	return &MailEvents{
+		pool: pool,
+	}
+}
+
+// AddEvent implements mail.EventStore interface.
+func (s *MailEvents) AddEvent(ctx context.Context, e *mail.Event) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, `INSERT INTO "mail_event" ("id", "message_id", "event", "recipient",
+		"severity", "reason", "code", "description", "occurred_at") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT ("id") DO NOTHING`,
+		e.ID, e.MessageID, e.Event, e.Recipient, e.Severity, e.Reason, e.Code, e.Description, e.Timestamp)
+	if err != nil {
+		return fmt.Errorf("mail add event: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// Events implements mail.EventStore interface.
+func (s *MailEvents) Events(ctx context.Context, messageID string) ([]*mail.Event, error) {
+	rows, err := conn(ctx, s.pool).Query(ctx, `SELECT "id", "message_id", "event", "recipient", "severity",
+		"reason", "code", "description", "occurred_at" FROM "mail_event" WHERE "message_id"=$1
+		ORDER BY "occurred_at"`, strings.Trim(messageID, "<>"))
+	if err != nil {
+		return nil, fmt.Errorf("mail events: %w", err)
+	}
+
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*mail.Event, error) {
+		e := &mail.Event{} //nolint:exhaustivestruct
+
+		return e, row.Scan(&e.ID, &e.MessageID, &e.Event, &e.Recipient, &e.Severity, //nolint:wrapcheck
+			&e.Reason, &e.Code, &e.Description, &e.Timestamp)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mail events: %w", err)
+	}
+
+	return events, nil
+}
+
+// Purge deletes events which occurred before t.
+func (s *MailEvents) Purge(ctx context.Context, before time.Time) error {
+	if _, err := s.pool.Exec(ctx, `DELETE FROM "mail_event" WHERE "occurred_at"<$1`, before); err != nil {
+		return fmt.Errorf("mail events purge: %w", err)
+	}
+
+	return nil
+}
+
+// Suppressions implements mail.Suppressions interface. Suppressions are global, an address bouncing or
+// complaining is suppressed for all tenants.
+type Suppressions struct {
+	pool *pgxpool.Pool
+}
+
+// NewSuppressions creates new suppression list store.
+func NewSuppressions(pool *pgxpool.Pool) *Suppressions {
+	return &Suppressions{
+		pool: pool,
+	}
+}
+
+// Suppress implements mail.Suppressions interface.
+func (s *Suppressions) Suppress(ctx context.Context, address, reason string) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, `INSERT INTO "mail_suppression" ("address", "reason") VALUES ($1, $2)
+		ON CONFLICT (lower("address")) DO UPDATE SET "reason"=EXCLUDED."reason", "updated_at"=now()`,
+		strings.TrimSpace(address), reason)
+	if err != nil {
+		return fmt.Errorf("mail suppress: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// Unsuppress implements mail.Suppressions interface.
+func (s *Suppressions) Unsuppress(ctx context.Context, address string) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, `DELETE FROM "mail_suppression" WHERE lower("address")=lower($1)`,
+		strings.TrimSpace(address))
+	if err != nil {
+		return fmt.Errorf("mail unsuppress: %w", err)
+	}
+
+	return nil
+}
+
+// Suppressed implements mail.Suppressions interface.
+func (s *Suppressions) Suppressed(ctx context.Context, addresses []string) ([]string, error) {
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+
+	lowered := make([]string, len(addresses))
+	for i, a := range addresses {
+		lowered[i] = strings.ToLower(strings.TrimSpace(a))
+	}
+
+	rows, err := conn(ctx, s.pool).Query(ctx,
+		`SELECT "address" FROM "mail_suppression" WHERE lower("address")=ANY($1)`, lowered)
+	if err != nil {
+		return nil, fmt.Errorf("mail suppressed: %w", err)
+	}
+
+	suppressed, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("mail suppressed: %w", err)
+	}
+
+	return suppressed, nil
+}
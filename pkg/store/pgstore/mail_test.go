@@ -0,0 +1,118 @@
+package pgstore_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/mail"
+	"github.com/acim/arc/pkg/store/pgstore"
+)
+
+func TestMailEventsIdempotent(t *testing.T) {
+	t.Parallel()
+
+	s := pgstore.NewMailEvents(newPool(t))
+	ctx := context.Background()
+	occurred := time.Now().UTC().Truncate(time.Microsecond)
+
+	e := &mail.Event{ //nolint:exhaustivestruct
+		ID:        "event-1",
+		MessageID: "msg@example.com",
+		Event:     "delivered",
+		Recipient: "rcpt@example.com",
+		Timestamp: occurred,
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.AddEvent(ctx, e); err != nil {
+			t.Fatalf("add event: %v", err)
+		}
+	}
+
+	later := *e
+	later.ID = "event-2"
+	later.Event = "opened"
+	later.Timestamp = occurred.Add(time.Second)
+
+	if err := s.AddEvent(ctx, &later); err != nil {
+		t.Fatalf("add event: %v", err)
+	}
+
+	events, err := s.Events(ctx, "<msg@example.com>")
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+
+	if len(events) != 2 || events[0].ID != "event-1" || events[1].ID != "event-2" {
+		t.Fatalf("events = %v, want event-1 and event-2", events)
+	}
+
+	if !events[0].Timestamp.Equal(occurred) {
+		t.Errorf("timestamp = %v, want %v", events[0].Timestamp, occurred)
+	}
+}
+
+func TestMailEventsPurge(t *testing.T) {
+	t.Parallel()
+
+	s := pgstore.NewMailEvents(newPool(t))
+	ctx := context.Background()
+	now := time.Now()
+
+	for id, occurred := range map[string]time.Time{"old": now.Add(-48 * time.Hour), "recent": now} {
+		e := &mail.Event{ //nolint:exhaustivestruct
+			ID: id, MessageID: "msg@example.com", Event: "delivered", Timestamp: occurred,
+		}
+
+		if err := s.AddEvent(ctx, e); err != nil {
+			t.Fatalf("add event: %v", err)
+		}
+	}
+
+	if err := s.Purge(ctx, now.Add(-24*time.Hour)); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+
+	events, err := s.Events(ctx, "msg@example.com")
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+
+	if len(events) != 1 || events[0].ID != "recent" {
+		t.Errorf("events = %v, want recent", events)
+	}
+}
+
+func TestSuppressions(t *testing.T) {
+	t.Parallel()
+
+	s := pgstore.NewSuppressions(newPool(t))
+	ctx := context.Background()
+
+	if err := s.Suppress(ctx, "Rcpt@Example.com", mail.SuppressionBounce); err != nil {
+		t.Fatalf("suppress: %v", err)
+	}
+
+	// Suppressing again updates the reason.
+	if err := s.Suppress(ctx, "rcpt@example.com ", mail.SuppressionComplaint); err != nil {
+		t.Fatalf("suppress: %v", err)
+	}
+
+	suppressed, err := s.Suppressed(ctx, []string{"RCPT@example.com", "other@example.com"})
+	if err != nil {
+		t.Fatalf("suppressed: %v", err)
+	}
+
+	if len(suppressed) != 1 || suppressed[0] != "Rcpt@Example.com" {
+		t.Fatalf("suppressed = %v, want [Rcpt@Example.com]", suppressed)
+	}
+
+	if err = s.Unsuppress(ctx, "rcpt@EXAMPLE.com"); err != nil {
+		t.Fatalf("unsuppress: %v", err)
+	}
+
+	if suppressed, err = s.Suppressed(ctx, []string{"rcpt@example.com"}); err != nil || len(suppressed) != 0 {
+		t.Errorf("suppressed = %v, %v, want none", suppressed, err)
+	}
+}
@@ -0,0 +1,195 @@
+package pgstore
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationsLockID is the advisory lock key preventing concurrent migrations.
+const migrationsLockID = 7_126_347_100
+
+// Migrator applies embedded schema migrations.
+type Migrator struct {
+	pool       *pgxpool.Pool
+	usersTable string
+	rls        bool
+}
+
+// NewMigrator creates new migrator.
+func NewMigrator(pool *pgxpool.Pool, opts ...MigratorOption) *Migrator {
+	m := &Migrator{
+		pool:       pool,
+		usersTable: "user",
+		rls:        false,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Migrate applies all migrations not yet applied. Each migration runs in its own transaction.
+// Afterwards row level security of the users table is enabled or disabled according to the options.
+func (m *Migrator) Migrate(ctx context.Context) error {
+	_, err := m.pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS "schema_migrations" (
+		"version" integer PRIMARY KEY,
+		"applied_at" timestamp with time zone NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("migrate: create schema_migrations: %w", err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := m.apply(ctx, name); err != nil {
+			return fmt.Errorf("migrate %s: %w", path.Base(name), err)
+		}
+	}
+
+	if err := m.rowLevelSecurity(ctx); err != nil {
+		return fmt.Errorf("migrate: row level security: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, name string) error {
+	version, err := strconv.Atoi(strings.SplitN(path.Base(name), "_", 2)[0]) //nolint:gomnd
+	if err != nil {
+		return fmt.Errorf("parse version: %w", err)
+	}
+
+	sql, err := m.render(name)
+	if err != nil {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, m.pool, func(tx pgx.Tx) error { //nolint:wrapcheck
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", migrationsLockID); err != nil {
+			return fmt.Errorf("lock: %w", err)
+		}
+
+		var applied bool
+
+		err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM "schema_migrations" WHERE "version"=$1)`, version).
+			Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("check version: %w", err)
+		}
+
+		if applied {
+			return nil
+		}
+
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, `INSERT INTO "schema_migrations" ("version") VALUES ($1)`, version); err != nil {
+			return fmt.Errorf("record version: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// rowLevelSecurity enables or disables the tenant isolation policy of the users table. It runs on each
+// Migrate so that the option may be switched on an existing database.
+func (m *Migrator) rowLevelSecurity(ctx context.Context) error {
+	table := pgx.Identifier{m.usersTable}.Sanitize()
+	policy := pgx.Identifier{m.usersTable + "_tenant_isolation"}.Sanitize()
+
+	return pgx.BeginFunc(ctx, m.pool, func(tx pgx.Tx) error { //nolint:wrapcheck
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", migrationsLockID); err != nil {
+			return fmt.Errorf("lock: %w", err)
+		}
+
+		stmts := []string{
+			"DROP POLICY IF EXISTS " + policy + " ON " + table,
+			"ALTER TABLE " + table + " DISABLE ROW LEVEL SECURITY",
+		}
+
+		if m.rls {
+			stmts = []string{
+				"DROP POLICY IF EXISTS " + policy + " ON " + table,
+				"CREATE POLICY " + policy + " ON " + table +
+					` USING ("tenant_id" = current_setting('app.tenant_id', true))` +
+					` WITH CHECK ("tenant_id" = current_setting('app.tenant_id', true))`,
+				"ALTER TABLE " + table + " ENABLE ROW LEVEL SECURITY",
+			}
+		}
+
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return err //nolint:wrapcheck
+			}
+		}
+
+		return nil
+	})
+}
+
+func (m *Migrator) render(name string) (string, error) {
+	tpl, err := template.New(path.Base(name)).
+		Funcs(template.FuncMap{
+			"ident": func(parts ...string) string {
+				return pgx.Identifier{strings.Join(parts, "_")}.Sanitize()
+			},
+		}).
+		ParseFS(migrations, name)
+	if err != nil {
+		return "", fmt.Errorf("parse: %w", err)
+	}
+
+	var sb strings.Builder
+
+	err = tpl.Execute(&sb, map[string]string{
+		"Users":      pgx.Identifier{m.usersTable}.Sanitize(),
+		"UsersTable": m.usersTable,
+	})
+	if err != nil {
+		return "", fmt.Errorf("render: %w", err)
+	}
+
+	return sb.String(), nil
+}
+
+// MigratorOption ...
+type MigratorOption func(*Migrator)
+
+// MigratorUsersTable sets the name of the users table, it should match the name passed to UsersTableName.
+func MigratorUsersTable(name string) MigratorOption {
+	return func(m *Migrator) {
+		m.usersTable = name
+	}
+}
+
+// MigratorRowLevelSecurity enables row level security policy isolating tenants of the users table. It should
+// be used together with UsersRowLevelSecurity, since the policy hides all rows from queries not setting
+// app.tenant_id. Without the option the policy is removed.
+func MigratorRowLevelSecurity() MigratorOption {
+	return func(m *Migrator) {
+		m.rls = true
+	}
+}
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS {{.Users}} (
+  "id" uuid PRIMARY KEY,
+  "email" character varying(254) UNIQUE NOT NULL,
+  "password" character(60) NOT NULL
+);
@@ -0,0 +1,9 @@
+ALTER TABLE {{.Users}}
+  ADD COLUMN "created_at" timestamp with time zone NOT NULL DEFAULT now(),
+  ADD COLUMN "updated_at" timestamp with time zone NOT NULL DEFAULT now(),
+  ADD COLUMN "deleted_at" timestamp with time zone,
+  ADD COLUMN "version" integer NOT NULL DEFAULT 1;
+
+-- Soft deleted users should not block reuse of their e-mail addresses.
+ALTER TABLE {{.Users}} DROP CONSTRAINT IF EXISTS {{ident .UsersTable "email_key"}};
+CREATE UNIQUE INDEX {{ident .UsersTable "email_active_key"}} ON {{.Users}} ("email") WHERE "deleted_at" IS NULL;
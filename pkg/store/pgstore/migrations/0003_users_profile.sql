@@ -0,0 +1,5 @@
+ALTER TABLE {{.Users}}
+  ADD COLUMN "display_name" character varying(100) NOT NULL DEFAULT '',
+  ADD COLUMN "locale" character varying(35) NOT NULL DEFAULT '',
+  ADD COLUMN "timezone" character varying(64) NOT NULL DEFAULT '',
+  ADD COLUMN "avatar_url" character varying(2048) NOT NULL DEFAULT '';
@@ -0,0 +1,41 @@
+-- E-mail addresses are unique regardless of case. Migration is aborted if existing active users collide,
+-- collisions have to be resolved manually before running it again.
+DO $$
+DECLARE
+  collisions text;
+BEGIN
+  SELECT string_agg(addresses, '; ') INTO collisions FROM (
+    SELECT string_agg("email", ', ' ORDER BY "created_at") AS addresses
+    FROM {{.Users}}
+    WHERE "deleted_at" IS NULL
+    GROUP BY lower(btrim("email"))
+    HAVING count(*) > 1
+  ) AS c;
+
+  IF collisions IS NOT NULL THEN
+    RAISE EXCEPTION 'e-mail addresses differing only in case: %', collisions;
+  END IF;
+END
+$$;
+
+-- IDNA conversion can not be done in SQL, internationalized domains are reported to be converted manually.
+DO $$
+DECLARE
+  non_ascii text;
+BEGIN
+  SELECT string_agg("email", ', ') INTO non_ascii FROM {{.Users}} WHERE "email" ~ '@.*[^\x01-\x7F]';
+
+  IF non_ascii IS NOT NULL THEN
+    RAISE WARNING 'e-mail addresses with non-ASCII domains: %', non_ascii;
+  END IF;
+END
+$$;
+
+-- Trim and lower case the domain part.
+UPDATE {{.Users}}
+SET "email" = substring(btrim("email") FROM '^(.*)@') || '@' || lower(substring(btrim("email") FROM '@([^@]*)$'))
+WHERE "email" <> substring(btrim("email") FROM '^(.*)@') || '@' || lower(substring(btrim("email") FROM '@([^@]*)$'));
+
+DROP INDEX IF EXISTS {{ident .UsersTable "email_active_key"}};
+CREATE UNIQUE INDEX {{ident .UsersTable "email_lower_active_key"}} ON {{.Users}} (lower("email"))
+  WHERE "deleted_at" IS NULL;
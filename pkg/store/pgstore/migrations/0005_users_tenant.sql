@@ -0,0 +1,7 @@
+ALTER TABLE {{.Users}} ADD COLUMN "tenant_id" character varying(63) NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS {{ident .UsersTable "email_lower_active_key"}};
+CREATE UNIQUE INDEX {{ident .UsersTable "tenant_email_lower_active_key"}} ON {{.Users}} ("tenant_id", lower("email"))
+  WHERE "deleted_at" IS NULL;
+
+-- Row level security policy is managed by Migrator according to MigratorRowLevelSecurity option.
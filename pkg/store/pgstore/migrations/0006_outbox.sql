@@ -0,0 +1,15 @@
+CREATE TABLE "outbox" (
+  "id" bigserial PRIMARY KEY,
+  "tenant_id" character varying(63) NOT NULL DEFAULT '',
+  "topic" character varying(255) NOT NULL,
+  "payload" jsonb NOT NULL,
+  "attempts" integer NOT NULL DEFAULT 0,
+  "last_error" text NOT NULL DEFAULT '',
+  "created_at" timestamp with time zone NOT NULL DEFAULT now(),
+  "next_attempt_at" timestamp with time zone NOT NULL DEFAULT now(),
+  "locked_until" timestamp with time zone,
+  "done_at" timestamp with time zone,
+  "failed_at" timestamp with time zone
+);
+
+CREATE INDEX "outbox_pending_idx" ON "outbox" ("next_attempt_at") WHERE "done_at" IS NULL AND "failed_at" IS NULL;
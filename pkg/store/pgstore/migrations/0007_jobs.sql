@@ -0,0 +1,16 @@
+CREATE TABLE "job" (
+  "id" bigserial PRIMARY KEY,
+  "tenant_id" character varying(63) NOT NULL DEFAULT '',
+  "kind" character varying(255) NOT NULL,
+  "payload" jsonb NOT NULL,
+  "priority" integer NOT NULL DEFAULT 0,
+  "run_at" timestamp with time zone NOT NULL DEFAULT now(),
+  "attempts" integer NOT NULL DEFAULT 0,
+  "max_attempts" integer NOT NULL DEFAULT 10,
+  "last_error" text NOT NULL DEFAULT '',
+  "created_at" timestamp with time zone NOT NULL DEFAULT now(),
+  "locked_until" timestamp with time zone,
+  "dead_at" timestamp with time zone
+);
+
+CREATE INDEX "job_due_idx" ON "job" ("priority" DESC, "run_at") WHERE "dead_at" IS NULL;
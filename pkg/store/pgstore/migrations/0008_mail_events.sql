@@ -0,0 +1,23 @@
+CREATE TABLE "mail_event" (
+  "id" character varying(255) PRIMARY KEY,
+  "message_id" character varying(998) NOT NULL,
+  "event" character varying(50) NOT NULL,
+  "recipient" character varying(254) NOT NULL DEFAULT '',
+  "severity" character varying(50) NOT NULL DEFAULT '',
+  "reason" character varying(255) NOT NULL DEFAULT '',
+  "code" integer NOT NULL DEFAULT 0,
+  "description" text NOT NULL DEFAULT '',
+  "occurred_at" timestamp with time zone NOT NULL,
+  "created_at" timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX "mail_event_message_id_idx" ON "mail_event" ("message_id", "occurred_at");
+
+CREATE TABLE "mail_suppression" (
+  "address" character varying(254) NOT NULL,
+  "reason" character varying(50) NOT NULL,
+  "created_at" timestamp with time zone NOT NULL DEFAULT now(),
+  "updated_at" timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX "mail_suppression_address_key" ON "mail_suppression" (lower("address"));
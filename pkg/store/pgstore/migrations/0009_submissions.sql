@@ -0,0 +1,23 @@
+CREATE TABLE "submission" (
+  "id" uuid PRIMARY KEY,
+  "tenant_id" character varying(63) NOT NULL DEFAULT '',
+  "first_name" character varying(255) NOT NULL DEFAULT '',
+  "last_name" character varying(255) NOT NULL DEFAULT '',
+  "company" character varying(255) NOT NULL DEFAULT '',
+  "email" character varying(254) NOT NULL,
+  "subject" character varying(998) NOT NULL,
+  "text" text NOT NULL,
+  "language" character varying(35) NOT NULL DEFAULT '',
+  "ip" character varying(45) NOT NULL DEFAULT '',
+  "user_agent" text NOT NULL DEFAULT '',
+  "status" character varying(20) NOT NULL,
+  "message_id" character varying(998) NOT NULL DEFAULT '',
+  "provider" character varying(50) NOT NULL DEFAULT '',
+  "error" text NOT NULL DEFAULT '',
+  "created_at" timestamp with time zone NOT NULL DEFAULT now(),
+  "updated_at" timestamp with time zone NOT NULL DEFAULT now(),
+  "handled_at" timestamp with time zone,
+  "handled_by" character varying(36) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX "submission_tenant_id_idx" ON "submission" ("tenant_id", "id" DESC);
@@ -0,0 +1,123 @@
+package pgstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/acim/arc/pkg/outbox"
+	"github.com/acim/arc/pkg/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	_ outbox.Writer = (*Outbox)(nil)
+	_ outbox.Store  = (*Outbox)(nil)
+)
+
+// ErrNoTransaction is returned when operation requires a transaction, but there is none in context.
+var ErrNoTransaction = errors.New("no transaction in context")
+
+// Outbox implements outbox.Writer and outbox.Store interfaces.
+type Outbox struct {
+	pool *pgxpool.Pool
+}
+
+// NewOutbox creates new outbox store.
+func NewOutbox(pool *pgxpool.Pool) *Outbox {
+	return &Outbox{
+		pool: pool,
+	}
+}
+
+// Add implements outbox.Writer interface. Payload is encoded as JSON. Context must carry a transaction
+// started by Transactor.
+func (s *Outbox) Add(ctx context.Context, topic string, payload interface{}) error {
+	tx := txFromContext(ctx)
+	if tx == nil {
+		return fmt.Errorf("outbox add: %w", ErrNoTransaction)
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("outbox add: encode payload: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO "outbox" ("tenant_id", "topic", "payload") VALUES ($1, $2, $3)`,
+		tenant.FromContext(ctx), topic, b)
+	if err != nil {
+		return fmt.Errorf("outbox add: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// Claim implements outbox.Store interface.
+func (s *Outbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]*outbox.Message, error) {
+	rows, err := s.pool.Query(ctx, `UPDATE "outbox" SET "locked_until"=now()+$2::interval
+		WHERE "id" IN (
+			SELECT "id" FROM "outbox"
+			WHERE "done_at" IS NULL AND "failed_at" IS NULL AND "next_attempt_at"<=now()
+				AND ("locked_until" IS NULL OR "locked_until"<now())
+			ORDER BY "id" LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING "id", "tenant_id", "topic", "payload", "attempts"`, limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("outbox claim: %w", err)
+	}
+
+	msgs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*outbox.Message, error) {
+		m := &outbox.Message{} //nolint:exhaustivestruct
+
+		return m, row.Scan(&m.ID, &m.TenantID, &m.Topic, &m.Payload, &m.Attempts) //nolint:wrapcheck
+	})
+	if err != nil {
+		return nil, fmt.Errorf("outbox claim: %w", err)
+	}
+
+	return msgs, nil
+}
+
+// Done implements outbox.Store interface.
+func (s *Outbox) Done(ctx context.Context, id int64) error {
+	_, err := s.pool.Exec(ctx, `UPDATE "outbox" SET "done_at"=now(), "locked_until"=NULL WHERE "id"=$1`, id)
+	if err != nil {
+		return fmt.Errorf("outbox done: %w", err)
+	}
+
+	return nil
+}
+
+// Retry implements outbox.Store interface.
+func (s *Outbox) Retry(ctx context.Context, id int64, next time.Time, cause string) error {
+	_, err := s.pool.Exec(ctx, `UPDATE "outbox" SET "attempts"="attempts"+1, "next_attempt_at"=$2, "last_error"=$3,
+		"locked_until"=NULL WHERE "id"=$1`, id, next, cause)
+	if err != nil {
+		return fmt.Errorf("outbox retry: %w", err)
+	}
+
+	return nil
+}
+
+// Fail implements outbox.Store interface.
+func (s *Outbox) Fail(ctx context.Context, id int64, cause string) error {
+	_, err := s.pool.Exec(ctx, `UPDATE "outbox" SET "attempts"="attempts"+1, "failed_at"=now(), "last_error"=$2,
+		"locked_until"=NULL WHERE "id"=$1`, id, cause)
+	if err != nil {
+		return fmt.Errorf("outbox fail: %w", err)
+	}
+
+	return nil
+}
+
+// Purge deletes messages delivered before t.
+func (s *Outbox) Purge(ctx context.Context, before time.Time) error {
+	if _, err := s.pool.Exec(ctx, `DELETE FROM "outbox" WHERE "done_at"<$1`, before); err != nil {
+		return fmt.Errorf("outbox purge: %w", err)
+	}
+
+	return nil
+}
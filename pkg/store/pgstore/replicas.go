@@ -0,0 +1,162 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/acim/arc/pkg/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type replica struct {
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// Replicas balances reads between healthy read replicas.
+type Replicas struct {
+	replicas      []*replica
+	next          atomic.Uint32
+	checkInterval time.Duration
+	checkTimeout  time.Duration
+}
+
+// NewReplicas creates new replicas set. All replicas are considered healthy until checked, so Check should
+// be called before the set is used if some of them may be unreachable.
+func NewReplicas(pools []*pgxpool.Pool, opts ...ReplicasOption) *Replicas {
+	r := &Replicas{ //nolint:exhaustivestruct
+		replicas:      make([]*replica, len(pools)),
+		checkInterval: 10 * time.Second, //nolint:gomnd
+		checkTimeout:  2 * time.Second,  //nolint:gomnd
+	}
+
+	for i, pool := range pools {
+		r.replicas[i] = &replica{pool: pool} //nolint:exhaustivestruct
+		r.replicas[i].healthy.Store(true)
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Run implements rest.Worker interface. It checks health of replicas periodically until context is canceled.
+func (r *Replicas) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Check(ctx)
+		}
+	}
+}
+
+// Check checks health of all replicas concurrently.
+func (r *Replicas) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, rep := range r.replicas {
+		wg.Add(1)
+
+		go func(rep *replica) {
+			defer wg.Done()
+
+			r.check(ctx, rep)
+		}(rep)
+	}
+
+	wg.Wait()
+}
+
+// Close closes all replica pools. It should be called after Run returns.
+func (r *Replicas) Close() {
+	for _, rep := range r.replicas {
+		rep.pool.Close()
+	}
+}
+
+func (r *Replicas) check(ctx context.Context, rep *replica) {
+	ctx, cancel := context.WithTimeout(ctx, r.checkTimeout)
+	defer cancel()
+
+	rep.healthy.Store(rep.pool.Ping(ctx) == nil)
+}
+
+// pick returns next healthy replica in round robin fashion or nil if there is none.
+func (r *Replicas) pick() *replica {
+	n := uint32(len(r.replicas))
+
+	for i := uint32(0); i < n; i++ {
+		rep := r.replicas[r.next.Add(1)%n]
+		if rep.healthy.Load() {
+			return rep
+		}
+	}
+
+	return nil
+}
+
+// ReplicasOption ...
+type ReplicasOption func(*Replicas)
+
+// ReplicasCheckInterval sets how often health of replicas is checked.
+func ReplicasCheckInterval(d time.Duration) ReplicasOption {
+	return func(r *Replicas) {
+		r.checkInterval = d
+	}
+}
+
+// read runs fn against a healthy replica, falling back to primary if there is none or the replica fails
+// due to connection error. Reads within transaction or in a session which already wrote use the primary.
+func read(ctx context.Context, primary *pgxpool.Pool, replicas *Replicas, fn func(q querier) error) error {
+	if tx := txFromContext(ctx); tx != nil {
+		return fn(tx)
+	}
+
+	if replicas == nil || store.Written(ctx) {
+		return fn(primary)
+	}
+
+	rep := replicas.pick()
+	if rep == nil {
+		return fn(primary)
+	}
+
+	err := fn(rep.pool)
+	if isConnError(ctx, err) {
+		rep.healthy.Store(false)
+
+		return fn(primary)
+	}
+
+	return err
+}
+
+// write returns querier for writes and marks the store session as written.
+func write(ctx context.Context, primary *pgxpool.Pool) querier { //nolint:ireturn
+	store.MarkWritten(ctx)
+
+	return conn(ctx, primary)
+}
+
+// isConnError returns true for errors not originating from the query itself.
+func isConnError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+
+	return !errors.Is(err, pgx.ErrNoRows) && !errors.Is(err, store.ErrNotFound) && !errors.As(err, &pgErr)
+}
@@ -0,0 +1,249 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// fakeTx stands in for a transaction found in context.
+type fakeTx struct {
+	pgx.Tx
+}
+
+// newUnreachablePool creates pool to a port nothing listens on. Pool doesn't connect until used.
+func newUnreachablePool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := pgxpool.New(context.Background(), "host=127.0.0.1 port=1 user=arc dbname=arc sslmode=disable "+
+		"connect_timeout=1")
+	if err != nil {
+		t.Fatalf("new pool: %v", err)
+	}
+
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+func newTestReplicas(t *testing.T, n int) *Replicas {
+	t.Helper()
+
+	pools := make([]*pgxpool.Pool, n)
+
+	for i := range pools {
+		pools[i] = newUnreachablePool(t)
+	}
+
+	return NewReplicas(pools)
+}
+
+func TestReplicasPick(t *testing.T) {
+	t.Parallel()
+
+	r := newTestReplicas(t, 3)
+	r.replicas[1].healthy.Store(false)
+
+	picked := make(map[*replica]int)
+
+	for i := 0; i < 10; i++ {
+		picked[r.pick()]++
+	}
+
+	if picked[r.replicas[1]] != 0 {
+		t.Error("picked unhealthy replica")
+	}
+
+	if picked[r.replicas[0]] != 5 || picked[r.replicas[2]] != 5 {
+		t.Errorf("picked %d and %d times, want reads balanced", picked[r.replicas[0]], picked[r.replicas[2]])
+	}
+
+	r.replicas[0].healthy.Store(false)
+	r.replicas[2].healthy.Store(false)
+
+	if rep := r.pick(); rep != nil {
+		t.Error("picked replica while all are unhealthy")
+	}
+}
+
+func TestReplicasCheck(t *testing.T) {
+	t.Parallel()
+
+	r := newTestReplicas(t, 2)
+	r.checkTimeout = 5 * time.Second
+
+	r.Check(context.Background())
+
+	for i, rep := range r.replicas {
+		if rep.healthy.Load() {
+			t.Errorf("unreachable replica %d is healthy", i)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	errQuery := &pgconn.PgError{Code: "42P01"} //nolint:exhaustivestruct
+	primary := newUnreachablePool(t)
+
+	tests := map[string]struct {
+		ctx       func() context.Context
+		replicas  bool
+		unhealthy bool
+		// replicaErr is returned by the query run against the replica.
+		replicaErr error
+		want       string
+		wantErr    error
+		wantHealth bool
+	}{
+		"without replicas": {
+			ctx:  context.Background,
+			want: "primary",
+		},
+		"replica": {
+			ctx: context.Background, replicas: true,
+			want: "replica", wantHealth: true,
+		},
+		"transaction": {
+			ctx: func() context.Context {
+				return context.WithValue(context.Background(), txKey{}, pgx.Tx(fakeTx{})) //nolint:exhaustivestruct
+			},
+			replicas: true,
+			want:     "tx", wantHealth: true,
+		},
+		"session which wrote": {
+			ctx: func() context.Context {
+				ctx := store.NewSession(context.Background())
+				store.MarkWritten(ctx)
+
+				return ctx
+			},
+			replicas: true,
+			want:     "primary", wantHealth: true,
+		},
+		"no healthy replica": {
+			ctx: context.Background, replicas: true, unhealthy: true,
+			want: "primary",
+		},
+		"replica connection error": {
+			ctx: context.Background, replicas: true, replicaErr: io.ErrUnexpectedEOF,
+			want: "primary",
+		},
+		"replica query error": {
+			ctx: context.Background, replicas: true, replicaErr: errQuery,
+			want: "replica", wantErr: errQuery, wantHealth: true,
+		},
+		"replica not found": {
+			ctx: context.Background, replicas: true, replicaErr: pgx.ErrNoRows,
+			want: "replica", wantErr: pgx.ErrNoRows, wantHealth: true,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var r *Replicas
+
+			if tc.replicas {
+				r = newTestReplicas(t, 1)
+				r.replicas[0].healthy.Store(!tc.unhealthy)
+			}
+
+			var used []string
+
+			err := read(tc.ctx(), primary, r, func(q querier) error {
+				switch {
+				case q == querier(primary):
+					used = append(used, "primary")
+				case r != nil && q == querier(r.replicas[0].pool):
+					used = append(used, "replica")
+
+					return tc.replicaErr
+				default:
+					used = append(used, "tx")
+				}
+
+				return nil
+			})
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("error = %v, want %v", err, tc.wantErr)
+			}
+
+			if len(used) == 0 || used[len(used)-1] != tc.want {
+				t.Errorf("used %v, want %s last", used, tc.want)
+			}
+
+			if r != nil && r.replicas[0].healthy.Load() != tc.wantHealth {
+				t.Errorf("healthy = %t, want %t", r.replicas[0].healthy.Load(), tc.wantHealth)
+			}
+		})
+	}
+}
+
+func TestWriteMarksSession(t *testing.T) {
+	t.Parallel()
+
+	primary := newUnreachablePool(t)
+	ctx := store.NewSession(context.Background())
+
+	if q := write(ctx, primary); q != querier(primary) {
+		t.Error("write outside transaction doesn't use primary")
+	}
+
+	if !store.Written(ctx) {
+		t.Error("session not marked as written")
+	}
+
+	tx := pgx.Tx(fakeTx{}) //nolint:exhaustivestruct
+
+	if q := write(context.WithValue(ctx, txKey{}, tx), primary); q != querier(tx) {
+		t.Error("write within transaction doesn't use it")
+	}
+}
+
+func TestIsConnError(t *testing.T) {
+	t.Parallel()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	pgErr := &pgconn.PgError{Code: "23505"} //nolint:exhaustivestruct
+
+	tests := map[string]struct {
+		ctx  context.Context //nolint:containedctx
+		err  error
+		want bool
+	}{
+		"nil":             {ctx: context.Background(), err: nil, want: false},
+		"no rows":         {ctx: context.Background(), err: fmt.Errorf("find: %w", pgx.ErrNoRows), want: false},
+		"not found":       {ctx: context.Background(), err: store.ErrNotFound, want: false},
+		"postgres error":  {ctx: context.Background(), err: pgErr, want: false},
+		"connection lost": {ctx: context.Background(), err: io.ErrUnexpectedEOF, want: true},
+		"canceled caller": {ctx: canceled, err: context.Canceled, want: false},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := isConnError(tc.ctx, tc.err); got != tc.want {
+				t.Errorf("isConnError(%v) = %t, want %t", tc.err, got, tc.want)
+			}
+		})
+	}
+}
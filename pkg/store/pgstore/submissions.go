@@ -0,0 +1,190 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+	"github.com/acim/arc/pkg/tenant"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ store.Submissions = (*Submissions)(nil)
+
+const submissionColumns = `"id", "tenant_id", "first_name", "last_name", "company", "email", "subject", "text",
+	"language", "ip", "user_agent", "status", "message_id", "provider", "error", "created_at", "updated_at",
+	"handled_at", "handled_by"`
+
+// Submissions implements store.Submissions interface. All queries are scoped to the tenant found in context.
+type Submissions struct {
+	pool *pgxpool.Pool
+}
+
+// NewSubmissions creates new submissions store.
+func NewSubmissions(pool *pgxpool.Pool) *Submissions {
+	return &Submissions{
+		pool: pool,
+	}
+}
+
+// FindByID implements store.Submissions interface.
+func (s *Submissions) FindByID(ctx context.Context, id string) (*model.Submission, error) {
+	sid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("find submission by id: %w", store.ErrNotFound)
+	}
+
+	sub, err := scanSubmission(conn(ctx, s.pool).QueryRow(ctx,
+		`SELECT `+submissionColumns+` FROM "submission" WHERE "tenant_id"=$1 AND "id"=$2`,
+		tenant.FromContext(ctx), toPgUUID(sid)))
+	if err != nil {
+		return nil, fmt.Errorf("find submission by id: %w", err)
+	}
+
+	return sub, nil
+}
+
+// List implements store.Submissions interface using keyset pagination.
+func (s *Submissions) List(ctx context.Context, f *store.SubmissionFilter, after string,
+	limit int,
+) ([]*model.Submission, error) {
+	conds := []string{`"tenant_id"=$1`}
+	args := []interface{}{tenant.FromContext(ctx)}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if after != "" {
+		afterID, err := uuid.Parse(after)
+		if err != nil {
+			return nil, fmt.Errorf("list submissions: parse after: %w", err)
+		}
+
+		conds = append(conds, `"id"<`+arg(toPgUUID(afterID)))
+	}
+
+	if f.Query != "" {
+		conds = append(conds, `strpos(lower(concat_ws(' ', "first_name", "last_name", "company", "email", "subject",
+			"text")), lower(`+arg(f.Query)+`))>0`)
+	}
+
+	if f.Status != "" {
+		conds = append(conds, `"status"=`+arg(f.Status))
+	}
+
+	if f.Handled != nil {
+		if *f.Handled {
+			conds = append(conds, `"handled_at" IS NOT NULL`)
+		} else {
+			conds = append(conds, `"handled_at" IS NULL`)
+		}
+	}
+
+	rows, err := conn(ctx, s.pool).Query(ctx, `SELECT `+submissionColumns+` FROM "submission" WHERE `+
+		strings.Join(conds, " AND ")+` ORDER BY "id" DESC LIMIT `+arg(limit), args...)
+	if err != nil {
+		return nil, fmt.Errorf("list submissions: %w", err)
+	}
+
+	subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*model.Submission, error) {
+		return scanSubmission(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list submissions: %w", err)
+	}
+
+	return subs, nil
+}
+
+// Insert implements store.Submissions interface. Submission's tenant is set from context.
+func (s *Submissions) Insert(ctx context.Context, sub *model.Submission) error {
+	sid, err := uuid.Parse(sub.ID)
+	if err != nil {
+		return fmt.Errorf("insert submission: parse id: %w", err)
+	}
+
+	sub.TenantID = tenant.FromContext(ctx)
+
+	err = conn(ctx, s.pool).QueryRow(ctx, `INSERT INTO "submission" ("id", "tenant_id", "first_name", "last_name",
+		"company", "email", "subject", "text", "language", "ip", "user_agent", "status")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING "created_at", "updated_at"`,
+		toPgUUID(sid), sub.TenantID, sub.FirstName, sub.LastName, sub.Company, sub.Email, sub.Subject, sub.Text,
+		sub.Language, sub.IP, sub.UserAgent, sub.Status).
+		Scan(&sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("insert submission: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// UpdateDelivery implements store.Submissions interface.
+func (s *Submissions) UpdateDelivery(ctx context.Context, sub *model.Submission) error {
+	sid, err := uuid.Parse(sub.ID)
+	if err != nil {
+		return fmt.Errorf("update submission delivery: %w", store.ErrNotFound)
+	}
+
+	err = conn(ctx, s.pool).QueryRow(ctx, `UPDATE "submission" SET "status"=$3, "message_id"=$4, "provider"=$5,
+		"error"=$6, "updated_at"=now() WHERE "tenant_id"=$1 AND "id"=$2 RETURNING "updated_at"`,
+		tenant.FromContext(ctx), toPgUUID(sid), sub.Status, sub.MessageID, sub.Provider, sub.Error).
+		Scan(&sub.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = store.ErrNotFound
+	}
+
+	if err != nil {
+		return fmt.Errorf("update submission delivery: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// SetHandled implements store.Submissions interface.
+func (s *Submissions) SetHandled(ctx context.Context, id, userID string) (*model.Submission, error) {
+	sid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("set submission handled: %w", store.ErrNotFound)
+	}
+
+	sub, err := scanSubmission(conn(ctx, s.pool).QueryRow(ctx, `UPDATE "submission"
+		SET "handled_at"=CASE WHEN $3='' THEN NULL ELSE coalesce("handled_at", now()) END,
+			"handled_by"=CASE WHEN $3='' OR "handled_at" IS NULL THEN $3 ELSE "handled_by" END, "updated_at"=now()
+		WHERE "tenant_id"=$1 AND "id"=$2 RETURNING `+submissionColumns,
+		tenant.FromContext(ctx), toPgUUID(sid), userID))
+	if err != nil {
+		return nil, fmt.Errorf("set submission handled: %w", mapError(err))
+	}
+
+	return sub, nil
+}
+
+func scanSubmission(row pgx.Row) (*model.Submission, error) {
+	var id pgtype.UUID
+
+	s := &model.Submission{} //nolint:exhaustivestruct
+
+	err := row.Scan(&id, &s.TenantID, &s.FirstName, &s.LastName, &s.Company, &s.Email, &s.Subject, &s.Text,
+		&s.Language, &s.IP, &s.UserAgent, &s.Status, &s.MessageID, &s.Provider, &s.Error, &s.CreatedAt, &s.UpdatedAt,
+		&s.HandledAt, &s.HandledBy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	s.ID = uuid.UUID(id.Bytes).String()
+
+	return s, nil
+}
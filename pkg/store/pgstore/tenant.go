@@ -0,0 +1,30 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/acim/arc/pkg/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// withTenant runs fn within the transaction from context or a new one, with app.tenant_id setting
+// local to the transaction set to the tenant from context.
+func withTenant(ctx context.Context, pool *pgxpool.Pool, fn func(ctx context.Context) error) error {
+	set := func(ctx context.Context, tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenant.FromContext(ctx)); err != nil {
+			return fmt.Errorf("set tenant: %w", err)
+		}
+
+		return fn(ctx)
+	}
+
+	if tx := txFromContext(ctx); tx != nil {
+		return set(ctx, tx)
+	}
+
+	return pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error { //nolint:wrapcheck
+		return set(context.WithValue(ctx, txKey{}, tx), tx)
+	})
+}
@@ -0,0 +1,134 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/acim/arc/pkg/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ store.Transactor = (*Transactor)(nil)
+
+// Serialization failures which are safe to retry.
+const (
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+)
+
+type txKey struct{}
+
+// Transactor implements store.Transactor interface.
+type Transactor struct {
+	pool       *pgxpool.Pool
+	txOptions  pgx.TxOptions
+	maxRetries int
+}
+
+// NewTransactor creates new transactor.
+func NewTransactor(pool *pgxpool.Pool, opts ...TransactorOption) *Transactor {
+	t := &Transactor{
+		pool:       pool,
+		txOptions:  pgx.TxOptions{}, //nolint:exhaustivestruct
+		maxRetries: 3,               //nolint:gomnd
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// WithTx runs fn inside a transaction. The transaction is committed if fn returns nil and rolled back
+// if fn returns an error or panics. Nested calls use savepoints. Top level transactions failed due to
+// serialization failure or deadlock are retried.
+func (t *Transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	store.MarkWritten(ctx)
+
+	if parent := txFromContext(ctx); parent != nil {
+		return run(ctx, fn, func() (pgx.Tx, error) {
+			return parent.Begin(ctx) //nolint:wrapcheck
+		})
+	}
+
+	var err error
+
+	for attempt := 0; attempt <= t.maxRetries; attempt++ {
+		err = run(ctx, fn, func() (pgx.Tx, error) {
+			return t.pool.BeginTx(ctx, t.txOptions) //nolint:wrapcheck
+		})
+		if !isRetryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// run begins transaction or savepoint, if begin is called on existing transaction, and runs fn in it.
+func run(ctx context.Context, fn func(ctx context.Context) error, begin func() (pgx.Tx, error)) (err error) {
+	tx, err := begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+
+			panic(p)
+		}
+	}()
+
+	if err = fn(store.NewTxContext(context.WithValue(ctx, txKey{}, tx))); err != nil {
+		if rerr := tx.Rollback(ctx); rerr != nil {
+			return fmt.Errorf("rollback: %v: %w", rerr, err) //nolint:errorlint
+		}
+
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	return nil
+}
+
+// TransactorOption ...
+type TransactorOption func(*Transactor)
+
+// TransactorIsolation sets isolation level of top level transactions.
+func TransactorIsolation(level pgx.TxIsoLevel) TransactorOption {
+	return func(t *Transactor) {
+		t.txOptions.IsoLevel = level
+	}
+}
+
+// TransactorMaxRetries sets how many times a transaction is retried on serialization failure.
+func TransactorMaxRetries(n int) TransactorOption {
+	return func(t *Transactor) {
+		t.maxRetries = n
+	}
+}
+
+func txFromContext(ctx context.Context) pgx.Tx { //nolint:ireturn
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+
+	return nil
+}
+
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	return pgErr.Code == codeSerializationFailure || pgErr.Code == codeDeadlockDetected
+}
@@ -2,32 +2,44 @@ package pgstore
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/acim/arc/pkg/model"
 	"github.com/acim/arc/pkg/store"
-	"github.com/jmoiron/sqlx"
+	"github.com/acim/arc/pkg/tenant"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var _ store.Users = (*Users)(nil)
 
-// Users implements store.Users interface.
+const (
+	userColumns = "id, tenant_id, email, password, admin, display_name, locale, timezone, avatar_url, created_at, " +
+		"updated_at, deleted_at, version"
+	insertUser = `INSERT INTO table (id, tenant_id, email, password, admin, display_name, locale, timezone,
+		avatar_url)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING created_at, updated_at, version`
+)
+
+// Users implements store.Users interface. All queries are scoped to the tenant found in context.
 type Users struct {
-	db              *sqlx.DB
-	tableName       string
-	prepFindByID    *sqlx.NamedStmt
-	prepFindByEmail *sqlx.NamedStmt
-	prepInsert      *sqlx.NamedStmt
+	pool      *pgxpool.Pool
+	replicas  *Replicas
+	tableName string
+	rls       bool
 }
 
 // NewUsers creates new users store.
-func NewUsers(db *sqlx.DB, opts ...UsersOption) *Users {
-	u := &Users{ //nolint:exhaustivestruct
-		db:        db,
+func NewUsers(pool *pgxpool.Pool, opts ...UsersOption) *Users {
+	u := &Users{
+		pool:      pool,
+		replicas:  nil,
 		tableName: "user",
+		rls:       false,
 	}
 
 	for _, opt := range opts {
@@ -39,25 +51,22 @@ func NewUsers(db *sqlx.DB, opts ...UsersOption) *Users {
 
 // FindByID finds user by id (UUID).
 func (s *Users) FindByID(ctx context.Context, id string) (*model.User, error) {
-	var err error
-
-	if s.prepFindByID == nil {
-		sql := "SELECT id, email, password FROM table WHERE id=:id"
-		sql = strings.Replace(sql, "table", s.tableName, 1)
-
-		s.prepFindByID, err = s.db.PrepareNamedContext(ctx, sql)
-		if err != nil {
-			return nil, fmt.Errorf("prepare: %w", err)
-		}
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, store.ErrNotFound
 	}
 
-	u := &model.User{} //nolint:exhaustivestruct
-	err = s.prepFindByID.QueryRowxContext(ctx, map[string]interface{}{"id": id}).StructScan(u)
+	var u *model.User
 
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil, store.ErrNotFound
-	}
+	err = s.scope(ctx, func(ctx context.Context) error {
+		return read(ctx, s.pool, s.replicas, func(q querier) error {
+			u, err = scanUser(q.QueryRow(ctx,
+				s.sql("SELECT "+userColumns+" FROM table WHERE tenant_id=$1 AND id=$2 AND deleted_at IS NULL"),
+				tenant.FromContext(ctx), toPgUUID(uid)))
 
+			return err
+		})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("find user by id: %w", err)
 	}
@@ -67,58 +76,264 @@ func (s *Users) FindByID(ctx context.Context, id string) (*model.User, error) {
 
 // FindByEmail finds user by email address.
 func (s *Users) FindByEmail(ctx context.Context, email string) (*model.User, error) {
-	var err error
+	email, err := model.NormalizeEmail(email)
+	if err != nil {
+		return nil, fmt.Errorf("find user by email: %w", store.ErrNotFound)
+	}
 
-	if s.prepFindByEmail == nil {
-		sql := "SELECT id, email, password FROM table WHERE email=:email"
-		sql = strings.Replace(sql, "table", s.tableName, 1)
+	var u *model.User
 
-		s.prepFindByEmail, err = s.db.PrepareNamedContext(ctx, sql)
-		if err != nil {
-			return nil, fmt.Errorf("prepare: %w", err)
-		}
+	err = s.scope(ctx, func(ctx context.Context) error {
+		return read(ctx, s.pool, s.replicas, func(q querier) error {
+			u, err = scanUser(q.QueryRow(ctx, s.sql("SELECT "+userColumns+
+				" FROM table WHERE tenant_id=$1 AND lower(email)=lower($2) AND deleted_at IS NULL"),
+				tenant.FromContext(ctx), email))
+
+			return err
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find user by email: %w", err)
 	}
 
-	u := &model.User{} //nolint:exhaustivestruct
-	err = s.prepFindByEmail.QueryRowxContext(ctx, map[string]interface{}{"email": email}).StructScan(u)
+	return u, nil
+}
 
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil, store.ErrNotFound
+// List implements store.Users interface using keyset pagination.
+func (s *Users) List(ctx context.Context, after string, limit int) ([]*model.User, error) {
+	var afterID uuid.UUID // nil UUID sorts before any other
+
+	if after != "" {
+		var err error
+
+		if afterID, err = uuid.Parse(after); err != nil {
+			return nil, fmt.Errorf("list users: parse after: %w", err)
+		}
 	}
 
+	var users []*model.User
+
+	err := s.scope(ctx, func(ctx context.Context) error {
+		return read(ctx, s.pool, s.replicas, func(q querier) error {
+			rows, err := q.Query(ctx, s.sql("SELECT "+userColumns+
+				" FROM table WHERE tenant_id=$1 AND id>$2 AND deleted_at IS NULL ORDER BY id LIMIT $3"),
+				tenant.FromContext(ctx), toPgUUID(afterID), limit)
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+			defer rows.Close()
+
+			users = make([]*model.User, 0, limit)
+
+			for rows.Next() {
+				u, err := scanUser(rows)
+				if err != nil {
+					return err
+				}
+
+				users = append(users, u)
+			}
+
+			return rows.Err() //nolint:wrapcheck
+		})
+	})
 	if err != nil {
-		return nil, fmt.Errorf("find user by email: %w", err)
+		return nil, fmt.Errorf("list users: %w", err)
 	}
 
-	return u, nil
+	return users, nil
 }
 
-// Insert implements store.Users interface.
+// Insert implements store.Users interface. User's tenant is set from context.
 func (s *Users) Insert(ctx context.Context, u *model.User) error {
-	var err error
+	uid, err := uuid.Parse(u.ID)
+	if err != nil {
+		return fmt.Errorf("insert user: parse id: %w", err)
+	}
+
+	u.TenantID = tenant.FromContext(ctx)
+
+	err = s.scope(ctx, func(ctx context.Context) error {
+		return write(ctx, s.pool).QueryRow(ctx, s.sql(insertUser),
+			toPgUUID(uid), u.TenantID, u.Email, u.Password, u.Admin, u.DisplayName, u.Locale, u.Timezone,
+			u.AvatarURL).
+			Scan(&u.CreatedAt, &u.UpdatedAt, &u.Version)
+	})
+	if err != nil {
+		return fmt.Errorf("insert user: %w", mapError(err))
+	}
+
+	return nil
+}
 
-	if s.prepInsert == nil {
-		sql := "INSERT INTO table (id, email, password) VALUES (:id, :email, :password)"
-		sql = strings.Replace(sql, "table", s.tableName, 1)
+// InsertBatch inserts multiple users in a single round-trip. Batch is executed atomically.
+func (s *Users) InsertBatch(ctx context.Context, users []*model.User) error {
+	b := &pgx.Batch{} //nolint:exhaustivestruct
+	sql := s.sql(insertUser)
+	tenantID := tenant.FromContext(ctx)
 
-		s.prepInsert, err = s.db.PrepareNamedContext(ctx, sql)
+	for _, u := range users {
+		uid, err := uuid.Parse(u.ID)
 		if err != nil {
-			return fmt.Errorf("prepare: %w", err)
+			return fmt.Errorf("insert users: parse id %s: %w", u.ID, err)
 		}
+
+		u.TenantID = tenantID
+		b.Queue(sql, toPgUUID(uid), u.TenantID, u.Email, u.Password, u.Admin, u.DisplayName, u.Locale, u.Timezone,
+			u.AvatarURL)
 	}
 
-	_, err = s.prepInsert.ExecContext(ctx, u)
+	err := s.scope(ctx, func(ctx context.Context) error {
+		br := write(ctx, s.pool).SendBatch(ctx, b)
+		defer br.Close()
+
+		for _, u := range users {
+			if err := br.QueryRow().Scan(&u.CreatedAt, &u.UpdatedAt, &u.Version); err != nil {
+				return err //nolint:wrapcheck
+			}
+		}
 
+		return br.Close() //nolint:wrapcheck
+	})
 	if err != nil {
-		return fmt.Errorf("insert user: %w", err)
+		return fmt.Errorf("insert users: %w", mapError(err))
 	}
 
 	return nil
 }
 
+// Update implements store.Users interface.
+func (s *Users) Update(ctx context.Context, u *model.User) error {
+	uid, err := uuid.Parse(u.ID)
+	if err != nil {
+		return fmt.Errorf("update user: %w", store.ErrNotFound)
+	}
+
+	err = s.scope(ctx, func(ctx context.Context) error {
+		q := write(ctx, s.pool)
+
+		err := q.QueryRow(ctx, s.sql(`UPDATE table SET email=$3, password=$4, admin=$5, display_name=$6,
+			locale=$7, timezone=$8, avatar_url=$9, updated_at=now(), version=version+1
+			WHERE tenant_id=$1 AND id=$2 AND version=$10 AND deleted_at IS NULL RETURNING updated_at, version`),
+			tenant.FromContext(ctx), toPgUUID(uid), u.Email, u.Password, u.Admin, u.DisplayName, u.Locale,
+			u.Timezone, u.AvatarURL, u.Version).
+			Scan(&u.UpdatedAt, &u.Version)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return s.missingOrStale(ctx, q, uid)
+		}
+
+		return err //nolint:wrapcheck
+	})
+	if err != nil {
+		return fmt.Errorf("update user: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// Delete implements store.Users interface.
+func (s *Users) Delete(ctx context.Context, id string) error {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("delete user: %w", store.ErrNotFound)
+	}
+
+	err = s.scope(ctx, func(ctx context.Context) error {
+		tag, err := write(ctx, s.pool).Exec(ctx, s.sql(`UPDATE table SET deleted_at=now(), updated_at=now(),
+			version=version+1 WHERE tenant_id=$1 AND id=$2 AND deleted_at IS NULL`),
+			tenant.FromContext(ctx), toPgUUID(uid))
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if tag.RowsAffected() == 0 {
+			return store.ErrNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete user: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// missingOrStale returns ErrConflict if user exists and ErrNotFound otherwise.
+func (s *Users) missingOrStale(ctx context.Context, q querier, id uuid.UUID) error {
+	var exists bool
+
+	err := q.QueryRow(ctx, s.sql("SELECT EXISTS(SELECT 1 FROM table WHERE tenant_id=$1 AND id=$2 AND "+
+		"deleted_at IS NULL)"), tenant.FromContext(ctx), toPgUUID(id)).Scan(&exists)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if exists {
+		return store.ErrConflict
+	}
+
+	return store.ErrNotFound
+}
+
+// scope runs fn in a transaction with the tenant set for row level security policies, if enabled.
+// Without row level security tenant scoping relies on the queries alone.
+func (s *Users) scope(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !s.rls {
+		return fn(ctx)
+	}
+
+	return withTenant(ctx, s.pool, fn)
+}
+
+// sql replaces table placeholder with quoted table name.
+func (s *Users) sql(query string) string {
+	return strings.Replace(query, "table", pgx.Identifier{s.tableName}.Sanitize(), 1)
+}
+
+func scanUser(row pgx.Row) (*model.User, error) {
+	var id pgtype.UUID
+
+	u := &model.User{} //nolint:exhaustivestruct
+
+	err := row.Scan(&id, &u.TenantID, &u.Email, &u.Password, &u.Admin, &u.DisplayName, &u.Locale, &u.Timezone,
+		&u.AvatarURL, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &u.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	u.ID = uuid.UUID(id.Bytes).String()
+
+	return u, nil
+}
+
+func toPgUUID(id uuid.UUID) pgtype.UUID {
+	return pgtype.UUID{Bytes: id, Valid: true}
+}
+
 // UsersOption ...
 type UsersOption func(*Users)
 
+// UsersReplicas routes reads to replicas. Pool passed to NewUsers is used as primary.
+func UsersReplicas(r *Replicas) UsersOption {
+	return func(u *Users) {
+		u.replicas = r
+	}
+}
+
+// UsersRowLevelSecurity makes each query run in a transaction setting app.tenant_id, which is used by
+// row level security policies. Policies apply only if the database role doesn't own the table.
+// Reads are then always served by the primary.
+func UsersRowLevelSecurity() UsersOption {
+	return func(u *Users) {
+		u.rls = true
+	}
+}
+
 // UsersTableName ...
 func UsersTableName(name string) UsersOption {
 	return func(u *Users) {
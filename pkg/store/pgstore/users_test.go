@@ -0,0 +1,103 @@
+package pgstore_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/acim/arc/pkg/store"
+	"github.com/acim/arc/pkg/store/pgstore"
+	"github.com/acim/arc/pkg/store/storetest"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// dsnEnv names environment variable containing DSN of the Postgres database tests run against.
+const dsnEnv = "ARC_TEST_POSTGRES_DSN"
+
+// newPool creates connection pool to a new schema which is dropped when the test completes.
+func newPool(t *testing.T, opts ...pgstore.MigratorOption) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", dsnEnv)
+	}
+
+	ctx := context.Background()
+
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("parse dsn: %v", err)
+	}
+
+	admin, err := pgxpool.NewWithConfig(ctx, config.Copy())
+	if err != nil {
+		t.Fatalf("new pool: %v", err)
+	}
+
+	t.Cleanup(admin.Close)
+
+	schema := fmt.Sprintf("arc_test_%d", time.Now().UnixNano())
+
+	if _, err = admin.Exec(ctx, "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, _ = admin.Exec(context.Background(), "DROP SCHEMA "+pgx.Identifier{schema}.Sanitize()+" CASCADE")
+	})
+
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("new pool: %v", err)
+	}
+
+	t.Cleanup(pool.Close)
+
+	if err = pgstore.NewMigrator(pool, opts...).Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return pool
+}
+
+func factory(rls bool) storetest.Factory {
+	return func(t *testing.T) (store.Users, store.Transactor) {
+		t.Helper()
+
+		var (
+			migratorOpts []pgstore.MigratorOption
+			opts         []pgstore.UsersOption
+		)
+
+		if rls {
+			migratorOpts = append(migratorOpts, pgstore.MigratorRowLevelSecurity())
+			opts = append(opts, pgstore.UsersRowLevelSecurity())
+		}
+
+		pool := newPool(t, migratorOpts...)
+
+		return pgstore.NewUsers(pool, opts...), pgstore.NewTransactor(pool)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	storetest.TestUsers(t, factory(false))
+}
+
+func TestUsersRowLevelSecurity(t *testing.T) {
+	storetest.TestUsers(t, factory(true))
+}
+
+func TestTransactor(t *testing.T) {
+	storetest.TestTransactor(t, factory(false))
+}
+
+func TestTransactorRowLevelSecurity(t *testing.T) {
+	storetest.TestTransactor(t, factory(true))
+}
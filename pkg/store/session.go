@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type sessionKey struct{}
+
+type session struct {
+	written atomic.Bool
+}
+
+// NewSession returns context tracking writes made through it, i.e. during a single request. Stores
+// routing reads to replicas serve reads following a write in the same session from the primary, so
+// that the session always sees its own writes.
+func NewSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{}) //nolint:exhaustivestruct
+}
+
+// MarkWritten records that a write happened in the session. It is a no-op without a session.
+func MarkWritten(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.written.Store(true)
+	}
+}
+
+// Written returns true if a write happened in the session.
+func Written(ctx context.Context) bool {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+
+	return ok && s.written.Load()
+}
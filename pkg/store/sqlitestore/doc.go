@@ -0,0 +1,2 @@
+// Package sqlitestore contains SQLite store implementation, suitable for single binary deployments.
+package sqlitestore
@@ -0,0 +1,140 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrator applies embedded schema migrations.
+type Migrator struct {
+	db         *sql.DB
+	usersTable string
+}
+
+// NewMigrator creates new migrator.
+func NewMigrator(db *sql.DB, opts ...MigratorOption) *Migrator {
+	m := &Migrator{
+		db:         db,
+		usersTable: "user",
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Migrate applies all migrations not yet applied. Each migration runs in its own transaction.
+func (m *Migrator) Migrate(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "schema_migrations" (
+		"version" integer PRIMARY KEY,
+		"applied_at" text NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+	)`)
+	if err != nil {
+		return fmt.Errorf("migrate: create schema_migrations: %w", err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := m.apply(ctx, name); err != nil {
+			return fmt.Errorf("migrate %s: %w", path.Base(name), err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, name string) error {
+	version, err := strconv.Atoi(strings.SplitN(path.Base(name), "_", 2)[0]) //nolint:gomnd
+	if err != nil {
+		return fmt.Errorf("parse version: %w", err)
+	}
+
+	query, err := m.render(name)
+	if err != nil {
+		return err
+	}
+
+	return NewTransactor(m.db).WithTx(ctx, func(ctx context.Context) error {
+		q := conn(ctx, m.db)
+
+		var applied bool
+
+		err := q.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM "schema_migrations" WHERE "version"=?)`, version).
+			Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("check version: %w", err)
+		}
+
+		if applied {
+			return nil
+		}
+
+		if _, err := q.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+
+		if _, err := q.ExecContext(ctx, `INSERT INTO "schema_migrations" ("version") VALUES (?)`, version); err != nil {
+			return fmt.Errorf("record version: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (m *Migrator) render(name string) (string, error) {
+	tpl, err := template.New(path.Base(name)).
+		Funcs(template.FuncMap{
+			"ident": func(parts ...string) string {
+				return quoteIdent(strings.Join(parts, "_"))
+			},
+		}).
+		ParseFS(migrations, name)
+	if err != nil {
+		return "", fmt.Errorf("parse: %w", err)
+	}
+
+	var sb strings.Builder
+
+	err = tpl.Execute(&sb, map[string]string{
+		"Users":      quoteIdent(m.usersTable),
+		"UsersTable": m.usersTable,
+	})
+	if err != nil {
+		return "", fmt.Errorf("render: %w", err)
+	}
+
+	return sb.String(), nil
+}
+
+// MigratorOption ...
+type MigratorOption func(*Migrator)
+
+// MigratorUsersTable sets the name of the users table, it should match the name passed to UsersTableName.
+func MigratorUsersTable(name string) MigratorOption {
+	return func(m *Migrator) {
+		m.usersTable = name
+	}
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
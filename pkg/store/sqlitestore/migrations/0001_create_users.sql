@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS {{.Users}} (
+  "id" text PRIMARY KEY,
+  "email" text NOT NULL CHECK (length("email") <= 254),
+  "password" text NOT NULL,
+  "display_name" text NOT NULL DEFAULT '' CHECK (length("display_name") <= 100),
+  "locale" text NOT NULL DEFAULT '',
+  "timezone" text NOT NULL DEFAULT '',
+  "avatar_url" text NOT NULL DEFAULT '',
+  "created_at" text NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  "updated_at" text NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  "deleted_at" text,
+  "version" integer NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS {{ident .UsersTable "email_active_key"}} ON {{.Users}} ("email" COLLATE NOCASE)
+  WHERE "deleted_at" IS NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE {{.Users}} ADD COLUMN "tenant_id" text NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS {{ident .UsersTable "email_active_key"}};
+CREATE UNIQUE INDEX {{ident .UsersTable "tenant_email_active_key"}} ON {{.Users}} ("tenant_id", "email" COLLATE NOCASE)
+  WHERE "deleted_at" IS NULL;
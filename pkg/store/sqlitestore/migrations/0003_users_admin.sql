@@ -0,0 +1,2 @@
+-- Users are made administrators by operators, there is no API granting it.
+ALTER TABLE {{.Users}} ADD COLUMN "admin" integer NOT NULL DEFAULT 0;
@@ -0,0 +1,106 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/acim/arc/pkg/store"
+)
+
+var _ store.Transactor = (*Transactor)(nil)
+
+type txKey struct{}
+
+type txState struct {
+	tx    *sql.Tx
+	depth int
+}
+
+// Transactor implements store.Transactor interface.
+type Transactor struct {
+	db *sql.DB
+}
+
+// NewTransactor creates new transactor.
+func NewTransactor(db *sql.DB) *Transactor {
+	return &Transactor{
+		db: db,
+	}
+}
+
+// WithTx runs fn inside a transaction. The transaction is committed if fn returns nil and rolled back
+// if fn returns an error or panics. Nested calls use savepoints.
+func (t *Transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	store.MarkWritten(ctx)
+
+	if parent := txFromContext(ctx); parent != nil {
+		return savepoint(ctx, parent, fn)
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+
+			panic(p)
+		}
+	}()
+
+	if err = fn(store.NewTxContext(context.WithValue(ctx, txKey{}, &txState{tx: tx, depth: 0}))); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			return fmt.Errorf("rollback: %v: %w", rerr, err) //nolint:errorlint
+		}
+
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	return nil
+}
+
+func savepoint(ctx context.Context, parent *txState, fn func(ctx context.Context) error) (err error) {
+	state := &txState{tx: parent.tx, depth: parent.depth + 1}
+	name := fmt.Sprintf("sp_%d", state.depth)
+
+	if _, err = state.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("savepoint: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+
+			panic(p)
+		}
+	}()
+
+	if err = fn(store.NewTxContext(context.WithValue(ctx, txKey{}, state))); err != nil {
+		if _, rerr := state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rerr != nil {
+			return fmt.Errorf("rollback to savepoint: %v: %w", rerr, err) //nolint:errorlint
+		}
+
+		return err
+	}
+
+	if _, err = state.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("release savepoint: %w", err)
+	}
+
+	return nil
+}
+
+func txFromContext(ctx context.Context) *txState {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+	"github.com/acim/arc/pkg/tenant"
+	"github.com/google/uuid"
+)
+
+var _ store.Users = (*Users)(nil)
+
+const (
+	userColumns = "id, tenant_id, email, password, admin, display_name, locale, timezone, avatar_url, created_at, " +
+		"updated_at, deleted_at, version"
+	insertUser = `INSERT INTO table (id, tenant_id, email, password, admin, display_name, locale, timezone,
+		avatar_url)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING created_at, updated_at, version`
+	now = "strftime('%Y-%m-%dT%H:%M:%fZ', 'now')"
+)
+
+// Users implements store.Users interface. All queries are scoped to the tenant found in context.
+type Users struct {
+	db        *sql.DB
+	tableName string
+}
+
+// NewUsers creates new users store.
+func NewUsers(db *sql.DB, opts ...UsersOption) *Users {
+	u := &Users{
+		db:        db,
+		tableName: "user",
+	}
+
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	return u
+}
+
+// FindByID finds user by id (UUID).
+func (s *Users) FindByID(ctx context.Context, id string) (*model.User, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, store.ErrNotFound
+	}
+
+	u, err := scanUser(conn(ctx, s.db).QueryRowContext(ctx,
+		s.sql("SELECT "+userColumns+" FROM table WHERE tenant_id=? AND id=? AND deleted_at IS NULL"),
+		tenant.FromContext(ctx), uid.String()))
+	if err != nil {
+		return nil, fmt.Errorf("find user by id: %w", err)
+	}
+
+	return u, nil
+}
+
+// FindByEmail finds user by email address.
+func (s *Users) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	email, err := model.NormalizeEmail(email)
+	if err != nil {
+		return nil, fmt.Errorf("find user by email: %w", store.ErrNotFound)
+	}
+
+	u, err := scanUser(conn(ctx, s.db).QueryRowContext(ctx,
+		s.sql("SELECT "+userColumns+" FROM table WHERE tenant_id=? AND email=? COLLATE NOCASE AND deleted_at IS NULL"),
+		tenant.FromContext(ctx), email))
+	if err != nil {
+		return nil, fmt.Errorf("find user by email: %w", err)
+	}
+
+	return u, nil
+}
+
+// List implements store.Users interface using keyset pagination.
+func (s *Users) List(ctx context.Context, after string, limit int) ([]*model.User, error) {
+	var afterID uuid.UUID // nil UUID sorts before any other
+
+	if after != "" {
+		var err error
+
+		if afterID, err = uuid.Parse(after); err != nil {
+			return nil, fmt.Errorf("list users: parse after: %w", err)
+		}
+	}
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx,
+		s.sql("SELECT "+userColumns+" FROM table WHERE tenant_id=? AND id>? AND deleted_at IS NULL ORDER BY id LIMIT ?"),
+		tenant.FromContext(ctx), afterID.String(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*model.User, 0, limit)
+
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list users: %w", err)
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+
+	return users, nil
+}
+
+// Insert implements store.Users interface. User's tenant is set from context.
+func (s *Users) Insert(ctx context.Context, u *model.User) error {
+	uid, err := uuid.Parse(u.ID)
+	if err != nil {
+		return fmt.Errorf("insert user: parse id: %w", err)
+	}
+
+	u.TenantID = tenant.FromContext(ctx)
+
+	var createdAt, updatedAt string
+
+	err = conn(ctx, s.db).QueryRowContext(ctx, s.sql(insertUser),
+		uid.String(), u.TenantID, u.Email, u.Password, u.Admin, u.DisplayName, u.Locale, u.Timezone, u.AvatarURL).
+		Scan(&createdAt, &updatedAt, &u.Version)
+	if err != nil {
+		return fmt.Errorf("insert user: %w", mapError(err))
+	}
+
+	if u.CreatedAt, err = parseTime(createdAt); err != nil {
+		return fmt.Errorf("insert user: %w", err)
+	}
+
+	if u.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return fmt.Errorf("insert user: %w", err)
+	}
+
+	return nil
+}
+
+// Update implements store.Users interface.
+func (s *Users) Update(ctx context.Context, u *model.User) error {
+	uid, err := uuid.Parse(u.ID)
+	if err != nil {
+		return fmt.Errorf("update user: %w", store.ErrNotFound)
+	}
+
+	q := conn(ctx, s.db)
+
+	var updatedAt string
+
+	err = q.QueryRowContext(ctx, s.sql(`UPDATE table SET email=?, password=?, admin=?, display_name=?, locale=?,
+		timezone=?, avatar_url=?, updated_at=`+now+`, version=version+1
+		WHERE tenant_id=? AND id=? AND version=? AND deleted_at IS NULL RETURNING updated_at, version`),
+		u.Email, u.Password, u.Admin, u.DisplayName, u.Locale, u.Timezone, u.AvatarURL, tenant.FromContext(ctx), uid.String(),
+		u.Version).
+		Scan(&updatedAt, &u.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("update user: %w", s.missingOrStale(ctx, q, uid))
+	}
+
+	if err != nil {
+		return fmt.Errorf("update user: %w", mapError(err))
+	}
+
+	if u.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return fmt.Errorf("update user: %w", err)
+	}
+
+	return nil
+}
+
+// Delete implements store.Users interface.
+func (s *Users) Delete(ctx context.Context, id string) error {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("delete user: %w", store.ErrNotFound)
+	}
+
+	res, err := conn(ctx, s.db).ExecContext(ctx, s.sql(`UPDATE table SET deleted_at=`+now+`, updated_at=`+now+`,
+		version=version+1 WHERE tenant_id=? AND id=? AND deleted_at IS NULL`), tenant.FromContext(ctx), uid.String())
+	if err != nil {
+		return fmt.Errorf("delete user: %w", mapError(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("delete user: %w", store.ErrNotFound)
+	}
+
+	return nil
+}
+
+// missingOrStale returns ErrConflict if user exists and ErrNotFound otherwise.
+func (s *Users) missingOrStale(ctx context.Context, q querier, id uuid.UUID) error {
+	var exists bool
+
+	err := q.QueryRowContext(ctx, s.sql("SELECT EXISTS(SELECT 1 FROM table WHERE tenant_id=? AND id=? AND "+
+		"deleted_at IS NULL)"), tenant.FromContext(ctx), id.String()).Scan(&exists)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if exists {
+		return store.ErrConflict
+	}
+
+	return store.ErrNotFound
+}
+
+// sql replaces table placeholder with quoted table name.
+func (s *Users) sql(query string) string {
+	return strings.Replace(query, "table", quoteIdent(s.tableName), 1)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner) (*model.User, error) {
+	var (
+		createdAt, updatedAt string
+		deletedAt            sql.NullString
+	)
+
+	u := &model.User{} //nolint:exhaustivestruct
+
+	err := row.Scan(&u.ID, &u.TenantID, &u.Email, &u.Password, &u.Admin, &u.DisplayName, &u.Locale, &u.Timezone,
+		&u.AvatarURL, &createdAt, &updatedAt, &deletedAt, &u.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if u.CreatedAt, err = parseTime(createdAt); err != nil {
+		return nil, err
+	}
+
+	if u.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return nil, err
+	}
+
+	if deletedAt.Valid {
+		t, err := parseTime(deletedAt.String)
+		if err != nil {
+			return nil, err
+		}
+
+		u.DeletedAt = &t
+	}
+
+	return u, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse time: %w", err)
+	}
+
+	return t, nil
+}
+
+// UsersOption ...
+type UsersOption func(*Users)
+
+// UsersTableName ...
+func UsersTableName(name string) UsersOption {
+	return func(u *Users) {
+		u.tableName = name
+	}
+}
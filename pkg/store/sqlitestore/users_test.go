@@ -0,0 +1,42 @@
+package sqlitestore_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/acim/arc/pkg/store"
+	"github.com/acim/arc/pkg/store/sqlitestore"
+	"github.com/acim/arc/pkg/store/storetest"
+)
+
+func factory(t *testing.T) (store.Users, store.Transactor) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	db, err := sqlitestore.NewDB(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("new db: %v", err)
+	}
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	if err = sqlitestore.NewMigrator(db).Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return sqlitestore.NewUsers(db), sqlitestore.NewTransactor(db)
+}
+
+func TestUsers(t *testing.T) {
+	t.Parallel()
+
+	storetest.TestUsers(t, factory)
+}
+
+func TestTransactor(t *testing.T) {
+	t.Parallel()
+
+	storetest.TestTransactor(t, factory)
+}
@@ -0,0 +1,539 @@
+// Package storetest contains conformance tests of store implementations.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/acim/arc/pkg/model"
+	"github.com/acim/arc/pkg/store"
+	"github.com/acim/arc/pkg/tenant"
+)
+
+// Factory creates stores backed by an empty database for a single test.
+type Factory func(t *testing.T) (store.Users, store.Transactor)
+
+// errRollback is returned by transaction functions to roll them back.
+var errRollback = errors.New("rollback")
+
+// TestUsers tests that store.Users implementation created by factory conforms to the interface contract.
+func TestUsers(t *testing.T, factory Factory) {
+	t.Helper()
+
+	tests := map[string]func(t *testing.T, users store.Users){
+		"InsertAndFind":           testInsertAndFind,
+		"TenantScoping":           testTenantScoping,
+		"EmailCaseInsensitive":    testEmailCaseInsensitive,
+		"SoftDelete":              testSoftDelete,
+		"UpdateVersion":           testUpdateVersion,
+		"UpdateConflictNotFound":  testUpdateConflictNotFound,
+		"ListKeysetPagination":    testListKeysetPagination,
+		"FindByMalformedIDMisses": testFindByMalformedID,
+		"Admin":                   testAdmin,
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			users, _ := factory(t)
+			test(t, users)
+		})
+	}
+}
+
+// TestTransactor tests that store.Transactor implementation created by factory commits and rolls back
+// writes of the users store created with it.
+func TestTransactor(t *testing.T, factory Factory) {
+	t.Helper()
+
+	tests := map[string]func(t *testing.T, users store.Users, tx store.Transactor){
+		"Commit":           testCommit,
+		"Rollback":         testRollback,
+		"PanicRollback":    testPanicRollback,
+		"NestedRollback":   testNestedRollback,
+		"NestedCommit":     testNestedCommit,
+		"InTxContext":      testInTxContext,
+		"OuterRollbackAll": testOuterRollbackAll,
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			users, tx := factory(t)
+			test(t, users, tx)
+		})
+	}
+}
+
+func testInsertAndFind(t *testing.T, users store.Users) {
+	ctx := context.Background()
+	u := insert(ctx, t, users, "alice@example.com")
+
+	if u.CreatedAt.IsZero() || u.UpdatedAt.IsZero() {
+		t.Error("insert didn't set timestamps")
+	}
+
+	found, err := users.FindByID(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("find by id: %v", err)
+	}
+
+	if found.Email != u.Email || found.Password != u.Password || found.DisplayName != u.DisplayName ||
+		found.Version != u.Version || found.DeletedAt != nil {
+		t.Errorf("find by id = %+v, want %+v", found, u)
+	}
+
+	if found, err = users.FindByEmail(ctx, "alice@example.com"); err != nil || found.ID != u.ID {
+		t.Errorf("find by email = %v, %v, want %s", found, err, u.ID)
+	}
+
+	if _, err = users.FindByEmail(ctx, "bob@example.com"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("find missing by email error = %v, want ErrNotFound", err)
+	}
+}
+
+func testAdmin(t *testing.T, users store.Users) {
+	ctx := context.Background()
+	u := newUser(t, "alice@example.com")
+	u.Admin = true
+
+	if err := users.Insert(ctx, u); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	u.Admin = false
+
+	if err := users.Update(ctx, u); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	found, err := users.FindByID(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("find by id: %v", err)
+	}
+
+	if found.Admin {
+		t.Error("admin flag not revoked by update")
+	}
+
+	if found = insert(ctx, t, users, "bob@example.com"); found.Admin {
+		t.Error("new user is admin")
+	}
+}
+
+func testTenantScoping(t *testing.T, users store.Users) {
+	ctxA := tenant.NewContext(context.Background(), "a")
+	ctxB := tenant.NewContext(context.Background(), "b")
+
+	a := insert(ctxA, t, users, "alice@example.com")
+	if a.TenantID != "a" {
+		t.Errorf("tenant id = %q, want a", a.TenantID)
+	}
+
+	// The same address may be used in another tenant.
+	b := insert(ctxB, t, users, "alice@example.com")
+
+	if _, err := users.FindByID(ctxB, a.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("find by id of other tenant error = %v, want ErrNotFound", err)
+	}
+
+	if u, err := users.FindByEmail(ctxB, "alice@example.com"); err != nil || u.ID != b.ID {
+		t.Errorf("find by email = %v, %v, want user of tenant b", u, err)
+	}
+
+	if _, err := users.FindByID(context.Background(), a.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("find by id of default tenant error = %v, want ErrNotFound", err)
+	}
+
+	list, err := users.List(ctxB, "", 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+
+	if len(list) != 1 || list[0].ID != b.ID {
+		t.Errorf("list of tenant b = %v, want only %s", ids(list), b.ID)
+	}
+
+	a.DisplayName = "changed"
+	if err = users.Update(ctxB, a); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("update of other tenant's user error = %v, want ErrNotFound", err)
+	}
+
+	if err = users.Delete(ctxB, a.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("delete of other tenant's user error = %v, want ErrNotFound", err)
+	}
+
+	if _, err = users.FindByID(ctxA, a.ID); err != nil {
+		t.Errorf("user modified through other tenant: %v", err)
+	}
+}
+
+func testEmailCaseInsensitive(t *testing.T, users store.Users) {
+	ctx := context.Background()
+	u := insert(ctx, t, users, "Alice@Example.com")
+
+	dup := newUser(t, "alice@example.com")
+	if err := users.Insert(ctx, dup); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("insert differing in case error = %v, want ErrConflict", err)
+	}
+
+	if found, err := users.FindByEmail(ctx, "ALICE@EXAMPLE.COM"); err != nil || found.ID != u.ID {
+		t.Errorf("find by email in other case = %v, %v, want %s", found, err, u.ID)
+	}
+
+	other := insert(ctx, t, users, "bob@example.com")
+	other.Email = "ALICE@example.com"
+
+	if err := users.Update(ctx, other); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("update to address differing in case error = %v, want ErrConflict", err)
+	}
+}
+
+func testSoftDelete(t *testing.T, users store.Users) {
+	ctx := context.Background()
+	u := insert(ctx, t, users, "alice@example.com")
+
+	if err := users.Delete(ctx, u.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if _, err := users.FindByID(ctx, u.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("find deleted by id error = %v, want ErrNotFound", err)
+	}
+
+	if _, err := users.FindByEmail(ctx, u.Email); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("find deleted by email error = %v, want ErrNotFound", err)
+	}
+
+	if list, err := users.List(ctx, "", 10); err != nil || len(list) != 0 {
+		t.Errorf("list = %v, %v, want no users", ids(list), err)
+	}
+
+	if err := users.Delete(ctx, u.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("delete of deleted user error = %v, want ErrNotFound", err)
+	}
+
+	if err := users.Update(ctx, u); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("update of deleted user error = %v, want ErrNotFound", err)
+	}
+
+	// Address of deleted user may be used again.
+	insert(ctx, t, users, "alice@example.com")
+}
+
+func testUpdateVersion(t *testing.T, users store.Users) {
+	ctx := context.Background()
+	u := insert(ctx, t, users, "alice@example.com")
+	version := u.Version
+
+	u.DisplayName = "Alice"
+	u.Locale = "de-DE"
+
+	if err := users.Update(ctx, u); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	if u.Version != version+1 {
+		t.Errorf("version = %d, want %d", u.Version, version+1)
+	}
+
+	found, err := users.FindByID(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("find by id: %v", err)
+	}
+
+	if found.DisplayName != "Alice" || found.Locale != "de-DE" || found.Version != u.Version {
+		t.Errorf("found = %+v, want updated user", found)
+	}
+}
+
+func testUpdateConflictNotFound(t *testing.T, users store.Users) {
+	ctx := context.Background()
+	u := insert(ctx, t, users, "alice@example.com")
+
+	stale := *u
+
+	if err := users.Update(ctx, u); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	if err := users.Update(ctx, &stale); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("update of stale version error = %v, want ErrConflict", err)
+	}
+
+	missing := newUser(t, "bob@example.com")
+	if err := users.Update(ctx, missing); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("update of missing user error = %v, want ErrNotFound", err)
+	}
+
+	if err := users.Delete(ctx, missing.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("delete of missing user error = %v, want ErrNotFound", err)
+	}
+}
+
+func testListKeysetPagination(t *testing.T, users store.Users) {
+	ctx := context.Background()
+	want := make([]string, 0, 5)
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com",
+		"e@example.com"} {
+		want = append(want, insert(ctx, t, users, email).ID)
+	}
+
+	sort.Strings(want)
+
+	var (
+		got   []string
+		after string
+	)
+
+	for page := 0; page < 4; page++ {
+		list, err := users.List(ctx, after, 2)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+
+		if len(list) > 2 {
+			t.Fatalf("list returned %d users, limit is 2", len(list))
+		}
+
+		if len(list) == 0 {
+			break
+		}
+
+		got = append(got, ids(list)...)
+		after = list[len(list)-1].ID
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("listed %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("listed %v, want %v", got, want)
+		}
+	}
+}
+
+func testFindByMalformedID(t *testing.T, users store.Users) {
+	if _, err := users.FindByID(context.Background(), "not-a-uuid"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("find by malformed id error = %v, want ErrNotFound", err)
+	}
+}
+
+func testCommit(t *testing.T, users store.Users, tx store.Transactor) {
+	ctx := context.Background()
+
+	var u *model.User
+
+	err := tx.WithTx(ctx, func(ctx context.Context) error {
+		u = insert(ctx, t, users, "alice@example.com")
+
+		_, err := users.FindByID(ctx, u.ID)
+
+		return err //nolint:wrapcheck
+	})
+	if err != nil {
+		t.Fatalf("with tx: %v", err)
+	}
+
+	if _, err = users.FindByID(ctx, u.ID); err != nil {
+		t.Errorf("committed user not found: %v", err)
+	}
+}
+
+func testRollback(t *testing.T, users store.Users, tx store.Transactor) {
+	ctx := context.Background()
+
+	var u *model.User
+
+	err := tx.WithTx(ctx, func(ctx context.Context) error {
+		u = insert(ctx, t, users, "alice@example.com")
+
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("with tx error = %v, want errRollback", err)
+	}
+
+	if _, err = users.FindByID(ctx, u.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("rolled back user find error = %v, want ErrNotFound", err)
+	}
+}
+
+func testPanicRollback(t *testing.T, users store.Users, tx store.Transactor) {
+	ctx := context.Background()
+
+	var u *model.User
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic was not propagated")
+			}
+		}()
+
+		_ = tx.WithTx(ctx, func(ctx context.Context) error {
+			u = insert(ctx, t, users, "alice@example.com")
+
+			panic("boom")
+		})
+	}()
+
+	if _, err := users.FindByID(ctx, u.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("user inserted before panic find error = %v, want ErrNotFound", err)
+	}
+}
+
+func testNestedRollback(t *testing.T, users store.Users, tx store.Transactor) {
+	ctx := context.Background()
+
+	var outer, inner *model.User
+
+	err := tx.WithTx(ctx, func(ctx context.Context) error {
+		outer = insert(ctx, t, users, "alice@example.com")
+
+		err := tx.WithTx(ctx, func(ctx context.Context) error {
+			inner = insert(ctx, t, users, "bob@example.com")
+
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Errorf("nested with tx error = %v, want errRollback", err)
+		}
+
+		// Transaction stays usable after savepoint rollback.
+		if _, err = users.FindByID(ctx, inner.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("rolled back nested user find error = %v, want ErrNotFound", err)
+		}
+
+		insert(ctx, t, users, "bob@example.com")
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("with tx: %v", err)
+	}
+
+	if _, err = users.FindByID(ctx, outer.ID); err != nil {
+		t.Errorf("outer user not committed: %v", err)
+	}
+
+	if _, err = users.FindByID(ctx, inner.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("nested rolled back user find error = %v, want ErrNotFound", err)
+	}
+}
+
+func testNestedCommit(t *testing.T, users store.Users, tx store.Transactor) {
+	ctx := context.Background()
+
+	var inner *model.User
+
+	err := tx.WithTx(ctx, func(ctx context.Context) error {
+		return tx.WithTx(ctx, func(ctx context.Context) error {
+			return tx.WithTx(ctx, func(ctx context.Context) error {
+				inner = insert(ctx, t, users, "alice@example.com")
+
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		t.Fatalf("with tx: %v", err)
+	}
+
+	if _, err = users.FindByID(ctx, inner.ID); err != nil {
+		t.Errorf("nested user not committed: %v", err)
+	}
+}
+
+func testOuterRollbackAll(t *testing.T, users store.Users, tx store.Transactor) {
+	ctx := context.Background()
+
+	var inner *model.User
+
+	err := tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := tx.WithTx(ctx, func(ctx context.Context) error {
+			inner = insert(ctx, t, users, "alice@example.com")
+
+			return nil
+		}); err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("with tx error = %v, want errRollback", err)
+	}
+
+	if _, err = users.FindByID(ctx, inner.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("released savepoint survived outer rollback, find error = %v", err)
+	}
+}
+
+func testInTxContext(t *testing.T, _ store.Users, tx store.Transactor) {
+	ctx := store.NewSession(context.Background())
+
+	err := tx.WithTx(ctx, func(ctx context.Context) error {
+		if !store.InTx(ctx) {
+			t.Error("context passed to fn is not marked as transaction")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("with tx: %v", err)
+	}
+
+	if store.InTx(ctx) {
+		t.Error("outer context is marked as transaction")
+	}
+
+	if !store.Written(ctx) {
+		t.Error("transaction is not recorded as session write")
+	}
+}
+
+func newUser(t *testing.T, email string) *model.User {
+	t.Helper()
+
+	id, err := model.NewID()
+	if err != nil {
+		t.Fatalf("new id: %v", err)
+	}
+
+	return &model.User{ //nolint:exhaustivestruct
+		ID:       id.String(),
+		Email:    email,
+		Password: "hash",
+		Profile:  model.Profile{DisplayName: "Test", Locale: "en", Timezone: "UTC", AvatarURL: ""},
+	}
+}
+
+func insert(ctx context.Context, t *testing.T, users store.Users, email string) *model.User {
+	t.Helper()
+
+	u := newUser(t, email)
+
+	if err := users.Insert(ctx, u); err != nil {
+		t.Fatalf("insert %s: %v", email, err)
+	}
+
+	return u
+}
+
+func ids(users []*model.User) []string {
+	s := make([]string, 0, len(users))
+
+	for _, u := range users {
+		s = append(s, u.ID)
+	}
+
+	return s
+}
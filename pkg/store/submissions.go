@@ -0,0 +1,30 @@
+package store
+
+import (
+	"context"
+
+	"github.com/acim/arc/pkg/model"
+)
+
+// SubmissionFilter selects submissions. Zero values match all submissions.
+type SubmissionFilter struct {
+	// Query matches name, company, e-mail address, subject or text, case insensitive.
+	Query  string
+	Status string
+	// Handled selects handled or not handled submissions.
+	Handled *bool
+}
+
+// Submissions ...
+type Submissions interface {
+	FindByID(ctx context.Context, id string) (*model.Submission, error)
+	// List returns up to limit submissions matching filter ordered from the newest, starting after the
+	// given ID. Empty after starts from the newest one.
+	List(ctx context.Context, filter *SubmissionFilter, after string, limit int) ([]*model.Submission, error)
+	Insert(ctx context.Context, s *model.Submission) error
+	// UpdateDelivery stores status, message ID, provider and error of the submission.
+	UpdateDelivery(ctx context.Context, s *model.Submission) error
+	// SetHandled marks submission as handled by the user, or as not handled if userID is empty. Marking
+	// already handled submission keeps the original user and time.
+	SetHandled(ctx context.Context, id, userID string) (*model.Submission, error)
+}
@@ -0,0 +1,24 @@
+package store
+
+import "context"
+
+// Transactor runs a function as a single unit of work. Stores detect the
+// running transaction through the context passed to fn.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+// NewTxContext marks context as running inside a transaction. Transactors call it on the context passed
+// to fn, so that store decorators, i.e. caches, can tell that reads may see uncommitted data.
+func NewTxContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, txKey{}, true)
+}
+
+// InTx returns true if context runs inside a transaction started by a Transactor.
+func InTx(ctx context.Context) bool {
+	in, _ := ctx.Value(txKey{}).(bool)
+
+	return in
+}
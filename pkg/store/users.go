@@ -12,8 +12,24 @@ import (
 type Users interface {
 	FindByID(ctx context.Context, id string) (*model.User, error)
 	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	// List returns up to limit users ordered by ID, starting after the given ID. Empty after
+	// starts from the beginning.
+	List(ctx context.Context, after string, limit int) ([]*model.User, error)
 	Insert(ctx context.Context, user *model.User) error
+	// Update updates user if its version matches the stored one, otherwise ErrConflict is returned.
+	// On success user's version and update time are refreshed.
+	Update(ctx context.Context, user *model.User) error
+	// Delete soft deletes user. Deleted users are not returned by any of the find methods.
+	Delete(ctx context.Context, id string) error
 }
 
-// ErrNotFound it returned when there are no results in the query.
-var ErrNotFound = errors.New("not found")
+// Errors.
+var (
+	// ErrNotFound it returned when there are no results in the query.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a record conflicts with an existing one, i.e. unique key violation.
+	ErrConflict = errors.New("conflict")
+	// ErrConstraint is returned when a record violates a database constraint other than uniqueness,
+	// i.e. foreign key, not null or check constraint.
+	ErrConstraint = errors.New("constraint violation")
+)
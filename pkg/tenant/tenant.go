@@ -0,0 +1,85 @@
+// Package tenant contains tenant identification used to isolate data of customers sharing a deployment.
+package tenant
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// Claim is the name of JWT claim containing tenant ID.
+const Claim = "tid"
+
+type tenantKey struct{}
+
+// NewContext returns context carrying tenant ID.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext returns tenant ID from context. Empty string, the default tenant, is returned if context
+// carries no tenant.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(tenantKey{}).(string)
+
+	return id
+}
+
+// Resolver returns tenant ID identified from request and true, or false if request doesn't identify a tenant.
+type Resolver func(r *http.Request) (string, bool)
+
+// FromHeader resolves tenant from request header.
+func FromHeader(name string) Resolver {
+	return func(r *http.Request) (string, bool) {
+		id := strings.TrimSpace(r.Header.Get(name))
+
+		return id, id != ""
+	}
+}
+
+// FromSubdomain resolves tenant from the first label of the host name under the base domain,
+// i.e. acme.example.com resolves to acme for the base domain example.com.
+func FromSubdomain(baseDomain string) Resolver {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+
+	return func(r *http.Request) (string, bool) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		host = strings.ToLower(host)
+		if !strings.HasSuffix(host, suffix) {
+			return "", false
+		}
+
+		sub := strings.TrimSuffix(host, suffix)
+		if i := strings.LastIndex(sub, "."); i >= 0 {
+			sub = sub[i+1:]
+		}
+
+		return sub, sub != ""
+	}
+}
+
+// FromToken resolves tenant from the claim of verified JWT found in Authorization header.
+func FromToken(ja *jwtauth.JWTAuth) Resolver {
+	return func(r *http.Request) (string, bool) {
+		token, err := jwtauth.VerifyRequest(ja, r, jwtauth.TokenFromHeader)
+		if err != nil || token == nil {
+			return "", false
+		}
+
+		v, ok := token.Get(Claim)
+		if !ok {
+			return "", false
+		}
+
+		id, ok := v.(string)
+
+		return id, ok && id != ""
+	}
+}
@@ -0,0 +1,140 @@
+package tenant_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/acim/arc/pkg/tenant"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+func TestFromSubdomain(t *testing.T) {
+	t.Parallel()
+
+	resolve := tenant.FromSubdomain(".Example.com.")
+
+	tests := map[string]struct {
+		host string
+		want string
+		ok   bool
+	}{
+		"subdomain":        {host: "acme.example.com", want: "acme", ok: true},
+		"port":             {host: "acme.example.com:8080", want: "acme", ok: true},
+		"upper case":       {host: "ACME.Example.COM", want: "acme", ok: true},
+		"nested subdomain": {host: "www.acme.example.com", want: "acme", ok: true},
+		"base domain":      {host: "example.com", ok: false},
+		"other domain":     {host: "acme.example.org", ok: false},
+		"suffix lookalike": {host: "acmeexample.com", ok: false},
+		"empty label":      {host: ".example.com", ok: false},
+		"ip address":       {host: "127.0.0.1:8080", ok: false},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tc.host
+
+			if id, ok := resolve(req); id != tc.want || ok != tc.ok {
+				t.Errorf("resolved %q, %t, want %q, %t", id, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestFromHeader(t *testing.T) {
+	t.Parallel()
+
+	resolve := tenant.FromHeader("X-Tenant")
+
+	tests := map[string]struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		"tenant":  {value: "acme", want: "acme", ok: true},
+		"padded":  {value: "  acme ", want: "acme", ok: true},
+		"missing": {value: "", ok: false},
+		"blank":   {value: "   ", ok: false},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.value != "" {
+				req.Header.Set("X-Tenant", tc.value)
+			}
+
+			if id, ok := resolve(req); id != tc.want || ok != tc.ok {
+				t.Errorf("resolved %q, %t, want %q, %t", id, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestFromToken(t *testing.T) {
+	t.Parallel()
+
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+	other := jwtauth.New("HS256", []byte("other secret"), nil)
+
+	tests := map[string]struct {
+		auth   *jwtauth.JWTAuth
+		claims map[string]interface{}
+		want   string
+		ok     bool
+	}{
+		"tenant claim":     {auth: auth, claims: map[string]interface{}{tenant.Claim: "acme"}, want: "acme", ok: true},
+		"no claim":         {auth: auth, claims: map[string]interface{}{"sub": "jane"}, ok: false},
+		"empty claim":      {auth: auth, claims: map[string]interface{}{tenant.Claim: ""}, ok: false},
+		"non-string claim": {auth: auth, claims: map[string]interface{}{tenant.Claim: 1}, ok: false},
+		"invalid signature": {
+			auth: other, claims: map[string]interface{}{tenant.Claim: "acme"}, ok: false,
+		},
+		"no token": {ok: false},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			if tc.auth != nil {
+				_, token, err := tc.auth.Encode(tc.claims)
+				if err != nil {
+					t.Fatalf("encode token: %v", err)
+				}
+
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			if id, ok := tenant.FromToken(auth)(req); id != tc.want || ok != tc.ok {
+				t.Errorf("resolved %q, %t, want %q, %t", id, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	if id := tenant.FromContext(context.Background()); id != "" {
+		t.Errorf("tenant = %q, want default", id)
+	}
+
+	if id := tenant.FromContext(tenant.NewContext(context.Background(), "acme")); id != "acme" {
+		t.Errorf("tenant = %q, want acme", id)
+	}
+}